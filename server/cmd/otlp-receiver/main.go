@@ -0,0 +1,26 @@
+// otlp-receiver 本地 OTLP/HTTP 接收端，用于在没有采集器的环境下联调 OTLP 导出
+//
+//	go run ./cmd/otlp-receiver -addr :4318
+//	go run . -otlp-endpoint http://localhost:4318
+//	curl localhost:4318/stats
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"analyseGo/internal/otlp"
+)
+
+func main() {
+	addr := flag.String("addr", ":4318", "监听地址")
+	verbose := flag.Bool("v", false, "打印每个收到的 span")
+	flag.Parse()
+
+	rv := otlp.NewReceiver(*verbose)
+	log.Printf("OTLP receiver listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, rv.Handler()); err != nil {
+		log.Fatalf("Failed to start receiver: %v", err)
+	}
+}
@@ -0,0 +1,193 @@
+// Package auth 认证与基于角色的访问控制
+//
+// 调用方通过 Authorization: Bearer <token> 携带凭据，token 为两种之一：
+// 登录接口签发的会话 token（HS256 JWT），或配置文件中的 API token。
+// EventSource 和 WebSocket 无法设置请求头，这两类接口也可用 ?access_token= 传递（见 Authenticator.Middleware）；
+// 其余接口不接受查询参数中的 token，以免 token 出现在代理日志和 Referer 中。
+// 连续登录失败按客户端 IP 和用户名指数退避。
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// defaultSessionTTL 会话 token 默认有效期
+	defaultSessionTTL = 12 * time.Hour
+	// QueryParam 无法设置请求头时携带 token 的查询参数
+	QueryParam = "access_token"
+)
+
+// 认证方式
+const (
+	MethodSession   = "session"   // 登录签发的 JWT
+	MethodToken     = "token"     // 配置的 API token
+	MethodAnonymous = "anonymous" // 未启用认证
+)
+
+var (
+	// ErrInvalidLogin 用户名或密码错误
+	ErrInvalidLogin = errors.New("invalid username or password")
+	// ErrInvalidToken 无法识别的 API token 或会话 token
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// User 可登录的用户
+type User struct {
+	Username     string
+	PasswordHash string // bcrypt 哈希
+	Role         Role
+}
+
+// Token API token，只保存哈希
+type Token struct {
+	Name string // 用作调用方标识
+	Hash string // token 的 SHA-256 十六进制
+	Role Role
+}
+
+// Config 认证配置
+type Config struct {
+	Enabled    bool
+	Secret     []byte // 会话 token 签名密钥，为空时随机生成，重启后已签发的会话失效
+	SessionTTL time.Duration
+	Users      []User
+	Tokens     []Token
+}
+
+// apiToken 解码后的 API token
+type apiToken struct {
+	name string
+	hash []byte
+	role Role
+}
+
+// Authenticator 校验凭据并签发会话 token
+type Authenticator struct {
+	enabled bool
+	secret  []byte
+	ttl     time.Duration
+	users   map[string]User
+	tokens  []apiToken
+	// dummyHash 用户名不存在时也做一次 bcrypt 比较，避免通过响应时间枚举用户名
+	dummyHash []byte
+	// logins 登录失败退避
+	logins *loginLimiter
+}
+
+// New 按配置创建 Authenticator，校验密码哈希和 token 哈希的格式
+func New(cfg Config) (*Authenticator, error) {
+	a := &Authenticator{
+		enabled: cfg.Enabled,
+		secret:  cfg.Secret,
+		ttl:     cfg.SessionTTL,
+		users:   make(map[string]User, len(cfg.Users)),
+		logins:  newLoginLimiter(),
+	}
+	if a.ttl <= 0 {
+		a.ttl = defaultSessionTTL
+	}
+	if len(a.secret) == 0 {
+		a.secret = make([]byte, 32)
+		rand.Read(a.secret)
+		if a.enabled && len(cfg.Users) > 0 {
+			slog.Warn("No auth secret configured, sessions will not survive a restart")
+		}
+	}
+
+	for _, u := range cfg.Users {
+		if _, err := bcrypt.Cost([]byte(u.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("user %q: invalid bcrypt hash: %w", u.Username, err)
+		}
+		if _, ok := a.users[u.Username]; ok {
+			return nil, fmt.Errorf("user %q is duplicated", u.Username)
+		}
+		a.users[u.Username] = u
+	}
+	for _, t := range cfg.Tokens {
+		hash, err := hex.DecodeString(t.Hash)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("token %q: hash must be a hex SHA-256 digest", t.Name)
+		}
+		a.tokens = append(a.tokens, apiToken{name: t.Name, hash: hash, role: t.Role})
+	}
+	if len(a.users) > 0 {
+		a.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	}
+	return a, nil
+}
+
+// Enabled 是否启用了认证
+func (a *Authenticator) Enabled() bool {
+	return a.enabled
+}
+
+// Login 校验用户名和密码，成功时签发会话 token 并返回过期时间
+func (a *Authenticator) Login(username, password string) (string, time.Time, error) {
+	u, ok := a.users[username]
+	hash := a.dummyHash
+	if ok {
+		hash = []byte(u.PasswordHash)
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !ok {
+		return "", time.Time{}, ErrInvalidLogin
+	}
+	return a.Issue(u.Username, u.Role)
+}
+
+// Issue 为指定调用方签发会话 token
+func (a *Authenticator) Issue(subject string, role Role) (string, time.Time, error) {
+	now := time.Now()
+	exp := now.Add(a.ttl)
+	token, err := signJWT(a.secret, claims{
+		Issuer:   issuer,
+		Subject:  subject,
+		Role:     role,
+		IssuedAt: now.Unix(),
+		Expires:  exp.Unix(),
+	})
+	return token, exp, err
+}
+
+// Authenticate 校验 token，依次尝试会话 token 和 API token
+func (a *Authenticator) Authenticate(token string) (Principal, error) {
+	// JWT 由三段组成，API token 不含点号
+	if strings.Count(token, ".") == 2 {
+		c, err := parseJWT(a.secret, token, time.Now())
+		if err != nil {
+			return Principal{}, err
+		}
+		if !c.Role.Allows(RoleReader) {
+			return Principal{}, ErrInvalidToken
+		}
+		return Principal{Subject: c.Subject, Role: c.Role, Method: MethodSession}, nil
+	}
+
+	sum := sha256.Sum256([]byte(token))
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare(sum[:], t.hash) == 1 {
+			return Principal{Subject: t.name, Role: t.role, Method: MethodToken}, nil
+		}
+	}
+	return Principal{}, ErrInvalidToken
+}
+
+// Credential 从 Authorization: Bearer 请求头取出 token
+func Credential(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
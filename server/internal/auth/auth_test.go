@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRoleAllows(t *testing.T) {
+	tests := []struct {
+		role, min Role
+		want      bool
+	}{
+		{RoleReader, RoleReader, true},
+		{RoleReader, RoleEditor, false},
+		{RoleReader, RoleAdmin, false},
+		{RoleEditor, RoleReader, true},
+		{RoleEditor, RoleAdmin, false},
+		{RoleAdmin, RoleReader, true},
+		{RoleAdmin, RoleAdmin, true},
+		{Role("root"), RoleReader, false},
+		{Role(""), Role(""), false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Allows(tt.min); got != tt.want {
+			t.Errorf("%q.Allows(%q) = %v, want %v", tt.role, tt.min, got, tt.want)
+		}
+	}
+}
+
+// newTestAuthenticator 创建带一个 reader API token 的 Authenticator
+func newTestAuthenticator(t *testing.T, enabled bool) *Authenticator {
+	t.Helper()
+	sum := sha256.Sum256([]byte("reader-token"))
+	a, err := New(Config{
+		Enabled: enabled,
+		Secret:  testSecret,
+		Tokens:  []Token{{Name: "ci", Hash: hex.EncodeToString(sum[:]), Role: RoleReader}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAuthenticateRejectsUnknownRole(t *testing.T) {
+	a := newTestAuthenticator(t, true)
+	now := time.Now()
+	token := mustSign(t, testSecret, claims{Issuer: issuer, Subject: "mallory", Role: Role("superuser"), Expires: now.Add(time.Hour).Unix()})
+	if _, err := a.Authenticate(token); err != ErrInvalidToken {
+		t.Errorf("unknown role: err = %v, want %v", err, ErrInvalidToken)
+	}
+}
+
+func TestRequireRoles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	editorToken, _, err := newTestAuthenticator(t, true).Issue("alice", RoleEditor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := mustSign(t, testSecret, claims{Issuer: issuer, Subject: "alice", Role: RoleAdmin, Expires: time.Now().Add(-time.Minute).Unix()})
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		credential string
+		route      string
+		want       int
+	}{
+		{"no credential", true, "", "/read", http.StatusUnauthorized},
+		{"reader token reads", true, "reader-token", "/read", http.StatusOK},
+		{"reader token cannot edit", true, "reader-token", "/edit", http.StatusForbidden},
+		{"editor session edits", true, editorToken, "/edit", http.StatusOK},
+		{"editor session cannot admin", true, editorToken, "/admin", http.StatusForbidden},
+		{"expired session", true, expired, "/read", http.StatusUnauthorized},
+		{"unknown token", true, "nope", "/read", http.StatusUnauthorized},
+		{"auth disabled", false, "", "/admin", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAuthenticator(t, tt.enabled)
+			r := gin.New()
+			r.Use(a.Middleware())
+			ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+			r.GET("/read", a.Require(RoleReader), ok)
+			r.GET("/edit", a.Require(RoleEditor), ok)
+			r.GET("/admin", a.Require(RoleAdmin), ok)
+
+			req := httptest.NewRequest(http.MethodGet, tt.route, nil)
+			if tt.credential != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.credential)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %s)", w.Code, tt.want, w.Body.String())
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestQueryTokenOnlyOnStreamRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := newTestAuthenticator(t, true)
+	r := gin.New()
+	r.Use(a.Middleware("/stream"))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/stream", a.Require(RoleReader), ok)
+	r.GET("/read", a.Require(RoleReader), ok)
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		want   int
+	}{
+		{"stream accepts query token", "/stream?access_token=reader-token", "", http.StatusOK},
+		{"stream rejects bad query token", "/stream?access_token=nope", "", http.StatusUnauthorized},
+		{"header wins over query", "/stream?access_token=nope", "reader-token", http.StatusOK},
+		{"other route rejects query token", "/read?access_token=reader-token", "", http.StatusBadRequest},
+		{"other route rejects query token with header", "/read?access_token=reader-token", "reader-token", http.StatusBadRequest},
+		{"other route accepts header", "/read", "reader-token", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (body %s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"analyseGo/internal/ginutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// loginRequest 登录请求体
+type loginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// HandleLogin 用户名密码登录，返回会话 token
+func (a *Authenticator) HandleLogin(c *gin.Context) {
+	if !a.enabled {
+		ginutil.Error(c, http.StatusNotFound, "Authentication is disabled")
+		return
+	}
+	var req loginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ginutil.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 在校验密码之前退避，等待期间的尝试不计入失败，也不消耗 bcrypt
+	clientKey, userKey := "ip:"+c.ClientIP(), "user:"+req.Username
+	if wait := a.logins.wait(clientKey, userKey); wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		ginutil.Error(c, http.StatusTooManyRequests, "Too many failed login attempts, retry later")
+		return
+	}
+
+	token, exp, err := a.Login(req.Username, req.Password)
+	if errors.Is(err, ErrInvalidLogin) {
+		a.logins.fail(clientKey, userKey)
+		slog.WarnContext(c.Request.Context(), "Login failed", "username", req.Username, "client_ip", c.ClientIP())
+		ginutil.Error(c, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		ginutil.Error(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+	// 只清除用户名的记录：同一客户端成功登录自己的账号不应重置其对其他账号的失败计数
+	a.logins.succeed(userKey)
+	slog.InfoContext(c.Request.Context(), "Login succeeded", "username", req.Username)
+	c.JSON(http.StatusOK, gin.H{
+		"token":     token,
+		"tokenType": "Bearer",
+		"expiresAt": exp.UnixMilli(),
+		"role":      a.users[req.Username].Role,
+	})
+}
+
+// HandleMe 返回当前调用方
+func (a *Authenticator) HandleMe(c *gin.Context) {
+	p, _ := FromContext(c.Request.Context())
+	c.JSON(http.StatusOK, p)
+}
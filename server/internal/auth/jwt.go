@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// issuer 会话 token 的签发者
+const issuer = "analyseGo"
+
+var (
+	errMalformedToken = errors.New("malformed token")
+	errBadSignature   = errors.New("invalid token signature")
+	errTokenExpired   = errors.New("token expired")
+)
+
+// jwtHeader 固定的 HS256 头，解析时要求完全一致，拒绝 alg=none 等其他算法
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// claims 会话 token 的载荷
+type claims struct {
+	Issuer   string `json:"iss"`
+	Subject  string `json:"sub"`
+	Role     Role   `json:"role"`
+	IssuedAt int64  `json:"iat"` // Unix 秒
+	Expires  int64  `json:"exp"` // Unix 秒
+}
+
+// signJWT 生成 HS256 签名的 JWT
+func signJWT(secret []byte, c claims) (string, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(hmacSHA256(secret, unsigned)), nil
+}
+
+// parseJWT 校验签名、签发者和有效期并返回载荷
+func parseJWT(secret []byte, token string, now time.Time) (claims, error) {
+	header, rest, ok := strings.Cut(token, ".")
+	if !ok || header != jwtHeader {
+		return claims{}, errMalformedToken
+	}
+	payload, sig, ok := strings.Cut(rest, ".")
+	if !ok {
+		return claims{}, errMalformedToken
+	}
+	want, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return claims{}, errMalformedToken
+	}
+	if !hmac.Equal(want, hmacSHA256(secret, header+"."+payload)) {
+		return claims{}, errBadSignature
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return claims{}, errMalformedToken
+	}
+	var c claims
+	if err := json.Unmarshal(raw, &c); err != nil || c.Issuer != issuer || c.Subject == "" {
+		return claims{}, errMalformedToken
+	}
+	if now.Unix() >= c.Expires {
+		return claims{}, errTokenExpired
+	}
+	return c, nil
+}
+
+// hmacSHA256 计算 HMAC-SHA256
+func hmacSHA256(secret []byte, msg string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(msg))
+	return h.Sum(nil)
+}
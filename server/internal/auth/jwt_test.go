@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSecret = []byte("test-secret")
+
+// mustSign 用 secret 签发 token
+func mustSign(t *testing.T, secret []byte, c claims) string {
+	t.Helper()
+	token, err := signJWT(secret, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// forge 用任意头和载荷构造 token，并用 secret 做 HS256 签名
+func forge(t *testing.T, secret []byte, header string, c claims) string {
+	t.Helper()
+	payload, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(hmacSHA256(secret, unsigned))
+}
+
+func TestParseJWT(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	valid := claims{Issuer: issuer, Subject: "alice", Role: RoleEditor, IssuedAt: now.Unix(), Expires: now.Add(time.Hour).Unix()}
+
+	adminPayload := valid
+	adminPayload.Role = RoleAdmin
+	tampered := mustSign(t, testSecret, valid)
+	parts := strings.Split(tampered, ".")
+	raw, _ := json.Marshal(adminPayload)
+	parts[1] = base64.RawURLEncoding.EncodeToString(raw)
+	tampered = strings.Join(parts, ".")
+
+	noneToken := forge(t, testSecret, `{"alg":"none","typ":"JWT"}`, valid)
+	noneToken = noneToken[:strings.LastIndex(noneToken, ".")+1]
+
+	wrongIssuer := valid
+	wrongIssuer.Issuer = "someone-else"
+	noSubject := valid
+	noSubject.Subject = ""
+
+	tests := []struct {
+		name    string
+		token   string
+		now     time.Time
+		wantErr error
+	}{
+		{"valid", mustSign(t, testSecret, valid), now, nil},
+		{"other secret", mustSign(t, []byte("other-secret"), valid), now, errBadSignature},
+		{"tampered payload", tampered, now, errBadSignature},
+		{"truncated signature", mustSign(t, testSecret, valid)[:10], now, errMalformedToken},
+		{"garbage signature", parts[0] + "." + parts[1] + ".!!!", now, errMalformedToken},
+		{"alg none", noneToken, now, errMalformedToken},
+		{"alg HS512 header", forge(t, testSecret, `{"alg":"HS512","typ":"JWT"}`, valid), now, errMalformedToken},
+		{"alg RS256 header", forge(t, testSecret, `{"alg":"RS256","typ":"JWT"}`, valid), now, errMalformedToken},
+		{"reordered header", forge(t, testSecret, `{"typ":"JWT","alg":"HS256"}`, valid), now, errMalformedToken},
+		{"wrong issuer", mustSign(t, testSecret, wrongIssuer), now, errMalformedToken},
+		{"no subject", mustSign(t, testSecret, noSubject), now, errMalformedToken},
+		{"one second before expiry", mustSign(t, testSecret, valid), now.Add(time.Hour - time.Second), nil},
+		{"at expiry", mustSign(t, testSecret, valid), now.Add(time.Hour), errTokenExpired},
+		{"after expiry", mustSign(t, testSecret, valid), now.Add(2 * time.Hour), errTokenExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseJWT(testSecret, tt.token, tt.now)
+			if err != tt.wantErr {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != valid {
+				t.Errorf("claims = %+v, want %+v", got, valid)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// loginFreeFailures 连续失败该次数以内不限制
+	loginFreeFailures = 5
+	// loginBaseDelay 超出后第一次失败的等待时间，此后每次失败翻倍
+	loginBaseDelay = time.Second
+	// loginMaxDelay 等待时间上限
+	loginMaxDelay = 15 * time.Minute
+	// loginForget 最后一次失败超过该时长后清零
+	loginForget = time.Hour
+	// maxLoginKeys 跟踪的客户端和用户名上限，防止大量来源耗尽内存
+	maxLoginKeys = 10000
+)
+
+// loginAttempts 一个客户端或用户名的连续失败记录
+type loginAttempts struct {
+	failures int
+	last     time.Time // 最后一次失败
+	until    time.Time // 下次允许尝试的时间
+}
+
+// loginLimiter 按客户端 IP 和用户名对连续登录失败做指数退避
+type loginLimiter struct {
+	now func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]*loginAttempts
+}
+
+// newLoginLimiter 创建登录限流器
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{now: time.Now, keys: make(map[string]*loginAttempts)}
+}
+
+// wait 返回 keys 中还需等待的最长时间，0 表示允许尝试
+func (l *loginLimiter) wait(keys ...string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	var d time.Duration
+	for _, k := range keys {
+		if a, ok := l.keys[k]; ok {
+			d = max(d, a.until.Sub(now))
+		}
+	}
+	return d
+}
+
+// fail 记录一次失败，超过 loginFreeFailures 次后按次数指数增加等待时间
+func (l *loginLimiter) fail(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	for _, k := range keys {
+		a, ok := l.keys[k]
+		if ok && now.Sub(a.last) >= loginForget {
+			a.failures = 0
+		}
+		if !ok {
+			if len(l.keys) >= maxLoginKeys && !l.prune(now) {
+				// 已满且没有过期记录：不再跟踪新来源，已跟踪的来源照常限流
+				continue
+			}
+			a = &loginAttempts{}
+			l.keys[k] = a
+		}
+		a.failures++
+		a.last = now
+		if n := a.failures - loginFreeFailures; n > 0 {
+			delay := loginMaxDelay
+			if n <= 20 {
+				delay = min(loginBaseDelay<<(n-1), loginMaxDelay)
+			}
+			a.until = now.Add(delay)
+		}
+	}
+}
+
+// succeed 清除 keys 的失败记录
+func (l *loginLimiter) succeed(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, k := range keys {
+		delete(l.keys, k)
+	}
+}
+
+// prune 删除最后一次失败超过 loginForget 的记录，返回是否删除了任何记录，调用方需持有 mu
+func (l *loginLimiter) prune(now time.Time) bool {
+	n := len(l.keys)
+	for k, a := range l.keys {
+		if now.Sub(a.last) >= loginForget {
+			delete(l.keys, k)
+		}
+	}
+	return len(l.keys) < n
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginLimiterBackoff(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newLoginLimiter()
+	l.now = func() time.Time { return now }
+
+	for i := range loginFreeFailures {
+		if d := l.wait("ip:a"); d != 0 {
+			t.Fatalf("wait after %d failures = %v, want 0", i, d)
+		}
+		l.fail("ip:a")
+	}
+	if d := l.wait("ip:a"); d != 0 {
+		t.Fatalf("wait after %d failures = %v, want 0", loginFreeFailures, d)
+	}
+
+	// 超出后每次失败等待时间翻倍，直到上限
+	want := loginBaseDelay
+	for range 12 {
+		l.fail("ip:a")
+		if d := l.wait("ip:a"); d != want {
+			t.Fatalf("wait = %v, want %v", d, want)
+		}
+		want = min(want*2, loginMaxDelay)
+	}
+	for range 100 {
+		l.fail("ip:a")
+	}
+	if d := l.wait("ip:a"); d != loginMaxDelay {
+		t.Errorf("wait after many failures = %v, want cap %v", d, loginMaxDelay)
+	}
+
+	// 取所有键中最长的等待，未记录的键不受影响
+	if d := l.wait("ip:b", "ip:a"); d != loginMaxDelay {
+		t.Errorf("wait(b, a) = %v, want %v", d, loginMaxDelay)
+	}
+	if d := l.wait("ip:b"); d != 0 {
+		t.Errorf("wait(b) = %v, want 0", d)
+	}
+
+	// 等待结束后仍保留计数，下一次失败继续退避
+	now = now.Add(loginMaxDelay)
+	if d := l.wait("ip:a"); d != 0 {
+		t.Errorf("wait after delay elapsed = %v, want 0", d)
+	}
+	l.fail("ip:a")
+	if d := l.wait("ip:a"); d != loginMaxDelay {
+		t.Errorf("wait after next failure = %v, want %v", d, loginMaxDelay)
+	}
+
+	// 长时间没有失败后计数清零
+	now = now.Add(loginForget)
+	l.fail("ip:a")
+	if d := l.wait("ip:a"); d != 0 {
+		t.Errorf("wait after forget = %v, want 0", d)
+	}
+
+	l.succeed("ip:a")
+	if _, ok := l.keys["ip:a"]; ok {
+		t.Error("succeed did not clear the record")
+	}
+}
+
+func TestLoginLimiterBounded(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	l := newLoginLimiter()
+	l.now = func() time.Time { return now }
+
+	for i := range maxLoginKeys + 10 {
+		l.fail(fmt.Sprintf("ip:%d", i))
+	}
+	if n := len(l.keys); n != maxLoginKeys {
+		t.Fatalf("tracked keys = %d, want %d", n, maxLoginKeys)
+	}
+	// 已跟踪的键照常计数
+	for range loginFreeFailures {
+		l.fail("ip:0")
+	}
+	if d := l.wait("ip:0"); d != loginBaseDelay {
+		t.Errorf("wait for tracked key = %v, want %v", d, loginBaseDelay)
+	}
+
+	// 过期记录被清理后可以跟踪新键
+	now = now.Add(loginForget)
+	l.fail("ip:new")
+	if _, ok := l.keys["ip:new"]; !ok {
+		t.Error("new key not tracked after expired records were pruned")
+	}
+	if n := len(l.keys); n != 1 {
+		t.Errorf("tracked keys after prune = %d, want 1", n)
+	}
+}
+
+func TestHandleLoginBackoff(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := New(Config{
+		Enabled: true,
+		Secret:  testSecret,
+		Users: []User{
+			{Username: "alice", PasswordHash: string(hash), Role: RoleEditor},
+			{Username: "bob", PasswordHash: string(hash), Role: RoleReader},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.POST("/login", a.HandleLogin)
+	login := func(ip, user, password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(fmt.Sprintf(`{"username":%q,"password":%q}`, user, password)))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":40000"
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for range loginFreeFailures + 1 {
+		if w := login("192.0.2.1", "alice", "wrong"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("wrong password: status = %d, want 401", w.Code)
+		}
+	}
+	// 正确密码在退避期间同样被拒绝
+	w := login("192.0.2.1", "alice", "secret")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("during backoff: status = %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Errorf("Retry-After = %q, want 1", w.Header().Get("Retry-After"))
+	}
+	// 换客户端也不能继续猜同一个用户名，同一客户端也不能换用户名
+	if w := login("192.0.2.2", "alice", "secret"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("other client, same user: status = %d, want 429", w.Code)
+	}
+	if w := login("192.0.2.1", "bob", "secret"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("same client, other user: status = %d, want 429", w.Code)
+	}
+	if w := login("192.0.2.2", "bob", "secret"); w.Code != http.StatusOK {
+		t.Errorf("unrelated client and user: status = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"net/http"
+	"slices"
+
+	"analyseGo/internal/ginutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// anonymous 未启用认证时的调用方，拥有全部权限
+var anonymous = Principal{Subject: "anonymous", Role: RoleAdmin, Method: MethodAnonymous}
+
+// Middleware 识别调用方并存入 Request.Context
+// 未携带凭据的请求继续处理，由 Require 决定是否放行；携带了无效凭据时直接返回 401。
+// streamRoutes 为可用 ?access_token= 传递 token 的路由（gin 的 FullPath，如 EventSource 和 WebSocket 接口），
+// 其余路由带该参数时返回 400
+func (a *Authenticator) Middleware(streamRoutes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.enabled {
+			c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), anonymous))
+			c.Next()
+			return
+		}
+
+		token := Credential(c.Request)
+		if query, ok := c.GetQuery(QueryParam); ok {
+			if !slices.Contains(streamRoutes, c.FullPath()) {
+				ginutil.Error(c, http.StatusBadRequest, "The "+QueryParam+" query parameter is only accepted by stream endpoints, use the Authorization header")
+				c.Abort()
+				return
+			}
+			if token == "" {
+				token = query
+			}
+		}
+		if token == "" {
+			c.Next()
+			return
+		}
+		p, err := a.Authenticate(token)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			ginutil.Error(c, http.StatusUnauthorized, err.Error())
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), p))
+		c.Next()
+	}
+}
+
+// Require 要求调用方至少具备 min 角色，未认证返回 401，权限不足返回 403
+func (a *Authenticator) Require(min Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := FromContext(c.Request.Context())
+		if !ok {
+			c.Header("WWW-Authenticate", "Bearer")
+			ginutil.Error(c, http.StatusUnauthorized, "Authentication required")
+			c.Abort()
+			return
+		}
+		if !p.Role.Allows(min) {
+			ginutil.Error(c, http.StatusForbidden, "Requires "+string(min)+" role")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
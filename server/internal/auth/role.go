@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+)
+
+// Role 访问角色，高级别角色拥有低级别角色的全部权限
+type Role string
+
+const (
+	RoleReader Role = "reader" // 查看指标和已发布文章
+	RoleEditor Role = "editor" // 另可读写文章、提交注释
+	RoleAdmin  Role = "admin"  // 另可使用压测、合成负载、性能剖析和管理接口
+)
+
+// level 角色级别，未知角色为 0
+func (r Role) level() int {
+	switch r {
+	case RoleReader:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Allows 是否具备 min 角色的权限
+func (r Role) Allows(min Role) bool {
+	return r.level() > 0 && r.level() >= min.level()
+}
+
+// ParseRole 解析角色名
+func ParseRole(s string) (Role, error) {
+	if r := Role(s); r.level() > 0 {
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown role %q, want reader, editor or admin", s)
+}
+
+// Principal 已认证的调用方
+type Principal struct {
+	Subject string `json:"subject"` // 用户名或 API token 名称
+	Role    Role   `json:"role"`
+	Method  string `json:"method"` // session / token / anonymous（未启用认证）
+}
+
+type ctxKey struct{}
+
+// WithPrincipal 把调用方存入 ctx
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext 从 ctx 取出调用方，未认证时 ok 为 false
+func FromContext(ctx context.Context) (p Principal, ok bool) {
+	p, ok = ctx.Value(ctxKey{}).(Principal)
+	return p, ok
+}
+
+// HasRole ctx 中的调用方是否具备 min 角色的权限
+// 未启用认证时中间件会放入 admin 角色的匿名调用方
+func HasRole(ctx context.Context, min Role) bool {
+	p, ok := FromContext(ctx)
+	return ok && p.Role.Allows(min)
+}
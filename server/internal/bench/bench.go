@@ -0,0 +1,322 @@
+// Package bench 把一段时间内的 Tracker 历史和路由延迟记录为命名的 run，并对比两个 run 生成报告
+//
+// run 可以实时记录（Start/Stop），也可以事后标记过去的时间区间（Mark），
+// 后者的路由延迟取自请求日志，区间早于日志容量时只覆盖其中较近的部分。
+package bench
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"analyseGo/internal/metrics"
+)
+
+// maxRuns 最多保留的 run 数
+const maxRuns = 50
+
+var (
+	// ErrRunExists run 名称已被使用
+	ErrRunExists = errors.New("run already exists")
+	// ErrRunNotFound run 不存在
+	ErrRunNotFound = errors.New("run not found")
+	// ErrRunStopped run 已经结束
+	ErrRunStopped = errors.New("run already stopped")
+	// ErrTooManyRuns run 数量已达上限
+	ErrTooManyRuns = errors.New("too many runs, delete some first")
+	// ErrInvalidRange 标记的时间区间无效
+	ErrInvalidRange = errors.New("from must be before to and to must not be in the future")
+)
+
+// reName 合法的 run 名称
+var reName = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RouteSummary 一个路由在 run 期间的请求统计
+type RouteSummary struct {
+	Route    string  `json:"route"`
+	Requests int     `json:"requests"` // 请求数
+	RPS      float64 `json:"rps"`      // 平均每秒请求数
+	Latency  Stat    `json:"latency"`  // 延迟（毫秒）
+	P50Ms    float64 `json:"p50Ms"`
+	P95Ms    float64 `json:"p95Ms"`
+	P99Ms    float64 `json:"p99Ms"`
+}
+
+// RunSummary run 的汇总
+type RunSummary struct {
+	Name        string         `json:"name"`
+	Active      bool           `json:"active"`      // 是否仍在记录
+	StartedAt   int64          `json:"startedAt"`   // 开始时间戳（毫秒）
+	EndedAt     int64          `json:"endedAt"`     // 结束时间戳（毫秒），记录中为 0
+	DurationSec float64        `json:"durationSec"` // 时长（秒）
+	Samples     int            `json:"samples"`     // 期间的 Tracker 样本数
+	Goroutines  Stat           `json:"goroutines"`  // Goroutine 数
+	HeapAlloc   Stat           `json:"heapAlloc"`   // 堆内存（字节）
+	GCCount     uint64         `json:"gcCount"`     // 期间的 GC 次数
+	Requests    int            `json:"requests"`    // 期间的请求总数
+	Routes      []RouteSummary `json:"routes"`
+	// RoutesSince 事后标记的 run 中路由统计的实际起点（毫秒），请求日志未覆盖整个区间时晚于 StartedAt
+	RoutesSince int64 `json:"routesSince,omitempty"`
+}
+
+// routeRecord 一个路由在 run 期间的请求延迟
+type routeRecord struct {
+	latency accumulator // 毫秒
+	sample  reservoir
+}
+
+// run 一段命名的记录区间
+type run struct {
+	name    string
+	start   time.Time
+	end     time.Time               // 记录中为零值
+	samples []metrics.Sample        // 结束时冻结，避免历史被淘汰
+	routes  map[string]*routeRecord // 按路由记录延迟
+	// routesSince 事后标记且请求日志未覆盖整个区间时，路由统计的实际起点
+	routesSince time.Time
+}
+
+// Manager 管理 run 的记录、查询和对比，作为请求钩子挂在 Tracker 上
+type Manager struct {
+	tracker    *metrics.Tracker
+	requestLog *metrics.RequestLog // 事后标记 run 时的路由延迟来源，可为 nil
+	active     atomic.Int32        // 记录中的 run 数，为 0 时钩子直接返回
+
+	mu   sync.Mutex
+	runs []*run
+}
+
+// NewManager 创建 run 管理器并注册为 tracker 的请求钩子
+// requestLog 为 tracker 的请求日志，为 nil 时事后标记的 run 不含路由统计
+func NewManager(tracker *metrics.Tracker, requestLog *metrics.RequestLog) *Manager {
+	m := &Manager{tracker: tracker, requestLog: requestLog}
+	tracker.AddHook(m)
+	return m
+}
+
+// StartRequest 实现 metrics.RequestHook
+func (m *Manager) StartRequest(ctx context.Context, _ *http.Request, _ string) context.Context {
+	return ctx
+}
+
+// EndRequest 把请求延迟计入所有记录中的 run
+func (m *Manager) EndRequest(_ context.Context, info metrics.RequestInfo) {
+	if m.active.Load() == 0 {
+		return
+	}
+	ms := float64(info.Duration) / float64(time.Millisecond)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.runs {
+		if !r.end.IsZero() || info.Start.Before(r.start) {
+			continue
+		}
+		rec := r.routes[info.Route]
+		if rec == nil {
+			rec = &routeRecord{}
+			r.routes[info.Route] = rec
+		}
+		rec.latency.add(ms)
+		rec.sample.add(ms)
+	}
+}
+
+// Start 开始记录一个命名的 run
+func (m *Manager) Start(name string) (RunSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkNew(name); err != nil {
+		return RunSummary{}, err
+	}
+	r := &run{name: name, start: m.tracker.Now(), routes: make(map[string]*routeRecord)}
+	m.runs = append(m.runs, r)
+	m.active.Add(1)
+	return m.summarize(r), nil
+}
+
+// Mark 把过去的时间区间 [from, to] 标记为已结束的 run，例如一次事故或调优窗口
+// 样本取自 Tracker 历史，路由延迟取自请求日志
+func (m *Manager) Mark(name string, from, to time.Time) (RunSummary, error) {
+	if !from.Before(to) || to.After(m.tracker.Now()) {
+		return RunSummary{}, ErrInvalidRange
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.checkNew(name); err != nil {
+		return RunSummary{}, err
+	}
+	r := &run{name: name, start: from, end: to, routes: make(map[string]*routeRecord)}
+	r.samples = m.samplesOf(r)
+	if m.requestLog != nil {
+		entries, complete := m.requestLog.Between(from.UnixMilli(), to.UnixMilli())
+		for _, e := range entries {
+			rec := r.routes[e.Route]
+			if rec == nil {
+				rec = &routeRecord{}
+				r.routes[e.Route] = rec
+			}
+			rec.latency.add(e.LatencyMs)
+			rec.sample.add(e.LatencyMs)
+		}
+		if !complete {
+			r.routesSince = to
+			if len(entries) > 0 {
+				r.routesSince = time.UnixMilli(entries[0].Time)
+			}
+		}
+	}
+	m.runs = append(m.runs, r)
+	return m.summarize(r), nil
+}
+
+// checkNew 校验新 run 的名称和数量，调用方需持有 mu
+func (m *Manager) checkNew(name string) error {
+	if !reName.MatchString(name) {
+		return fmt.Errorf("invalid run name %q: use 1-64 letters, digits, '.', '_' or '-'", name)
+	}
+	if m.find(name) != nil {
+		return ErrRunExists
+	}
+	if len(m.runs) >= maxRuns {
+		return ErrTooManyRuns
+	}
+	return nil
+}
+
+// Stop 结束记录并冻结期间的样本
+func (m *Manager) Stop(name string) (RunSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.find(name)
+	if r == nil {
+		return RunSummary{}, ErrRunNotFound
+	}
+	if !r.end.IsZero() {
+		return RunSummary{}, ErrRunStopped
+	}
+	r.end = m.tracker.Now()
+	r.samples = m.samplesOf(r)
+	m.active.Add(-1)
+	return m.summarize(r), nil
+}
+
+// Delete 删除 run，记录中的 run 会先结束
+func (m *Manager) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.runs, func(r *run) bool { return r.name == name })
+	if i < 0 {
+		return ErrRunNotFound
+	}
+	if m.runs[i].end.IsZero() {
+		m.active.Add(-1)
+	}
+	m.runs = slices.Delete(m.runs, i, i+1)
+	return nil
+}
+
+// Get 返回 run 的汇总
+func (m *Manager) Get(name string) (RunSummary, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.find(name)
+	if r == nil {
+		return RunSummary{}, false
+	}
+	return m.summarize(r), true
+}
+
+// List 返回所有 run 的汇总，按开始时间排序
+func (m *Manager) List() []RunSummary {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]RunSummary, 0, len(m.runs))
+	for _, r := range m.runs {
+		out = append(out, m.summarize(r))
+	}
+	return out
+}
+
+// find 按名称查找 run，调用方需持有 mu
+func (m *Manager) find(name string) *run {
+	for _, r := range m.runs {
+		if r.name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// samplesOf 返回 run 区间内的 Tracker 样本
+func (m *Manager) samplesOf(r *run) []metrics.Sample {
+	if !r.end.IsZero() && r.samples != nil {
+		return r.samples
+	}
+	samples := m.tracker.SamplesSince(r.start.UnixMilli())
+	if !r.end.IsZero() {
+		end := r.end.UnixMilli()
+		for i, smp := range samples {
+			if smp.Time > end {
+				return samples[:i]
+			}
+		}
+	}
+	return samples
+}
+
+// summarize 计算 run 的汇总，调用方需持有 mu
+func (m *Manager) summarize(r *run) RunSummary {
+	end := r.end
+	if end.IsZero() {
+		end = m.tracker.Now()
+	}
+	s := RunSummary{
+		Name:        r.name,
+		Active:      r.end.IsZero(),
+		StartedAt:   r.start.UnixMilli(),
+		DurationSec: end.Sub(r.start).Seconds(),
+		Routes:      make([]RouteSummary, 0, len(r.routes)),
+	}
+	if !r.end.IsZero() {
+		s.EndedAt = r.end.UnixMilli()
+	}
+	if !r.routesSince.IsZero() {
+		s.RoutesSince = r.routesSince.UnixMilli()
+	}
+
+	samples := m.samplesOf(r)
+	var goroutines, heap accumulator
+	for _, smp := range samples {
+		goroutines.add(float64(smp.Goroutines))
+		heap.add(float64(smp.HeapAlloc))
+		s.GCCount += uint64(smp.GCIncrement)
+	}
+	s.Samples = len(samples)
+	s.Goroutines = goroutines.stat()
+	s.HeapAlloc = heap.stat()
+
+	for route, rec := range r.routes {
+		rs := RouteSummary{
+			Route:    route,
+			Requests: rec.latency.n,
+			Latency:  rec.latency.stat(),
+		}
+		if s.DurationSec > 0 {
+			rs.RPS = float64(rs.Requests) / s.DurationSec
+		}
+		rs.P50Ms, rs.P95Ms, rs.P99Ms = rec.sample.quantiles()
+		s.Requests += rs.Requests
+		s.Routes = append(s.Routes, rs)
+	}
+	slices.SortFunc(s.Routes, func(a, b RouteSummary) int { return strings.Compare(a.Route, b.Route) })
+	return s
+}
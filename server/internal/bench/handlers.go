@@ -0,0 +1,113 @@
+package bench
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"analyseGo/internal/ginutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// startRequest 开始记录 run 的请求体，同时给出 from 和 to 时把过去的区间标记为 run
+type startRequest struct {
+	Name string `json:"name" binding:"required"`
+	From int64  `json:"from"` // 区间起点（毫秒）
+	To   int64  `json:"to"`   // 区间终点（毫秒）
+}
+
+// errorStatus 返回错误对应的状态码
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrRunNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrRunExists), errors.Is(err, ErrRunStopped):
+		return http.StatusConflict
+	case errors.Is(err, ErrTooManyRuns):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// HandleStart 开始记录一个命名的 run，或把 from/to 区间标记为已结束的 run
+func (m *Manager) HandleStart(c *gin.Context) {
+	var req startRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ginutil.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if (req.From == 0) != (req.To == 0) {
+		ginutil.Error(c, http.StatusBadRequest, "from and to must be given together")
+		return
+	}
+	var run RunSummary
+	var err error
+	if req.From != 0 {
+		run, err = m.Mark(req.Name, time.UnixMilli(req.From), time.UnixMilli(req.To))
+	} else {
+		run, err = m.Start(req.Name)
+	}
+	if err != nil {
+		ginutil.Error(c, errorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// HandleStop 结束记录
+func (m *Manager) HandleStop(c *gin.Context) {
+	run, err := m.Stop(c.Param("name"))
+	if err != nil {
+		ginutil.Error(c, errorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// HandleList 列出所有 run
+func (m *Manager) HandleList(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"runs": m.List()})
+}
+
+// HandleGet 获取 run 的汇总
+func (m *Manager) HandleGet(c *gin.Context) {
+	run, ok := m.Get(c.Param("name"))
+	if !ok {
+		ginutil.Error(c, http.StatusNotFound, ErrRunNotFound.Error())
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// HandleDelete 删除 run
+func (m *Manager) HandleDelete(c *gin.Context) {
+	if err := m.Delete(c.Param("name")); err != nil {
+		ginutil.Error(c, errorStatus(err), err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Run deleted successfully"})
+}
+
+// HandleCompare 对比两个 run（?base=&target=），format=markdown 时返回 Markdown
+func (m *Manager) HandleCompare(c *gin.Context) {
+	base, target := c.Query("base"), c.Query("target")
+	if base == "" || target == "" {
+		ginutil.Error(c, http.StatusBadRequest, "base and target are required")
+		return
+	}
+	report, err := m.Compare(base, target)
+	if err != nil {
+		ginutil.Error(c, errorStatus(err), err.Error())
+		return
+	}
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, report)
+	case "markdown", "md":
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(report.Markdown()))
+	default:
+		ginutil.Error(c, http.StatusBadRequest, "format must be json or markdown")
+	}
+}
@@ -0,0 +1,258 @@
+package bench
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// mib 字节换算为 MiB
+const mib = 1 << 20
+
+// Delta 一个指标在两个 run 之间的变化
+type Delta struct {
+	Metric      string   `json:"metric"`
+	Base        float64  `json:"base"`
+	Target      float64  `json:"target"`
+	Change      float64  `json:"change"`              // Target - Base
+	ChangePct   *float64 `json:"changePct,omitempty"` // 相对变化（%），Base 为 0 时省略
+	PValue      *float64 `json:"pValue,omitempty"`    // 双侧 p 值，未做检验时省略
+	Significant bool     `json:"significant"`         // p < alpha
+	Note        string   `json:"note"`                // 检验方法与结论
+}
+
+// RouteComparison 一个路由的对比
+type RouteComparison struct {
+	Route   string  `json:"route"`
+	Note    string  `json:"note,omitempty"` // 只出现在一个 run 中时说明
+	Metrics []Delta `json:"metrics"`
+}
+
+// RunInfo 参与对比的 run 概况
+type RunInfo struct {
+	Name        string  `json:"name"`
+	Active      bool    `json:"active"`
+	StartedAt   int64   `json:"startedAt"`
+	EndedAt     int64   `json:"endedAt"`
+	DurationSec float64 `json:"durationSec"`
+	Samples     int     `json:"samples"`
+	Requests    int     `json:"requests"`
+}
+
+// Comparison 两个 run 的对比报告
+type Comparison struct {
+	Base    RunInfo           `json:"base"`
+	Target  RunInfo           `json:"target"`
+	Alpha   float64           `json:"alpha"` // 显著性水平
+	Process []Delta           `json:"process"`
+	Routes  []RouteComparison `json:"routes"`
+	Notes   []string          `json:"notes"`
+}
+
+// Compare 对比两个 run，base 为基线，target 为调整后的 run
+func (m *Manager) Compare(base, target string) (Comparison, error) {
+	b, ok := m.Get(base)
+	if !ok {
+		return Comparison{}, fmt.Errorf("%w: %s", ErrRunNotFound, base)
+	}
+	t, ok := m.Get(target)
+	if !ok {
+		return Comparison{}, fmt.Errorf("%w: %s", ErrRunNotFound, target)
+	}
+	return compare(b, t), nil
+}
+
+// compare 生成对比报告
+func compare(b, t RunSummary) Comparison {
+	c := Comparison{
+		Base:   runInfo(b),
+		Target: runInfo(t),
+		Alpha:  alpha,
+		Routes: []RouteComparison{},
+		Notes:  []string{},
+	}
+
+	c.Process = []Delta{
+		meanDelta("goroutinesMean", b.Goroutines, t.Goroutines, 1),
+		meanDelta("heapAllocMeanMiB", b.HeapAlloc, t.HeapAlloc, mib),
+		descriptive("heapAllocMaxMiB", b.HeapAlloc.Max/mib, t.HeapAlloc.Max/mib),
+		rateDelta("gcPerMinute", float64(b.GCCount), b.DurationSec, float64(t.GCCount), t.DurationSec, 60),
+	}
+
+	routes := make(map[string][2]*RouteSummary)
+	for i := range b.Routes {
+		r := &b.Routes[i]
+		routes[r.Route] = [2]*RouteSummary{r, nil}
+	}
+	for i := range t.Routes {
+		r := &t.Routes[i]
+		pair := routes[r.Route]
+		pair[1] = r
+		routes[r.Route] = pair
+	}
+	names := make([]string, 0, len(routes))
+	for name := range routes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		c.Routes = append(c.Routes, compareRoute(name, routes[name], b.DurationSec, t.DurationSec))
+	}
+
+	if b.Active || t.Active {
+		c.Notes = append(c.Notes, "at least one run is still recording; figures will change")
+	}
+	if b.Samples < 2 || t.Samples < 2 {
+		c.Notes = append(c.Notes, "fewer than 2 tracker samples in a run; process metrics cannot be tested")
+	}
+	if d := math.Max(b.DurationSec, t.DurationSec); d > 0 && math.Abs(b.DurationSec-t.DurationSec)/d > 0.2 {
+		c.Notes = append(c.Notes, "run durations differ by more than 20%; counts are compared as rates")
+	}
+	c.Notes = append(c.Notes,
+		"means are compared with Welch's t-test, rates with a Poisson rate test (normal approximation); percentiles and maxima are descriptive",
+		"tracker samples within a run are autocorrelated, so p-values for process metrics are optimistic")
+	return c
+}
+
+// compareRoute 对比一个路由，pair 为 base 与 target 中的统计，可能缺一
+func compareRoute(name string, pair [2]*RouteSummary, baseSec, targetSec float64) RouteComparison {
+	var zero RouteSummary
+	b, t := pair[0], pair[1]
+	rc := RouteComparison{Route: name}
+	switch {
+	case b == nil:
+		b, rc.Note = &zero, "only in target run"
+	case t == nil:
+		t, rc.Note = &zero, "only in base run"
+	}
+	rc.Metrics = []Delta{
+		rateDelta("rps", float64(b.Requests), baseSec, float64(t.Requests), targetSec, 1),
+		meanDelta("latencyMeanMs", b.Latency, t.Latency, 1),
+		descriptive("latencyP95Ms", b.P95Ms, t.P95Ms),
+		descriptive("latencyP99Ms", b.P99Ms, t.P99Ms),
+	}
+	return rc
+}
+
+// runInfo 提取 run 概况
+func runInfo(s RunSummary) RunInfo {
+	return RunInfo{
+		Name:        s.Name,
+		Active:      s.Active,
+		StartedAt:   s.StartedAt,
+		EndedAt:     s.EndedAt,
+		DurationSec: s.DurationSec,
+		Samples:     s.Samples,
+		Requests:    s.Requests,
+	}
+}
+
+// newDelta 计算变化量
+func newDelta(metric string, base, target float64) Delta {
+	d := Delta{Metric: metric, Base: base, Target: target, Change: target - base}
+	if base != 0 {
+		pct := (target - base) / math.Abs(base) * 100
+		d.ChangePct = &pct
+	}
+	return d
+}
+
+// withTest 填入检验结果
+func (d Delta) withTest(method string, p float64, ok bool) Delta {
+	if !ok {
+		d.Note = "insufficient data for " + method
+		return d
+	}
+	d.PValue = &p
+	d.Significant = p < alpha
+	if d.Significant {
+		d.Note = fmt.Sprintf("significant (%s, p=%.3g)", method, p)
+	} else {
+		d.Note = fmt.Sprintf("not significant (%s, p=%.3g)", method, p)
+	}
+	return d
+}
+
+// meanDelta 均值对比，scale 为单位换算除数
+func meanDelta(metric string, b, t Stat, scale float64) Delta {
+	p, ok := welchTest(b, t)
+	return newDelta(metric, b.Mean/scale, t.Mean/scale).withTest("Welch t-test", p, ok)
+}
+
+// rateDelta 速率对比，per 为速率的时间单位（秒）
+func rateDelta(metric string, c1, t1, c2, t2, per float64) Delta {
+	var r1, r2 float64
+	if t1 > 0 {
+		r1 = c1 / t1 * per
+	}
+	if t2 > 0 {
+		r2 = c2 / t2 * per
+	}
+	p, ok := rateTest(c1, t1, c2, t2)
+	return newDelta(metric, r1, r2).withTest("Poisson rate test", p, ok)
+}
+
+// descriptive 不做检验的对比
+func descriptive(metric string, base, target float64) Delta {
+	d := newDelta(metric, base, target)
+	d.Note = "descriptive only"
+	return d
+}
+
+// Markdown 把报告渲染为 Markdown
+func (c Comparison) Markdown() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Benchmark comparison: %s → %s\n\n", mdEscape(c.Base.Name), mdEscape(c.Target.Name))
+
+	sb.WriteString("| | Base | Target |\n|---|---:|---:|\n")
+	fmt.Fprintf(&sb, "| Run | %s | %s |\n", mdEscape(c.Base.Name), mdEscape(c.Target.Name))
+	fmt.Fprintf(&sb, "| Duration (s) | %.1f | %.1f |\n", c.Base.DurationSec, c.Target.DurationSec)
+	fmt.Fprintf(&sb, "| Tracker samples | %d | %d |\n", c.Base.Samples, c.Target.Samples)
+	fmt.Fprintf(&sb, "| Requests | %d | %d |\n\n", c.Base.Requests, c.Target.Requests)
+
+	sb.WriteString("## Process\n\n")
+	writeDeltaTable(&sb, c.Process)
+
+	sb.WriteString("\n## Routes\n")
+	if len(c.Routes) == 0 {
+		sb.WriteString("\nNo requests were recorded in either run.\n")
+	}
+	for _, r := range c.Routes {
+		fmt.Fprintf(&sb, "\n### `%s`\n\n", strings.ReplaceAll(r.Route, "`", "'"))
+		if r.Note != "" {
+			fmt.Fprintf(&sb, "_%s_\n\n", r.Note)
+		}
+		writeDeltaTable(&sb, r.Metrics)
+	}
+
+	fmt.Fprintf(&sb, "\n## Notes\n\n- Significance level α = %g.\n", c.Alpha)
+	for _, n := range c.Notes {
+		fmt.Fprintf(&sb, "- %s\n", mdEscape(n))
+	}
+	return sb.String()
+}
+
+// writeDeltaTable 输出指标表格，显著变化加粗
+func writeDeltaTable(sb *strings.Builder, deltas []Delta) {
+	sb.WriteString("| Metric | Base | Target | Change | p-value | Note |\n|---|---:|---:|---:|---:|---|\n")
+	for _, d := range deltas {
+		change := fmt.Sprintf("%+.4g", d.Change)
+		if d.ChangePct != nil {
+			change += fmt.Sprintf(" (%+.1f%%)", *d.ChangePct)
+		}
+		if d.Significant {
+			change = "**" + change + "**"
+		}
+		p := "–"
+		if d.PValue != nil {
+			p = fmt.Sprintf("%.3g", *d.PValue)
+		}
+		fmt.Fprintf(sb, "| %s | %.4g | %.4g | %s | %s | %s |\n", d.Metric, d.Base, d.Target, change, p, mdEscape(d.Note))
+	}
+}
+
+// mdEscape 转义表格中的竖线
+func mdEscape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
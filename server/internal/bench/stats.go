@@ -0,0 +1,190 @@
+package bench
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+const (
+	// alpha 显著性水平
+	alpha = 0.05
+	// maxReservoir 每个路由保留的延迟样本数，用于分位数
+	maxReservoir = 2000
+)
+
+// Stat 一组观测值的描述统计
+type Stat struct {
+	N      int     `json:"n"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stdDev"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+}
+
+// variance 样本方差
+func (s Stat) variance() float64 {
+	return s.StdDev * s.StdDev
+}
+
+// accumulator 增量计算均值和方差（Welford 算法）
+type accumulator struct {
+	n        int
+	mean, m2 float64
+	min, max float64
+}
+
+// add 加入一个观测值
+func (a *accumulator) add(x float64) {
+	a.n++
+	if a.n == 1 {
+		a.min, a.max = x, x
+	} else {
+		a.min = min(a.min, x)
+		a.max = max(a.max, x)
+	}
+	d := x - a.mean
+	a.mean += d / float64(a.n)
+	a.m2 += d * (x - a.mean)
+}
+
+// stat 返回描述统计
+func (a *accumulator) stat() Stat {
+	s := Stat{N: a.n, Mean: a.mean, Min: a.min, Max: a.max}
+	if a.n > 1 {
+		s.StdDev = math.Sqrt(a.m2 / float64(a.n-1))
+	}
+	return s
+}
+
+// reservoir 固定容量的均匀抽样
+type reservoir struct {
+	seen    int
+	samples []float64
+}
+
+// add 加入一个观测值
+func (r *reservoir) add(x float64) {
+	r.seen++
+	if len(r.samples) < maxReservoir {
+		r.samples = append(r.samples, x)
+	} else if i := rand.IntN(r.seen); i < maxReservoir {
+		r.samples[i] = x
+	}
+}
+
+// quantiles 返回 p50、p95、p99
+func (r *reservoir) quantiles() (p50, p95, p99 float64) {
+	sorted := slices.Clone(r.samples)
+	slices.Sort(sorted)
+	return quantile(sorted, 0.50), quantile(sorted, 0.95), quantile(sorted, 0.99)
+}
+
+// quantile 返回已排序数据的 q 分位数（最近秩）
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+// welchTest 两组均值差异的 Welch t 检验，返回双侧 p 值；样本不足时 ok 为 false
+func welchTest(a, b Stat) (p float64, ok bool) {
+	if a.N < 2 || b.N < 2 {
+		return 0, false
+	}
+	va, vb := a.variance()/float64(a.N), b.variance()/float64(b.N)
+	se := va + vb
+	if se == 0 {
+		// 两组都没有波动：均值相同则无差异，否则差异确定
+		if a.Mean == b.Mean {
+			return 1, true
+		}
+		return 0, true
+	}
+	t := (b.Mean - a.Mean) / math.Sqrt(se)
+	df := se * se / (va*va/float64(a.N-1) + vb*vb/float64(b.N-1))
+	return studentTwoSided(t, df), true
+}
+
+// rateTest 比较两个泊松计数的速率（次数/秒），返回双侧 p 值（正态近似）
+func rateTest(c1 float64, t1 float64, c2 float64, t2 float64) (p float64, ok bool) {
+	if t1 <= 0 || t2 <= 0 || c1+c2 == 0 {
+		return 0, false
+	}
+	se := math.Sqrt(c1/(t1*t1) + c2/(t2*t2))
+	z := (c2/t2 - c1/t1) / se
+	return math.Erfc(math.Abs(z) / math.Sqrt2), true
+}
+
+// studentTwoSided 自由度 df 的 t 分布双侧尾概率 P(|T| ≥ |t|)
+func studentTwoSided(t, df float64) float64 {
+	return betaInc(df/2, 0.5, df/(df+t*t))
+}
+
+// betaInc 正则化不完全 Beta 函数 I_x(a, b)
+func betaInc(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	// 连分式在 x < (a+1)/(a+b+2) 时收敛快，否则用对称关系
+	if x < (a+1)/(a+b+2) {
+		return front * betaCF(a, b, x) / a
+	}
+	return 1 - front*betaCF(b, a, 1-x)/b
+}
+
+// betaCF 不完全 Beta 函数的连分式（修正 Lentz 法）
+func betaCF(a, b, x float64) float64 {
+	const (
+		maxIter = 200
+		eps     = 1e-14
+		tiny    = 1e-300
+	)
+	qab, qap, qam := a+b, a+1, a-1
+	c, d := 1.0, 1-qab*x/qap
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIter; m++ {
+		fm := float64(m)
+		m2 := 2 * fm
+		aa := fm * (b - fm) * x / ((qam + m2) * (a + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+		aa = -(a + fm) * (qab + fm) * x / ((a + m2) * (qap + m2))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < eps {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,120 @@
+package bench
+
+import (
+	"math"
+	"testing"
+)
+
+// statOf 计算 xs 的描述统计
+func statOf(xs ...float64) Stat {
+	var a accumulator
+	for _, x := range xs {
+		a.add(x)
+	}
+	return a.stat()
+}
+
+func TestStudentTwoSided(t *testing.T) {
+	tests := []struct {
+		t, df, want float64
+	}{
+		{0, 10, 1},
+		{1, 1, 0.5},                        // Cauchy：1 - 2/π·atan(1)
+		{3, 1, 1 - 2/math.Pi*math.Atan(3)}, // Cauchy
+		{1, 2, 1 - 1/math.Sqrt(3)},         // df=2：1 - t/√(2+t²)
+		{-2, 2, 1 - 2/math.Sqrt(6)},        // 对称
+		{12.706205, 1, 0.05},               // t 分布临界值表
+		{2.570582, 5, 0.05},
+		{2.228139, 10, 0.05},
+		{3.169273, 10, 0.01},
+		{2.059539, 25, 0.05},
+		{1.959964, 1e7, 0.05}, // 大自由度趋近正态
+		{2.575829, 1e7, 0.01},
+	}
+	for _, tt := range tests {
+		if got := studentTwoSided(tt.t, tt.df); math.Abs(got-tt.want) > 1e-5 {
+			t.Errorf("studentTwoSided(%v, %v) = %.7f, want %.7f", tt.t, tt.df, got, tt.want)
+		}
+	}
+}
+
+func TestWelchTest(t *testing.T) {
+	// Welch (1947) 的经典示例数据（Wikipedia "Welch's t-test" 示例 1）：t ≈ 2.46，df ≈ 25.0，p ≈ 0.021
+	a := statOf(27.5, 21.0, 19.0, 23.6, 17.0, 17.9, 16.9, 20.1, 21.9, 22.6, 23.1, 19.6, 19.0, 21.7, 21.4)
+	b := statOf(27.1, 22.0, 20.8, 23.4, 23.4, 23.5, 25.8, 22.0, 24.8, 20.2, 21.9, 22.1, 22.9, 20.5, 24.4)
+	p, ok := welchTest(a, b)
+	if !ok || math.Abs(p-0.021) > 1e-3 {
+		t.Errorf("welchTest = %.4f, %v; want ≈0.021", p, ok)
+	}
+	if q, _ := welchTest(b, a); q != p {
+		t.Errorf("welchTest not symmetric: %v vs %v", q, p)
+	}
+
+	// 等样本量等方差时 Welch df = 2(n-1)：均值差 1、标准差 1、n=10 → t = √5，df = 18
+	x := statOf(0, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+	y := statOf(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	if p, _ := welchTest(x, y); math.Abs(p-studentTwoSided(1/math.Sqrt(2*x.variance()/10), 18)) > 1e-12 {
+		t.Errorf("equal variance welchTest = %v", p)
+	}
+
+	edges := []struct {
+		name   string
+		a, b   Stat
+		want   float64
+		wantOK bool
+	}{
+		{"too few samples", statOf(1), statOf(1, 2, 3), 0, false},
+		{"empty", Stat{}, statOf(1, 2), 0, false},
+		{"constant and equal", statOf(5, 5, 5), statOf(5, 5), 1, true},
+		{"constant and different", statOf(5, 5, 5), statOf(6, 6), 0, true},
+		{"identical", statOf(1, 2, 3), statOf(1, 2, 3), 1, true},
+	}
+	for _, tt := range edges {
+		p, ok := welchTest(tt.a, tt.b)
+		if ok != tt.wantOK || math.Abs(p-tt.want) > 1e-12 {
+			t.Errorf("%s: welchTest = %v, %v; want %v, %v", tt.name, p, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestRateTest(t *testing.T) {
+	tests := []struct {
+		name           string
+		c1, t1, c2, t2 float64
+		want           float64
+		wantOK         bool
+	}{
+		{"same rate", 100, 10, 100, 10, 1, true},
+		{"same rate, different durations", 200, 2, 100, 1, 1, true},
+		{"z = 3", 0, 1, 9, 1, 0.0026998, true},   // se = 3
+		{"z = -4", 16, 1, 0, 1, 0.0000633, true}, // se = 4
+		{"no events", 0, 1, 0, 1, 0, false},
+		{"zero duration", 10, 0, 10, 1, 0, false},
+	}
+	for _, tt := range tests {
+		p, ok := rateTest(tt.c1, tt.t1, tt.c2, tt.t2)
+		if ok != tt.wantOK || math.Abs(p-tt.want) > 1e-7 {
+			t.Errorf("%s: rateTest = %v, %v; want %v, %v", tt.name, p, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestAccumulatorAndQuantile(t *testing.T) {
+	s := statOf(2, 4, 4, 4, 5, 5, 7, 9)
+	if s.N != 8 || s.Mean != 5 || s.Min != 2 || s.Max != 9 || math.Abs(s.StdDev-math.Sqrt(32.0/7)) > 1e-12 {
+		t.Errorf("stat = %+v", s)
+	}
+	if s := statOf(3); s.StdDev != 0 || s.Mean != 3 {
+		t.Errorf("single observation stat = %+v", s)
+	}
+
+	sorted := []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	for _, tt := range []struct{ q, want float64 }{{0, 1}, {0.5, 5}, {0.95, 10}, {0.1, 1}, {0.11, 2}, {1, 10}} {
+		if got := quantile(sorted, tt.q); got != tt.want {
+			t.Errorf("quantile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+	if got := quantile(nil, 0.5); got != 0 {
+		t.Errorf("quantile(nil) = %v", got)
+	}
+}
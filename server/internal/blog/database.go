@@ -2,39 +2,55 @@ package blog
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
+	"time"
+
+	"analyseGo/internal/logging"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 var db *gorm.DB
 
+// Config 数据库配置
+type Config struct {
+	DSN                string        // MySQL DSN
+	SlowQueryThreshold time.Duration // 慢查询阈值，<= 0 时使用默认值
+	MaxOpenConns       int           // 最大打开连接数，0 表示不限制
+	MaxIdleConns       int           // 最大空闲连接数，0 时使用 database/sql 默认值
+}
+
 // InitDB 初始化数据库连接
-func InitDB() error {
-	// 从环境变量获取数据库配置，如果没有则使用默认值
-	dsn := os.Getenv("DB_DSN")
-	if dsn == "" {
-		dsn = "root:123456789@tcp(localhost:3306)/blog?charset=utf8mb4&parseTime=True&loc=Local"
+func InitDB(cfg Config) error {
+	if cfg.SlowQueryThreshold <= 0 {
+		cfg.SlowQueryThreshold = defaultSlowQueryThreshold
 	}
 
 	var err error
-	db, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+	db, err = gorm.Open(mysql.Open(cfg.DSN), &gorm.Config{
+		Logger: newGormLogger(logging.Logger(logging.ComponentGorm), cfg.SlowQueryThreshold),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to connect database: %w", err)
 	}
 
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get sql.DB: %w", err)
+	}
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+
 	// 自动迁移
 	err = db.AutoMigrate(&Post{}, &Category{}, &Tag{})
 	if err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	log.Println("Database connected and migrated successfully")
+	slog.Info("Database connected and migrated successfully")
 	return nil
 }
 
@@ -42,3 +58,15 @@ func InitDB() error {
 func GetDB() *gorm.DB {
 	return db
 }
+
+// CloseDB 关闭数据库连接池，未初始化时直接返回
+func CloseDB() error {
+	if db == nil {
+		return nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
@@ -4,17 +4,32 @@ import (
 	"net/http"
 	"strconv"
 
+	"analyseGo/internal/auth"
+	"analyseGo/internal/ginutil"
+
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+// dbWithRequest 返回绑定当前请求上下文的数据库会话，SQL 日志中会带上请求 ID
+func dbWithRequest(c *gin.Context) *gorm.DB {
+	return GetDB().WithContext(c.Request.Context())
+}
+
+// canSeeDrafts 编辑及以上角色可查看草稿，其余调用方只能看到已发布的文章
+func canSeeDrafts(c *gin.Context) bool {
+	return auth.HasRole(c.Request.Context(), auth.RoleEditor)
+}
+
 // GetPosts 获取文章列表
 func GetPosts(c *gin.Context) {
 	var posts []Post
-	query := GetDB().Preload("Category").Preload("Tags")
+	query := dbWithRequest(c).Preload("Category").Preload("Tags")
 
 	// 状态筛选
-	if status := c.Query("status"); status != "" {
+	if !canSeeDrafts(c) {
+		query = query.Where("status = ?", "published")
+	} else if status := c.Query("status"); status != "" {
 		query = query.Where("status = ?", status)
 	}
 
@@ -49,17 +64,21 @@ func GetPosts(c *gin.Context) {
 func GetPost(c *gin.Context) {
 	id := c.Param("id")
 	var post Post
-	if err := GetDB().Preload("Category").Preload("Tags").First(&post, id).Error; err != nil {
+	query := dbWithRequest(c).Preload("Category").Preload("Tags")
+	if !canSeeDrafts(c) {
+		query = query.Where("status = ?", "published")
+	}
+	if err := query.First(&post, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+			ginutil.Error(c, http.StatusNotFound, "Post not found")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ginutil.Error(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	// 增加浏览量
-	GetDB().Model(&post).Update("views", gorm.Expr("views + ?", 1))
+	dbWithRequest(c).Model(&post).Update("views", gorm.Expr("views + ?", 1))
 
 	c.JSON(http.StatusOK, post)
 }
@@ -68,7 +87,7 @@ func GetPost(c *gin.Context) {
 func CreatePost(c *gin.Context) {
 	var req PostCreateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ginutil.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -89,16 +108,16 @@ func CreatePost(c *gin.Context) {
 	// 关联标签
 	if len(req.TagIDs) > 0 {
 		var tags []Tag
-		GetDB().Where("id IN ?", req.TagIDs).Find(&tags)
+		dbWithRequest(c).Where("id IN ?", req.TagIDs).Find(&tags)
 		post.Tags = tags
 	}
 
-	if err := GetDB().Create(&post).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := dbWithRequest(c).Create(&post).Error; err != nil {
+		ginutil.Error(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	GetDB().Preload("Category").Preload("Tags").First(&post, post.ID)
+	dbWithRequest(c).Preload("Category").Preload("Tags").First(&post, post.ID)
 	c.JSON(http.StatusOK, post)
 }
 
@@ -106,18 +125,18 @@ func CreatePost(c *gin.Context) {
 func UpdatePost(c *gin.Context) {
 	id := c.Param("id")
 	var post Post
-	if err := GetDB().First(&post, id).Error; err != nil {
+	if err := dbWithRequest(c).First(&post, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Post not found"})
+			ginutil.Error(c, http.StatusNotFound, "Post not found")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		ginutil.Error(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
 	var req PostUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ginutil.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -147,24 +166,24 @@ func UpdatePost(c *gin.Context) {
 	// 更新标签
 	if req.TagIDs != nil {
 		var tags []Tag
-		GetDB().Where("id IN ?", req.TagIDs).Find(&tags)
-		GetDB().Model(&post).Association("Tags").Replace(tags)
+		dbWithRequest(c).Where("id IN ?", req.TagIDs).Find(&tags)
+		dbWithRequest(c).Model(&post).Association("Tags").Replace(tags)
 	}
 
-	if err := GetDB().Save(&post).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := dbWithRequest(c).Save(&post).Error; err != nil {
+		ginutil.Error(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	GetDB().Preload("Category").Preload("Tags").First(&post, post.ID)
+	dbWithRequest(c).Preload("Category").Preload("Tags").First(&post, post.ID)
 	c.JSON(http.StatusOK, post)
 }
 
 // DeletePost 删除文章
 func DeletePost(c *gin.Context) {
 	id := c.Param("id")
-	if err := GetDB().Delete(&Post{}, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := dbWithRequest(c).Delete(&Post{}, id).Error; err != nil {
+		ginutil.Error(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Post deleted successfully"})
@@ -173,7 +192,7 @@ func DeletePost(c *gin.Context) {
 // GetCategories 获取分类列表
 func GetCategories(c *gin.Context) {
 	var categories []Category
-	GetDB().Find(&categories)
+	dbWithRequest(c).Find(&categories)
 	c.JSON(http.StatusOK, categories)
 }
 
@@ -181,7 +200,7 @@ func GetCategories(c *gin.Context) {
 func CreateCategory(c *gin.Context) {
 	var req CategoryCreateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ginutil.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -190,8 +209,8 @@ func CreateCategory(c *gin.Context) {
 		Slug: req.Slug,
 	}
 
-	if err := GetDB().Create(&category).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := dbWithRequest(c).Create(&category).Error; err != nil {
+		ginutil.Error(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -201,7 +220,7 @@ func CreateCategory(c *gin.Context) {
 // GetTags 获取标签列表
 func GetTags(c *gin.Context) {
 	var tags []Tag
-	GetDB().Find(&tags)
+	dbWithRequest(c).Find(&tags)
 	c.JSON(http.StatusOK, tags)
 }
 
@@ -209,7 +228,7 @@ func GetTags(c *gin.Context) {
 func CreateTag(c *gin.Context) {
 	var req TagCreateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		ginutil.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -218,8 +237,8 @@ func CreateTag(c *gin.Context) {
 		Slug: req.Slug,
 	}
 
-	if err := GetDB().Create(&tag).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := dbWithRequest(c).Create(&tag).Error; err != nil {
+		ginutil.Error(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
@@ -0,0 +1,94 @@
+package blog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/utils"
+)
+
+// defaultSlowQueryThreshold 默认慢查询阈值
+const defaultSlowQueryThreshold = 200 * time.Millisecond
+
+// gormLogger 把 gorm 日志转为 slog 结构化日志
+// 普通 SQL 记为 Debug，慢查询记为 Warn，执行出错记为 Error；
+// 配合 db.WithContext(ctx) 使用时日志会带上请求 ID
+type gormLogger struct {
+	logger        *slog.Logger
+	level         logger.LogLevel
+	slowThreshold time.Duration
+}
+
+// newGormLogger 创建 gorm 日志适配器，slowThreshold <= 0 时不单独标记慢查询
+func newGormLogger(l *slog.Logger, slowThreshold time.Duration) logger.Interface {
+	return &gormLogger{logger: l, level: logger.Info, slowThreshold: slowThreshold}
+}
+
+// LogMode 设置 gorm 侧的日志级别
+func (l *gormLogger) LogMode(level logger.LogLevel) logger.Interface {
+	nl := *l
+	nl.level = level
+	return &nl
+}
+
+// Info 输出 Info 级别日志
+func (l *gormLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Info {
+		l.logger.InfoContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+// Warn 输出 Warn 级别日志
+func (l *gormLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Warn {
+		l.logger.WarnContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+// Error 输出 Error 级别日志
+func (l *gormLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Error {
+		l.logger.ErrorContext(ctx, fmt.Sprintf(msg, data...))
+	}
+}
+
+// Trace 输出 SQL 执行日志
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	level, msg := slog.LevelDebug, "sql"
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound) && l.level >= logger.Error:
+		level, msg = slog.LevelError, "sql error"
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= logger.Warn:
+		level, msg = slog.LevelWarn, "slow query"
+	case l.level < logger.Info:
+		return
+	}
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Float64("elapsed_ms", float64(elapsed)/float64(time.Millisecond)),
+		slog.String("caller", utils.FileWithLineNum()),
+	}
+	if level == slog.LevelError {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	if level == slog.LevelWarn {
+		attrs = append(attrs, slog.Float64("threshold_ms", float64(l.slowThreshold)/float64(time.Millisecond)))
+	}
+	l.logger.LogAttrs(ctx, level, msg, attrs...)
+}
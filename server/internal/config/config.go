@@ -0,0 +1,639 @@
+// Package config 分层加载服务配置
+//
+// 优先级从低到高：内置默认值 < 配置文件（YAML/TOML）< 环境变量 < 命令行参数。
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// envPrefix 环境变量前缀，例如 ANALYSEGO_SERVER_ADDR
+const envPrefix = "ANALYSEGO_"
+
+// 运行模式
+const (
+	ModeStandalone = "standalone" // 单进程，只看本进程指标
+	ModeAgent      = "agent"      // 单进程，同时把指标推送到 Collector
+	ModeCollector  = "collector"  // 接收多个 Agent 的推送并提供聚合视图
+)
+
+// reSLOName 合法的 SLO 名称
+var reSLOName = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// reSHA256 SHA-256 摘要的十六进制形式
+var reSHA256 = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// validRole 是否为 reader、editor 或 admin
+func validRole(role string) bool {
+	return role == "reader" || role == "editor" || role == "admin"
+}
+
+// Duration 支持 "1s"、"500ms" 形式的时长配置
+type Duration time.Duration
+
+// UnmarshalText 解析 time.ParseDuration 格式
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := time.ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalText 输出 time.Duration 格式
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Std 转为 time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// StdDurations 转为 []time.Duration
+func StdDurations(ds []Duration) []time.Duration {
+	out := make([]time.Duration, len(ds))
+	for i, d := range ds {
+		out[i] = d.Std()
+	}
+	return out
+}
+
+// Config 服务配置
+type Config struct {
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Database DatabaseConfig `yaml:"database" toml:"database"`
+	Metrics  MetricsConfig  `yaml:"metrics" toml:"metrics"`
+	Cluster  ClusterConfig  `yaml:"cluster" toml:"cluster"`
+	OTLP     OTLPConfig     `yaml:"otlp" toml:"otlp"`
+	Jobs     JobsConfig     `yaml:"jobs" toml:"jobs"`
+	SLO      SLOConfig      `yaml:"slo" toml:"slo"`
+	Auth     AuthConfig     `yaml:"auth" toml:"auth"`
+	Log      LogConfig      `yaml:"log" toml:"log"`
+}
+
+// ServerConfig HTTP 服务配置
+type ServerConfig struct {
+	Addr             string   `yaml:"addr" toml:"addr" env:"SERVER_ADDR" flag:"addr" usage:"监听地址"`
+	DefaultWindowSec int      `yaml:"defaultWindowSec" toml:"defaultWindowSec" env:"SERVER_DEFAULT_WINDOW_SEC" flag:"default-window" usage:"历史查询默认窗口（秒）"`
+	MaxWindowSec     int      `yaml:"maxWindowSec" toml:"maxWindowSec" env:"SERVER_MAX_WINDOW_SEC" flag:"max-window" usage:"历史查询最大窗口（秒）"`
+	DrainDelay       Duration `yaml:"drainDelay" toml:"drainDelay" env:"SERVER_DRAIN_DELAY" flag:"drain-delay" usage:"收到退出信号后继续服务的时长，期间 /api/ready 返回 503"`
+	ShutdownTimeout  Duration `yaml:"shutdownTimeout" toml:"shutdownTimeout" env:"SERVER_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"等待进行中请求结束的最长时间"`
+	CORSOrigins      []string `yaml:"corsOrigins" toml:"corsOrigins" env:"SERVER_CORS_ORIGINS" flag:"cors-origins" usage:"允许跨域访问的来源，逗号分隔，* 表示任意来源；WebSocket 只接受同源和显式列出的来源"`
+	TrustedProxies   []string `yaml:"trustedProxies" toml:"trustedProxies" env:"SERVER_TRUSTED_PROXIES" flag:"trusted-proxies" usage:"受信任的反向代理 IP 或 CIDR，逗号分隔；只有来自这些地址的请求才采用 X-Forwarded-For / X-Real-IP 作为客户端 IP"`
+}
+
+// DatabaseConfig 数据库配置
+type DatabaseConfig struct {
+	DSN                string   `yaml:"dsn" toml:"dsn" env:"DATABASE_DSN" flag:"dsn" usage:"MySQL DSN"`
+	SlowQueryThreshold Duration `yaml:"slowQueryThreshold" toml:"slowQueryThreshold" env:"DATABASE_SLOW_QUERY_THRESHOLD" flag:"slow-query" usage:"慢查询阈值"`
+	MaxOpenConns       int      `yaml:"maxOpenConns" toml:"maxOpenConns" env:"DATABASE_MAX_OPEN_CONNS" usage:"最大打开连接数，0 表示不限制"`
+	MaxIdleConns       int      `yaml:"maxIdleConns" toml:"maxIdleConns" env:"DATABASE_MAX_IDLE_CONNS" usage:"最大空闲连接数"`
+}
+
+// MetricsConfig 采样与保留配置
+type MetricsConfig struct {
+	SampleInterval Duration      `yaml:"sampleInterval" toml:"sampleInterval" env:"METRICS_SAMPLE_INTERVAL" flag:"sample-interval" usage:"采样间隔"`
+	MaxHistory     int           `yaml:"maxHistory" toml:"maxHistory" env:"METRICS_MAX_HISTORY" flag:"max-history" usage:"最多保留的历史样本数"`
+	RequestWindow  Duration      `yaml:"requestWindow" toml:"requestWindow" env:"METRICS_REQUEST_WINDOW" flag:"request-window" usage:"请求数统计窗口"`
+	RateWindows    []Duration    `yaml:"rateWindows" toml:"rateWindows" env:"METRICS_RATE_WINDOWS" flag:"rate-windows" usage:"QPS 统计窗口，逗号分隔"`
+	RequestLogSize int           `yaml:"requestLogSize" toml:"requestLogSize" env:"METRICS_REQUEST_LOG_SIZE" usage:"请求日志容量"`
+	SlowLogSize    int           `yaml:"slowLogSize" toml:"slowLogSize" env:"METRICS_SLOW_LOG_SIZE" usage:"慢请求记录容量"`
+	SlowThreshold  Duration      `yaml:"slowThreshold" toml:"slowThreshold" env:"METRICS_SLOW_THRESHOLD" flag:"slow-threshold" usage:"默认慢请求阈值"`
+	FlushPath      string        `yaml:"flushPath" toml:"flushPath" env:"METRICS_FLUSH_PATH" flag:"flush-path" usage:"关闭时把历史样本写入该 JSON 文件，SLO 计数写入同目录的 .slo 文件并在启动时读回；为空时都不写，SLO 30 天窗口只覆盖本次运行"`
+	Routes         RoutesConfig  `yaml:"routes" toml:"routes"`
+	Anomaly        AnomalyConfig `yaml:"anomaly" toml:"anomaly"`
+}
+
+// AnomalyConfig 样本与路由序列的异常检测（EWMA z 分数）
+type AnomalyConfig struct {
+	Enabled   bool    `yaml:"enabled" toml:"enabled" env:"METRICS_ANOMALY_ENABLED" flag:"anomaly" usage:"启用异常检测"`
+	Alpha     float64 `yaml:"alpha" toml:"alpha" env:"METRICS_ANOMALY_ALPHA" usage:"EWMA 平滑系数 (0,1]"`
+	Threshold float64 `yaml:"threshold" toml:"threshold" env:"METRICS_ANOMALY_THRESHOLD" flag:"anomaly-threshold" usage:"判定为异常的 |z| 阈值"`
+	Warmup    int     `yaml:"warmup" toml:"warmup" env:"METRICS_ANOMALY_WARMUP" usage:"开始判定前每个序列需要的样本数"`
+}
+
+// RoutesConfig 路由名归一化与基数限制
+type RoutesConfig struct {
+	MaxRoutes   int            `yaml:"maxRoutes" toml:"maxRoutes" env:"METRICS_ROUTES_MAX" flag:"max-routes" usage:"最多跟踪的路由数"`
+	IdleTTL     Duration       `yaml:"idleTTL" toml:"idleTTL" env:"METRICS_ROUTES_IDLE_TTL" flag:"route-idle-ttl" usage:"空闲路由淘汰时间"`
+	CollapseIDs bool           `yaml:"collapseIDs" toml:"collapseIDs" env:"METRICS_ROUTES_COLLAPSE_IDS" flag:"collapse-route-ids" usage:"把数字、UUID 路径段折叠为 :id"`
+	Rewrites    []RouteRewrite `yaml:"rewrites" toml:"rewrites"`
+}
+
+// RouteRewrite 路由名正则改写规则
+type RouteRewrite struct {
+	Pattern string `yaml:"pattern" toml:"pattern"` // Go 正则表达式
+	Replace string `yaml:"replace" toml:"replace"` // 替换文本，支持 $1 分组引用
+}
+
+// ClusterConfig 多实例汇聚配置
+type ClusterConfig struct {
+	Mode         string   `yaml:"mode" toml:"mode" env:"CLUSTER_MODE" flag:"mode" usage:"运行模式：standalone / agent / collector"`
+	CollectorURL string   `yaml:"collectorUrl" toml:"collectorUrl" env:"CLUSTER_COLLECTOR_URL" flag:"collector" usage:"agent 模式下的 Collector 推送地址"`
+	Instance     string   `yaml:"instance" toml:"instance" env:"CLUSTER_INSTANCE" flag:"instance" usage:"agent 模式下的实例标识，默认 主机名+监听地址"`
+	PushInterval Duration `yaml:"pushInterval" toml:"pushInterval" env:"CLUSTER_PUSH_INTERVAL" flag:"push-interval" usage:"agent 模式下的推送间隔"`
+	Token        string   `yaml:"token" toml:"token" env:"CLUSTER_TOKEN" usage:"agent 模式下推送时携带的 API token，Collector 启用认证时需要 admin 角色"`
+}
+
+// OTLPConfig OTLP 导出配置
+type OTLPConfig struct {
+	Endpoint    string   `yaml:"endpoint" toml:"endpoint" env:"OTLP_ENDPOINT" flag:"otlp-endpoint" usage:"OTLP/HTTP 导出地址，例如 http://localhost:4318，为空时不导出"`
+	ServiceName string   `yaml:"serviceName" toml:"serviceName" env:"OTLP_SERVICE_NAME" flag:"otlp-service" usage:"OTLP 资源属性 service.name"`
+	Interval    Duration `yaml:"interval" toml:"interval" env:"OTLP_INTERVAL" usage:"OTLP 导出间隔"`
+	// Headers 可能含鉴权信息，只从配置文件和环境变量读取，不提供命令行参数
+	Headers map[string]string `yaml:"headers" toml:"headers" env:"OTLP_HEADERS" usage:"OTLP 导出请求携带的额外请求头，环境变量格式为 key=value,key2=value2"`
+}
+
+// JobsConfig 模拟负载任务配置
+type JobsConfig struct {
+	MaxGoroutines int      `yaml:"maxGoroutines" toml:"maxGoroutines" env:"JOBS_MAX_GOROUTINES" flag:"job-goroutines" usage:"所有模拟负载任务和压测的 goroutine 总预算"`
+	MaxMemoryMB   int      `yaml:"maxMemoryMB" toml:"maxMemoryMB" env:"JOBS_MAX_MEMORY_MB" usage:"所有模拟负载任务保留内存的总预算（MB）"`
+	MaxDiskMB     int      `yaml:"maxDiskMB" toml:"maxDiskMB" env:"JOBS_MAX_DISK_MB" usage:"所有文件 IO 任务临时文件的总预算（MB）"`
+	MaxDuration   Duration `yaml:"maxDuration" toml:"maxDuration" env:"JOBS_MAX_DURATION" usage:"单个任务最长持续时间"`
+	FinishedTTL   Duration `yaml:"finishedTTL" toml:"finishedTTL" env:"JOBS_FINISHED_TTL" usage:"已结束任务在 /api/jobs 中的保留时间"`
+}
+
+// SLOConfig 按路由的 SLO 定义与告警评估
+type SLOConfig struct {
+	EvalInterval Duration       `yaml:"evalInterval" toml:"evalInterval" env:"SLO_EVAL_INTERVAL" usage:"燃烧率告警的评估间隔"`
+	Objectives   []SLOObjective `yaml:"objectives" toml:"objectives"`
+}
+
+// SLOObjective 一个 SLO，例如 /api/blog/posts 99% 的请求在 200ms 内完成
+type SLOObjective struct {
+	Name      string   `yaml:"name" toml:"name"`           // 唯一名称，用于 /api/slo/:name
+	Route     string   `yaml:"route" toml:"route"`         // 路由名，支持 path.Match 通配符
+	Method    string   `yaml:"method" toml:"method"`       // 请求方法，为空时匹配全部
+	Type      string   `yaml:"type" toml:"type"`           // latency：耗时不超过 threshold；availability：状态码小于 500
+	Target    float64  `yaml:"target" toml:"target"`       // 达标请求比例，如 0.99
+	Threshold Duration `yaml:"threshold" toml:"threshold"` // latency 类型的耗时阈值
+}
+
+// AuthConfig 认证配置
+// 未启用时所有接口对任何能连上的人开放，因此默认只监听回环地址，见 Config.ListenAddr；
+// 启用后除 ping、ready 和登录外都需要携带 token
+type AuthConfig struct {
+	Enabled    bool        `yaml:"enabled" toml:"enabled" env:"AUTH_ENABLED" flag:"auth" usage:"启用认证和基于角色的访问控制"`
+	Insecure   bool        `yaml:"insecure" toml:"insecure" env:"AUTH_INSECURE" flag:"insecure" usage:"未启用认证时仍按 server.addr 监听所有地址，任何能连上的人都有 admin 权限；默认只监听回环地址"`
+	Secret     string      `yaml:"secret" toml:"secret" env:"AUTH_SECRET" usage:"会话 token 签名密钥，至少 32 字节，为空时随机生成"`
+	SessionTTL Duration    `yaml:"sessionTTL" toml:"sessionTTL" env:"AUTH_SESSION_TTL" usage:"登录签发的会话 token 有效期"`
+	Users      []AuthUser  `yaml:"users" toml:"users"`
+	Tokens     []AuthToken `yaml:"tokens" toml:"tokens"`
+}
+
+// AuthUser 可登录的用户
+type AuthUser struct {
+	Username     string `yaml:"username" toml:"username"`
+	PasswordHash string `yaml:"passwordHash" toml:"passwordHash"` // bcrypt 哈希
+	Role         string `yaml:"role" toml:"role"`                 // reader / editor / admin
+}
+
+// AuthToken API token，只保存哈希
+type AuthToken struct {
+	Name string `yaml:"name" toml:"name"` // 调用方标识
+	Hash string `yaml:"hash" toml:"hash"` // token 的 SHA-256 十六进制
+	Role string `yaml:"role" toml:"role"` // reader / editor / admin
+}
+
+// LogConfig 日志配置
+type LogConfig struct {
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT" flag:"log-format" usage:"日志格式：json / text"`
+	Level  string `yaml:"level" toml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"日志级别：debug / info / warn / error"`
+}
+
+// Default 返回内置默认配置
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:             ":8099",
+			DefaultWindowSec: 600,   // 默认10分钟
+			MaxWindowSec:     86400, // 最大24小时
+			DrainDelay:       Duration(2 * time.Second),
+			ShutdownTimeout:  Duration(15 * time.Second),
+			CORSOrigins:      []string{"http://localhost:5173", "http://127.0.0.1:5173"}, // 前端开发服务器
+		},
+		Database: DatabaseConfig{
+			DSN:                "root@tcp(localhost:3306)/blog?charset=utf8mb4&parseTime=True&loc=Local",
+			SlowQueryThreshold: Duration(200 * time.Millisecond),
+		},
+		Metrics: MetricsConfig{
+			SampleInterval: Duration(time.Second),
+			MaxHistory:     86400, // 每秒一个样本时为24小时
+			RequestWindow:  Duration(10 * time.Second),
+			RateWindows:    []Duration{Duration(10 * time.Second), Duration(time.Minute), Duration(5 * time.Minute)},
+			RequestLogSize: 2000,
+			SlowLogSize:    100,
+			SlowThreshold:  Duration(time.Second),
+			Routes: RoutesConfig{
+				MaxRoutes: 500,
+				IdleTTL:   Duration(30 * time.Minute),
+			},
+			Anomaly: AnomalyConfig{
+				Enabled:   true,
+				Alpha:     0.05,
+				Threshold: 4,
+				Warmup:    30,
+			},
+		},
+		Cluster: ClusterConfig{
+			Mode:         ModeStandalone,
+			CollectorURL: "http://localhost:8099/api/collector/push",
+			PushInterval: Duration(5 * time.Second),
+		},
+		OTLP: OTLPConfig{
+			ServiceName: "analyseGo",
+			Interval:    Duration(5 * time.Second),
+		},
+		Jobs: JobsConfig{
+			MaxGoroutines: 10000,
+			MaxMemoryMB:   2048,
+			MaxDiskMB:     4096,
+			MaxDuration:   Duration(10 * time.Minute),
+			FinishedTTL:   Duration(10 * time.Minute),
+		},
+		SLO: SLOConfig{
+			EvalInterval: Duration(30 * time.Second),
+		},
+		Auth: AuthConfig{
+			SessionTTL: Duration(12 * time.Hour),
+		},
+		Log: LogConfig{
+			Format: "json",
+			Level:  "info",
+		},
+	}
+}
+
+// Load 按 默认值 < 配置文件 < 环境变量 < 命令行参数 的顺序加载配置并校验
+// 配置文件路径来自 -config 参数或 ANALYSEGO_CONFIG 环境变量，未指定时跳过
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("analyseGo", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv(envPrefix+"CONFIG"), "配置文件路径（.yaml/.yml/.toml）")
+	setters := registerFlags(fs, reflect.ValueOf(&cfg).Elem())
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configPath != "" {
+		if err := loadFile(*configPath, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// 兼容旧的 DB_DSN 环境变量
+	if dsn := os.Getenv("DB_DSN"); dsn != "" {
+		cfg.Database.DSN = dsn
+	}
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return nil, err
+	}
+
+	// 只应用显式传入的参数，未传入的参数不覆盖文件和环境变量
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		if set, ok := setters[f.Name]; ok && flagErr == nil {
+			if err := set(f.Value.String()); err != nil {
+				flagErr = fmt.Errorf("invalid -%s: %w", f.Name, err)
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate 校验配置，返回所有错误
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr is required")
+	check(c.Server.DefaultWindowSec > 0, "server.defaultWindowSec must be positive")
+	check(c.Server.MaxWindowSec >= c.Server.DefaultWindowSec, "server.maxWindowSec must be >= server.defaultWindowSec")
+	check(c.Server.DrainDelay >= 0, "server.drainDelay must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdownTimeout must be positive")
+	check(len(c.Server.CORSOrigins) > 0, "server.corsOrigins must not be empty")
+	if host, _, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		check(c.Server.Addr == "", "server.addr: %v", err)
+	} else if !c.Auth.Enabled && !c.Auth.Insecure {
+		check(wildcardHost(host) || loopbackHost(host),
+			"server.addr %q is not a loopback address: enable auth, or set auth.insecure to serve all endpoints unauthenticated", c.Server.Addr)
+	}
+	for _, p := range c.Server.TrustedProxies {
+		_, _, cidrErr := net.ParseCIDR(p)
+		check(cidrErr == nil || net.ParseIP(p) != nil, "server.trustedProxies: %q is not an IP or CIDR", p)
+	}
+
+	switch c.Cluster.Mode {
+	case ModeStandalone, ModeAgent:
+		check(c.Database.DSN != "", "database.dsn is required in %s mode", c.Cluster.Mode)
+	case ModeCollector:
+	default:
+		errs = append(errs, fmt.Errorf("cluster.mode must be one of standalone, agent, collector, got %q", c.Cluster.Mode))
+	}
+	if c.Cluster.Mode == ModeAgent {
+		check(c.Cluster.CollectorURL != "", "cluster.collectorUrl is required in agent mode")
+		check(c.Cluster.PushInterval.Std() >= 100*time.Millisecond, "cluster.pushInterval must be >= 100ms")
+	}
+	check(c.Database.SlowQueryThreshold >= 0, "database.slowQueryThreshold must not be negative")
+	check(c.Database.MaxOpenConns >= 0 && c.Database.MaxIdleConns >= 0, "database connection limits must not be negative")
+
+	check(c.Metrics.SampleInterval.Std() >= 100*time.Millisecond && c.Metrics.SampleInterval.Std() <= time.Minute,
+		"metrics.sampleInterval must be between 100ms and 1m")
+	check(c.Metrics.MaxHistory > 0 && c.Metrics.MaxHistory <= 10_000_000, "metrics.maxHistory must be between 1 and 10000000")
+	check(c.Metrics.RequestWindow.Std() >= time.Second && c.Metrics.RequestWindow.Std() <= time.Hour,
+		"metrics.requestWindow must be between 1s and 1h")
+	check(len(c.Metrics.RateWindows) > 0, "metrics.rateWindows must not be empty")
+	for _, w := range c.Metrics.RateWindows {
+		check(w.Std() >= time.Second && w.Std() <= time.Hour, "metrics.rateWindows entry %s must be between 1s and 1h", w.Std())
+	}
+	check(c.Metrics.RequestLogSize > 0, "metrics.requestLogSize must be positive")
+	check(c.Metrics.SlowLogSize > 0, "metrics.slowLogSize must be positive")
+	check(c.Metrics.SlowThreshold >= 0, "metrics.slowThreshold must not be negative")
+	check(c.Metrics.Routes.MaxRoutes > 0, "metrics.routes.maxRoutes must be positive")
+	check(c.Metrics.Routes.IdleTTL > 0, "metrics.routes.idleTTL must be positive")
+	for i, rw := range c.Metrics.Routes.Rewrites {
+		if _, err := regexp.Compile(rw.Pattern); err != nil {
+			errs = append(errs, fmt.Errorf("metrics.routes.rewrites[%d].pattern: %w", i, err))
+		}
+	}
+
+	check(c.Metrics.Anomaly.Alpha > 0 && c.Metrics.Anomaly.Alpha <= 1, "metrics.anomaly.alpha must be in (0, 1]")
+	check(c.Metrics.Anomaly.Threshold > 0, "metrics.anomaly.threshold must be positive")
+	check(c.Metrics.Anomaly.Warmup >= 2, "metrics.anomaly.warmup must be >= 2")
+
+	check(c.OTLP.Interval.Std() >= 100*time.Millisecond, "otlp.interval must be >= 100ms")
+	check(c.Jobs.MaxGoroutines > 0, "jobs.maxGoroutines must be positive")
+	check(c.Jobs.MaxMemoryMB > 0, "jobs.maxMemoryMB must be positive")
+	check(c.Jobs.MaxDiskMB > 0, "jobs.maxDiskMB must be positive")
+	check(c.Jobs.MaxDuration >= Duration(time.Second), "jobs.maxDuration must be >= 1s")
+	check(c.Jobs.FinishedTTL > 0, "jobs.finishedTTL must be positive")
+	check(c.SLO.EvalInterval >= Duration(time.Second), "slo.evalInterval must be >= 1s")
+	sloNames := make(map[string]bool)
+	for i, o := range c.SLO.Objectives {
+		check(reSLOName.MatchString(o.Name) && o.Name != "alerts", "slo.objectives[%d].name %q must match %s and not be \"alerts\"", i, o.Name, reSLOName)
+		check(!sloNames[o.Name], "slo.objectives[%d].name %q is duplicated", i, o.Name)
+		sloNames[o.Name] = true
+		_, err := path.Match(o.Route, "")
+		check(o.Route != "" && err == nil, "slo.objectives[%d].route must be a route name or path.Match pattern", i)
+		check(o.Target > 0 && o.Target < 1, "slo.objectives[%d].target must be in (0, 1)", i)
+		switch o.Type {
+		case "latency":
+			check(o.Threshold > 0, "slo.objectives[%d].threshold must be positive for latency objectives", i)
+		case "availability":
+		default:
+			errs = append(errs, fmt.Errorf("slo.objectives[%d].type must be latency or availability, got %q", i, o.Type))
+		}
+	}
+	check(c.Auth.Secret == "" || len(c.Auth.Secret) >= 32, "auth.secret must be at least 32 bytes")
+	check(c.Auth.SessionTTL >= Duration(time.Minute), "auth.sessionTTL must be >= 1m")
+	authNames := make(map[string]bool)
+	for i, u := range c.Auth.Users {
+		check(u.Username != "", "auth.users[%d].username is required", i)
+		check(!authNames[u.Username], "auth.users[%d].username %q is duplicated", i, u.Username)
+		authNames[u.Username] = true
+		check(strings.HasPrefix(u.PasswordHash, "$2"), "auth.users[%d].passwordHash must be a bcrypt hash", i)
+		check(validRole(u.Role), "auth.users[%d].role must be reader, editor or admin, got %q", i, u.Role)
+	}
+	for i, t := range c.Auth.Tokens {
+		check(t.Name != "", "auth.tokens[%d].name is required", i)
+		check(!authNames[t.Name], "auth.tokens[%d].name %q is duplicated", i, t.Name)
+		authNames[t.Name] = true
+		check(reSHA256.MatchString(t.Hash), "auth.tokens[%d].hash must be a hex SHA-256 digest", i)
+		check(validRole(t.Role), "auth.tokens[%d].role must be reader, editor or admin, got %q", i, t.Role)
+	}
+	check(!c.Auth.Enabled || len(c.Auth.Users)+len(c.Auth.Tokens) > 0, "auth.users or auth.tokens must not be empty when auth is enabled")
+
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format must be json or text")
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level must be one of debug, info, warn, error, got %q", c.Log.Level))
+	}
+
+	return errors.Join(errs...)
+}
+
+// ListenAddr 返回实际监听的地址
+// 未启用认证且未设置 auth.insecure 时，把未指定主机的地址（如 ":8099"）限制为 127.0.0.1
+func (c *Config) ListenAddr() string {
+	if c.Auth.Enabled || c.Auth.Insecure {
+		return c.Server.Addr
+	}
+	host, port, err := net.SplitHostPort(c.Server.Addr)
+	if err != nil || !wildcardHost(host) {
+		return c.Server.Addr
+	}
+	return net.JoinHostPort("127.0.0.1", port)
+}
+
+// wildcardHost 主机部分是否表示监听所有地址
+func wildcardHost(host string) bool {
+	ip := net.ParseIP(host)
+	return host == "" || ip != nil && ip.IsUnspecified()
+}
+
+// loopbackHost 主机部分是否为回环地址
+func loopbackHost(host string) bool {
+	ip := net.ParseIP(host)
+	return host == "localhost" || ip != nil && ip.IsLoopback()
+}
+
+// loadFile 按扩展名解析 YAML 或 TOML 配置文件，文件中未出现的字段保留原值
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalWithOptions(data, cfg, yaml.Strict())
+	case ".toml":
+		err = toml.NewDecoder(bytes.NewReader(data)).DisallowUnknownFields().Decode(cfg)
+	default:
+		return fmt.Errorf("unsupported config format: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv 用带 env 标签的环境变量覆盖配置
+func applyEnv(v reflect.Value) error {
+	var firstErr error
+	walkFields(v, func(f reflect.StructField, fv reflect.Value) {
+		name := f.Tag.Get("env")
+		if name == "" || firstErr != nil {
+			return
+		}
+		raw, ok := os.LookupEnv(envPrefix + name)
+		if !ok {
+			return
+		}
+		if err := setValue(fv, raw); err != nil {
+			firstErr = fmt.Errorf("invalid %s%s: %w", envPrefix, name, err)
+		}
+	})
+	return firstErr
+}
+
+// registerFlags 为带 flag 标签的字段注册命令行参数，返回参数名到赋值函数的映射
+// 按字段类型注册，bool 参数可省略值（-auth 即 -auth=true），-help 显示参数类型；
+// 解析结果不直接写入字段，由 Load 在文件和环境变量之后应用
+func registerFlags(fs *flag.FlagSet, v reflect.Value) map[string]func(string) error {
+	setters := make(map[string]func(string) error)
+	walkFields(v, func(f reflect.StructField, fv reflect.Value) {
+		name := f.Tag.Get("flag")
+		if name == "" {
+			return
+		}
+		usage := f.Tag.Get("usage")
+		switch def := fv.Interface().(type) {
+		case Duration:
+			fs.Duration(name, def.Std(), usage)
+		case bool:
+			fs.Bool(name, def, usage)
+		case int:
+			fs.Int(name, def, usage)
+		case float64:
+			fs.Float64(name, def, usage)
+		default:
+			fs.String(name, formatValue(fv), usage)
+		}
+		setters[name] = func(raw string) error { return setValue(fv, raw) }
+	})
+	return setters
+}
+
+// walkFields 遍历嵌套结构体的叶子字段
+func walkFields(v reflect.Value, fn func(reflect.StructField, reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		if f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(Duration(0)) {
+			walkFields(fv, fn)
+			continue
+		}
+		fn(f, fv)
+	}
+}
+
+// setValue 把字符串解析后写入字段
+func setValue(fv reflect.Value, raw string) error {
+	if d, ok := fv.Addr().Interface().(*Duration); ok {
+		return d.UnmarshalText([]byte(raw))
+	}
+	if ds, ok := fv.Addr().Interface().(*[]Duration); ok {
+		// 逗号分隔的列表
+		var out []Duration
+		for _, part := range strings.Split(raw, ",") {
+			var d Duration
+			if err := d.UnmarshalText([]byte(strings.TrimSpace(part))); err != nil {
+				return err
+			}
+			out = append(out, d)
+		}
+		*ds = out
+		return nil
+	}
+	if m, ok := fv.Addr().Interface().(*map[string]string); ok {
+		// 逗号分隔的 key=value 列表
+		out := make(map[string]string)
+		for _, part := range strings.Split(raw, ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			k, v, ok := strings.Cut(part, "=")
+			if k = strings.TrimSpace(k); !ok || k == "" {
+				return fmt.Errorf("invalid key=value pair %q", part)
+			}
+			out[k] = strings.TrimSpace(v)
+		}
+		*m = out
+		return nil
+	}
+	if ss, ok := fv.Addr().Interface().(*[]string); ok {
+		// 逗号分隔的列表
+		var out []string
+		for _, part := range strings.Split(raw, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				out = append(out, part)
+			}
+		}
+		*ss = out
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", fv.Type())
+	}
+	return nil
+}
+
+// formatValue 返回字段当前值的字符串形式，用作参数默认值展示
+func formatValue(fv reflect.Value) string {
+	if d, ok := fv.Interface().(Duration); ok {
+		return d.Std().String()
+	}
+	if ds, ok := fv.Interface().([]Duration); ok {
+		parts := make([]string, len(ds))
+		for i, d := range ds {
+			parts[i] = d.Std().String()
+		}
+		return strings.Join(parts, ",")
+	}
+	if ss, ok := fv.Interface().([]string); ok {
+		return strings.Join(ss, ",")
+	}
+	return fmt.Sprint(fv.Interface())
+}
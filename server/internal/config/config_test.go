@@ -0,0 +1,331 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile 在临时目录写入配置文件并返回路径
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	cfg := Default()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExampleConfigLoads(t *testing.T) {
+	if _, err := Load([]string{"-config", "../../config.example.yaml"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeFile(t, "c.yaml", `
+server:
+  addr: ":1001"
+  corsOrigins: ["http://file"]
+metrics:
+  sampleInterval: 2s
+  maxHistory: 100
+  requestWindow: 20s
+log:
+  level: debug
+`)
+	tomlFile := writeFile(t, "c.toml", `
+[server]
+addr = ":1001"
+corsOrigins = ["http://file"]
+
+[metrics]
+sampleInterval = "2s"
+maxHistory = 100
+requestWindow = "20s"
+
+[log]
+level = "debug"
+`)
+	for _, file := range []string{yamlFile, tomlFile} {
+		t.Run(filepath.Ext(file), func(t *testing.T) {
+			t.Setenv("ANALYSEGO_SERVER_ADDR", ":1002")
+			t.Setenv("ANALYSEGO_METRICS_MAX_HISTORY", "200")
+			t.Setenv("ANALYSEGO_LOG_LEVEL", "warn")
+
+			cfg, err := Load([]string{"-config", file, "-addr", ":1003", "-request-window", "30s"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			tests := []struct {
+				field     string
+				got, want any
+			}{
+				{"addr: flag > env > file", cfg.Server.Addr, ":1003"},
+				{"maxHistory: env > file", cfg.Metrics.MaxHistory, 200},
+				{"level: env > file", cfg.Log.Level, "warn"},
+				{"requestWindow: flag > file", cfg.Metrics.RequestWindow.Std(), 30 * time.Second},
+				{"sampleInterval: file > default", cfg.Metrics.SampleInterval.Std(), 2 * time.Second},
+				{"corsOrigins: file > default", cfg.Server.CORSOrigins, []string{"http://file"}},
+				{"slowLogSize: default", cfg.Metrics.SlowLogSize, 100},
+			}
+			for _, tt := range tests {
+				if !reflect.DeepEqual(tt.got, tt.want) {
+					t.Errorf("%s: got %v, want %v", tt.field, tt.got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadUnsetFlagsKeepEnv(t *testing.T) {
+	// 未传入的参数即使有默认值也不覆盖环境变量
+	t.Setenv("ANALYSEGO_METRICS_SAMPLE_INTERVAL", "5s")
+	t.Setenv("ANALYSEGO_METRICS_ANOMALY_ENABLED", "false")
+	cfg, err := Load([]string{"-addr", ":1003", "-collapse-route-ids"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Metrics.SampleInterval.Std() != 5*time.Second || cfg.Metrics.Anomaly.Enabled {
+		t.Errorf("env overridden by unset flags: %+v", cfg.Metrics)
+	}
+	if !cfg.Metrics.Routes.CollapseIDs {
+		t.Error("bool flag without value not applied")
+	}
+}
+
+func TestLoadConfigPathAndLegacyDSN(t *testing.T) {
+	file := writeFile(t, "c.yml", "database:\n  dsn: file-dsn\n")
+	t.Setenv("ANALYSEGO_CONFIG", file)
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.DSN != "file-dsn" {
+		t.Errorf("dsn = %q, want file-dsn from ANALYSEGO_CONFIG", cfg.Database.DSN)
+	}
+
+	// DB_DSN 覆盖文件，ANALYSEGO_DATABASE_DSN 覆盖 DB_DSN
+	t.Setenv("DB_DSN", "legacy-dsn")
+	if cfg, _ = Load(nil); cfg.Database.DSN != "legacy-dsn" {
+		t.Errorf("dsn = %q, want legacy-dsn", cfg.Database.DSN)
+	}
+	t.Setenv("ANALYSEGO_DATABASE_DSN", "env-dsn")
+	if cfg, _ = Load(nil); cfg.Database.DSN != "env-dsn" {
+		t.Errorf("dsn = %q, want env-dsn", cfg.Database.DSN)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want string
+	}{
+		{"unknown yaml field", []string{"-config", writeFile(t, "c.yaml", "server:\n  adr: x\n")}, nil, "failed to parse config"},
+		{"unknown toml field", []string{"-config", writeFile(t, "c.toml", "[server]\nadr = \"x\"\n")}, nil, "failed to parse config"},
+		{"unsupported format", []string{"-config", writeFile(t, "c.json", "{}")}, nil, "unsupported config format"},
+		{"missing file", []string{"-config", "/nonexistent/c.yaml"}, nil, "failed to read config"},
+		{"bad env duration", nil, map[string]string{"ANALYSEGO_METRICS_SAMPLE_INTERVAL": "soon"}, "invalid ANALYSEGO_METRICS_SAMPLE_INTERVAL"},
+		{"bad env int", nil, map[string]string{"ANALYSEGO_METRICS_MAX_HISTORY": "lots"}, "invalid ANALYSEGO_METRICS_MAX_HISTORY"},
+		{"bad env map", nil, map[string]string{"ANALYSEGO_OTLP_HEADERS": "novalue"}, "invalid ANALYSEGO_OTLP_HEADERS"},
+		{"bad flag", []string{"-max-history", "lots"}, nil, "max-history"},
+		{"unknown flag", []string{"-nope"}, nil, "not defined"},
+		{"invalid after layering", []string{"-mode", "cluster"}, nil, "cluster.mode must be one of"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := Load(tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want mention of %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetValue(t *testing.T) {
+	var target struct {
+		D   Duration
+		DS  []Duration
+		SS  []string
+		M   map[string]string
+		I   int
+		F   float64
+		B   bool
+		Str string
+	}
+	v := reflect.ValueOf(&target).Elem()
+	tests := []struct {
+		field, raw string
+		want       any
+	}{
+		{"D", "1m30s", Duration(90 * time.Second)},
+		{"DS", "10s, 1m,5m", []Duration{Duration(10 * time.Second), Duration(time.Minute), Duration(5 * time.Minute)}},
+		{"SS", "a, b,,c ", []string{"a", "b", "c"}},
+		{"SS", "", []string(nil)},
+		{"M", "Authorization=Bearer x=y, team = core,", map[string]string{"Authorization": "Bearer x=y", "team": "core"}},
+		{"I", "42", 42},
+		{"F", "0.25", 0.25},
+		{"B", "true", true},
+		{"Str", " kept as is ", " kept as is "},
+	}
+	for _, tt := range tests {
+		if err := setValue(v.FieldByName(tt.field), tt.raw); err != nil {
+			t.Errorf("%s=%q: %v", tt.field, tt.raw, err)
+			continue
+		}
+		if got := v.FieldByName(tt.field).Interface(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s=%q: got %#v, want %#v", tt.field, tt.raw, got, tt.want)
+		}
+	}
+
+	bad := []struct{ field, raw string }{
+		{"D", "10"},
+		{"DS", "10s,x"},
+		{"M", "=v"},
+		{"I", "1.5"},
+		{"F", "x"},
+		{"B", "yes please"},
+	}
+	for _, tt := range bad {
+		if err := setValue(v.FieldByName(tt.field), tt.raw); err == nil {
+			t.Errorf("%s=%q accepted", tt.field, tt.raw)
+		}
+	}
+}
+
+func TestTagsUnique(t *testing.T) {
+	// 每个叶子字段都应能从文件设置；env 和 flag 名不重复，默认值经 formatValue 和 setValue 往返不变
+	cfg := Default()
+	envs, flags := make(map[string]string), make(map[string]string)
+	walkFields(reflect.ValueOf(&cfg).Elem(), func(f reflect.StructField, fv reflect.Value) {
+		if f.Tag.Get("yaml") == "" || f.Tag.Get("toml") == "" {
+			t.Errorf("%s has no yaml or toml tag", f.Name)
+		}
+		if name := f.Tag.Get("env"); name != "" {
+			if prev, ok := envs[name]; ok {
+				t.Errorf("env %s used by %s and %s", name, prev, f.Name)
+			}
+			envs[name] = f.Name
+			// map 字段没有命令行参数，formatValue 不用于它们
+			if fv.Kind() != reflect.Map {
+				if err := setValue(fv, formatValue(fv)); err != nil {
+					t.Errorf("%s: current value does not round-trip: %v", f.Name, err)
+				}
+			}
+		}
+		if name := f.Tag.Get("flag"); name != "" {
+			if prev, ok := flags[name]; ok {
+				t.Errorf("flag -%s used by %s and %s", name, prev, f.Name)
+			}
+			flags[name] = f.Name
+		}
+	})
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Error("formatValue/setValue round trip changed the defaults")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	const hash = "$2a$10$abcdefghijklmnopqrstuv"
+	const digest = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   []string // 为空表示应通过
+	}{
+		{"collector needs no dsn", func(c *Config) { c.Cluster.Mode = ModeCollector; c.Database.DSN = "" }, nil},
+		{"standalone needs dsn", func(c *Config) { c.Database.DSN = "" }, []string{"database.dsn is required in standalone mode"}},
+		{"agent push interval", func(c *Config) { c.Cluster.Mode = ModeAgent; c.Cluster.PushInterval = Duration(time.Millisecond) }, []string{"cluster.pushInterval"}},
+		{"window order", func(c *Config) { c.Server.MaxWindowSec = 10 }, []string{"server.maxWindowSec must be >= server.defaultWindowSec"}},
+		{"trusted proxies", func(c *Config) { c.Server.TrustedProxies = []string{"10.0.0.0/8", "::1", "proxy.local"} }, []string{`"proxy.local" is not an IP or CIDR`}},
+		{"sample interval", func(c *Config) { c.Metrics.SampleInterval = Duration(time.Hour) }, []string{"metrics.sampleInterval"}},
+		{"rate window", func(c *Config) { c.Metrics.RateWindows = []Duration{Duration(time.Millisecond)} }, []string{"metrics.rateWindows entry 1ms"}},
+		{"rewrite regexp", func(c *Config) { c.Metrics.Routes.Rewrites = []RouteRewrite{{Pattern: "("}} }, []string{"metrics.routes.rewrites[0].pattern"}},
+		{"anomaly", func(c *Config) { c.Metrics.Anomaly.Alpha = 0; c.Metrics.Anomaly.Warmup = 1 }, []string{"metrics.anomaly.alpha", "metrics.anomaly.warmup"}},
+		{"slo", func(c *Config) {
+			c.SLO.Objectives = []SLOObjective{
+				{Name: "alerts", Route: "/a", Type: "availability", Target: 0.99},
+				{Name: "p", Route: "/a", Type: "latency", Target: 1},
+				{Name: "p", Route: "[", Type: "speed", Target: 0.9},
+			}
+		}, []string{`"alerts" must match`, "objectives[1].target", "objectives[1].threshold", `objectives[2].name "p" is duplicated`, "objectives[2].route", "objectives[2].type"}},
+		{"auth enabled without principals", func(c *Config) { c.Auth.Enabled = true }, []string{"auth.users or auth.tokens must not be empty"}},
+		{"auth principals", func(c *Config) {
+			c.Auth.Secret = "short"
+			c.Auth.Users = []AuthUser{{Username: "ci", PasswordHash: "plain", Role: "root"}}
+			c.Auth.Tokens = []AuthToken{{Name: "ci", Hash: "abc", Role: "reader"}}
+		}, []string{"auth.secret", "users[0].passwordHash", "users[0].role", `tokens[0].name "ci" is duplicated`, "tokens[0].hash"}},
+		{"auth valid", func(c *Config) {
+			c.Auth.Enabled = true
+			c.Auth.Users = []AuthUser{{Username: "alice", PasswordHash: hash, Role: "admin"}}
+			c.Auth.Tokens = []AuthToken{{Name: "ci", Hash: digest, Role: "reader"}}
+		}, nil},
+		{"auth off on public addr", func(c *Config) { c.Server.Addr = "10.0.0.5:8099" }, []string{`server.addr "10.0.0.5:8099" is not a loopback address`}},
+		{"auth off on loopback addr", func(c *Config) { c.Server.Addr = "localhost:8099" }, nil},
+		{"auth off insecure on public addr", func(c *Config) { c.Server.Addr = "10.0.0.5:8099"; c.Auth.Insecure = true }, nil},
+		{"addr without port", func(c *Config) { c.Server.Addr = "8099" }, []string{"server.addr: "}},
+		{"log", func(c *Config) { c.Log.Format = "xml"; c.Log.Level = "verbose" }, []string{"log.format", "log.level"}},
+		{"log level case", func(c *Config) { c.Log.Level = "DEBUG" }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("accepted")
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("error does not mention %q:\n%v", w, err)
+				}
+			}
+		})
+	}
+}
+
+func TestListenAddr(t *testing.T) {
+	tests := []struct {
+		addr              string
+		enabled, insecure bool
+		want              string
+	}{
+		{":8099", false, false, "127.0.0.1:8099"},
+		{"0.0.0.0:8099", false, false, "127.0.0.1:8099"},
+		{"[::]:8099", false, false, "127.0.0.1:8099"},
+		{"localhost:8099", false, false, "localhost:8099"},
+		{"[::1]:8099", false, false, "[::1]:8099"},
+		{":8099", true, false, ":8099"},
+		{":8099", false, true, ":8099"},
+		{"10.0.0.5:8099", true, false, "10.0.0.5:8099"},
+	}
+	for _, tt := range tests {
+		cfg := Default()
+		cfg.Server.Addr = tt.addr
+		cfg.Auth.Enabled, cfg.Auth.Insecure = tt.enabled, tt.insecure
+		if got := cfg.ListenAddr(); got != tt.want {
+			t.Errorf("ListenAddr(%q, enabled=%v, insecure=%v) = %q, want %q", tt.addr, tt.enabled, tt.insecure, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package ginutil
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AccessLogMiddleware 记录访问日志的中间件
+// 5xx 记为 Error，4xx 记为 Warn，其余记为 Info；注册在 RequestIDMiddleware 之后时日志带请求 ID，
+// 注册在 TrackingMiddleware 之前且启用 OTLP 导出时带追踪 ID
+func AccessLogMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		ctx := c.Request.Context()
+		if !logger.Enabled(ctx, level) {
+			return
+		}
+
+		size := c.Writer.Size()
+		if size < 0 {
+			size = 0
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", GetRoutePath(c)),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start))/float64(time.Millisecond)),
+			slog.Int("bytes", size),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate); len(errs) > 0 {
+			attrs = append(attrs, slog.String("error", errs.String()))
+		}
+		logger.LogAttrs(ctx, level, "request", attrs...)
+	}
+}
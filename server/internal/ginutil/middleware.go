@@ -3,21 +3,29 @@ package ginutil
 import (
 	"context"
 	"net/http"
-	"runtime"
-	"time"
+	"slices"
 
 	"analyseGo/internal/metrics"
-	pprof "runtime/pprof"
 
 	"github.com/gin-gonic/gin"
 )
 
 // CORSMiddleware 处理跨域请求的中间件
-func CORSMiddleware() gin.HandlerFunc {
+// origins 为允许的来源，包含 * 时允许任意来源；其余来源不返回 CORS 头，由浏览器拦截
+func CORSMiddleware(origins []string) gin.HandlerFunc {
+	anyOrigin := slices.Contains(origins, "*")
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
+		if anyOrigin {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Vary", "Origin")
+			if origin := c.GetHeader("Origin"); slices.Contains(origins, origin) {
+				c.Header("Access-Control-Allow-Origin", origin)
+			}
+		}
 		c.Header("Access-Control-Allow-Methods", "GET,POST,PUT,PATCH,DELETE,OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID")
 		if c.Request.Method == http.MethodOptions {
 			c.Status(http.StatusNoContent)
 			c.Abort()
@@ -28,42 +36,21 @@ func CORSMiddleware() gin.HandlerFunc {
 }
 
 // TrackingMiddleware 追踪请求并添加 pprof 标签的中间件
-// 用于记录请求路由和添加性能分析标签
+// 用于记录请求路由、请求体/响应体大小（Content-Length 或实际读取字节数、c.Writer.Size()）和添加性能分析标签
 func TrackingMiddleware(tracker *metrics.Tracker, hub *metrics.Hub) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		route := GetRoutePath(c)
-
-		// 记录请求开始时间（用于计算CPU时间）
-		startTime := time.Now()
-
-		// 记录请求前的内存
-		var memBefore runtime.MemStats
-		runtime.ReadMemStats(&memBefore)
-
-		// 记录请求
-		tracker.AddRequest(0)
-		tracker.AddRequestRoute(route, 0)
-		hub.Notify()
-
-		// 添加 pprof 标签用于性能分析
-		labels := pprof.Labels("route", route)
-		pprof.Do(c.Request.Context(), labels, func(ctx context.Context) {
+		metrics.Track(c.Request, tracker, hub, route, func(ctx context.Context) metrics.ResponseInfo {
 			c.Request = c.Request.WithContext(ctx)
 			c.Next()
 
-			// 请求完成后记录内存增量和CPU时间
-			var memAfter runtime.MemStats
-			runtime.ReadMemStats(&memAfter)
-
-			// 计算内存增量（使用 HeapAlloc 的变化）
-			if memAfter.HeapAlloc > memBefore.HeapAlloc {
-				memDelta := memAfter.HeapAlloc - memBefore.HeapAlloc
-				tracker.AddRouteMemory(route, memDelta)
+			// 未写出响应体时 Size 为 -1
+			size := c.Writer.Size()
+			if size < 0 {
+				size = 0
 			}
-
-			// 计算CPU时间（纳秒）
-			cpuTimeNs := time.Since(startTime).Nanoseconds()
-			tracker.AddRouteCPUTime(route, cpuTimeNs)
+			// c.ClientIP 只在连接来自 engine 的受信代理时采用转发头
+			return metrics.ResponseInfo{Status: c.Writer.Status(), Bytes: int64(size), ClientIP: c.ClientIP()}
 		})
 	}
 }
@@ -3,6 +3,8 @@ package ginutil
 import (
 	"strconv"
 
+	"analyseGo/internal/metrics"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -20,42 +22,22 @@ func ParseIntQuery(c *gin.Context, key string, defaultValue int) int {
 	return val
 }
 
-// ParseWindowSeconds 解析时间窗口参数（支持 window/minutes/hours）
-// 参数优先级：window > minutes > hours
-// 如果所有参数都无效，返回 defaultWindowSec
-// 如果解析的值超过 maxWindowSec，则限制为 maxWindowSec
-func ParseWindowSeconds(c *gin.Context, maxWindowSec, defaultWindowSec int) int {
-	// 优先使用 window 参数（秒）
-	if wStr := c.Query("window"); wStr != "" {
-		if sec, err := strconv.Atoi(wStr); err == nil && sec > 0 {
-			if sec > maxWindowSec {
-				sec = maxWindowSec
-			}
-			return sec
-		}
-	}
-
-	// 尝试 minutes 参数
-	if mStr := c.Query("minutes"); mStr != "" {
-		if m, err := strconv.Atoi(mStr); err == nil && m > 0 {
-			sec := m * 60
-			if sec > maxWindowSec {
-				sec = maxWindowSec
-			}
-			return sec
-		}
+// ParsePositiveIntQuery 解析必须为正整数的查询参数
+// 参数不存在时返回默认值；解析失败或值小于等于0时 ok 为 false，由调用方报错
+func ParsePositiveIntQuery(c *gin.Context, key string, defaultValue int) (val int, ok bool) {
+	valStr, present := c.GetQuery(key)
+	if !present {
+		return defaultValue, true
 	}
-
-	// 尝试 hours 参数
-	if hStr := c.Query("hours"); hStr != "" {
-		if h, err := strconv.Atoi(hStr); err == nil && h > 0 {
-			sec := h * 3600
-			if sec > maxWindowSec {
-				sec = maxWindowSec
-			}
-			return sec
-		}
+	val, err := strconv.Atoi(valStr)
+	if err != nil || val <= 0 {
+		return 0, false
 	}
+	return val, true
+}
 
-	return defaultWindowSec
+// ParseWindowSeconds 解析时间窗口参数（支持 window/minutes/hours）
+// 规则见 metrics.ParseWindowSeconds
+func ParseWindowSeconds(c *gin.Context, maxWindowSec, defaultWindowSec int) int {
+	return metrics.ParseWindowSeconds(c.Request.URL.Query(), maxWindowSec, defaultWindowSec)
 }
@@ -0,0 +1,40 @@
+package ginutil
+
+import (
+	"analyseGo/internal/reqid"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestIDKey gin.Context 中保存请求 ID 的键
+const requestIDKey = "requestId"
+
+// RequestIDMiddleware 生成或沿用 X-Request-ID 的中间件
+// 请求 ID 写入响应头、gin.Context 和 Request.Context，须注册在 TrackingMiddleware 之前
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(reqid.Header)
+		if !reqid.Valid(id) {
+			id = reqid.New()
+		}
+
+		c.Set(requestIDKey, id)
+		c.Header(reqid.Header, id)
+		c.Request = c.Request.WithContext(reqid.WithContext(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// RequestID 返回当前请求的 ID，未启用 RequestIDMiddleware 时返回空字符串
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// Error 以 {"error": "...", "requestId": "..."} 格式返回错误响应
+func Error(c *gin.Context, status int, msg string) {
+	body := gin.H{"error": msg}
+	if id := RequestID(c); id != "" {
+		body["requestId"] = id
+	}
+	c.JSON(status, body)
+}
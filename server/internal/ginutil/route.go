@@ -1,13 +1,17 @@
 package ginutil
 
-import "github.com/gin-gonic/gin"
+import (
+	"analyseGo/internal/metrics"
+
+	"github.com/gin-gonic/gin"
+)
 
 // GetRoutePath 提取路由路径
-// 优先使用 FullPath()，如果为空则使用 Request.URL.Path
+// 使用 FullPath()，未匹配任何路由（404/405）时归入 metrics.UnmatchedRoute，避免每个探测路径产生一个路由
 func GetRoutePath(c *gin.Context) string {
 	route := c.FullPath()
 	if route == "" {
-		route = c.Request.URL.Path
+		route = metrics.UnmatchedRoute
 	}
 	return route
 }
@@ -0,0 +1,72 @@
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"analyseGo/internal/auth"
+	"analyseGo/internal/ginutil"
+	"analyseGo/internal/workload"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stopWait 停止压测时等待进行中的请求结束的最长时间
+const stopWait = 5 * time.Second
+
+// HandleStart 启动压测，请求体为 Scenario
+func (m *Manager) HandleStart(c *gin.Context) {
+	var sc Scenario
+	if err := c.ShouldBindJSON(&sc); err != nil {
+		ginutil.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	// 压测请求打到本服务，以发起者的身份通过认证
+	if token := auth.Credential(c.Request); token != "" {
+		sc.Authorization = "Bearer " + token
+	}
+	r, err := m.Start(sc)
+	if errors.Is(err, ErrTooManyRuns) || errors.Is(err, workload.ErrBudgetExceeded) {
+		ginutil.Error(c, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	if err != nil {
+		ginutil.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusAccepted, r.Report(false))
+}
+
+// HandleList 列出所有压测
+func (m *Manager) HandleList(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"runs": m.Reports()})
+}
+
+// HandleGet 获取压测报告，默认附带压测期间的 Tracker 样本，samples=false 时省略
+func (m *Manager) HandleGet(c *gin.Context) {
+	r, ok := m.Get(c.Param("id"))
+	if !ok {
+		ginutil.Error(c, http.StatusNotFound, ErrRunNotFound.Error())
+		return
+	}
+	c.JSON(http.StatusOK, r.Report(c.Query("samples") != "false"))
+}
+
+// HandleStop 停止压测并返回当前报告
+// 最多等待 stopWait，超时返回的报告 endedAt 为 0，结束后可通过 HandleGet 取得最终报告
+func (m *Manager) HandleStop(c *gin.Context) {
+	r, err := m.Stop(c.Param("id"))
+	if err != nil {
+		ginutil.Error(c, http.StatusNotFound, err.Error())
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), stopWait)
+	defer cancel()
+	select {
+	case <-r.Done():
+	case <-ctx.Done():
+	}
+	c.JSON(http.StatusOK, r.Report(false))
+}
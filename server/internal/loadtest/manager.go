@@ -0,0 +1,219 @@
+package loadtest
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"analyseGo/internal/metrics"
+	"analyseGo/internal/otlp"
+	"analyseGo/internal/workload"
+)
+
+const (
+	// maxActiveRuns 同时运行的压测上限
+	maxActiveRuns = 4
+	// maxFinishedRuns 保留的已结束压测数，超出时丢弃最早的
+	maxFinishedRuns = 50
+)
+
+var (
+	// ErrTooManyRuns 运行中的压测已达上限
+	ErrTooManyRuns = errors.New("too many load tests running")
+	// ErrRunNotFound 压测不存在
+	ErrRunNotFound = errors.New("load test not found")
+)
+
+// Manager 管理压测的启动、停止和报告
+type Manager struct {
+	baseURL     string
+	tracker     *metrics.Tracker
+	annotations *metrics.AnnotationLog
+	jobs        *workload.Manager
+	client      *http.Client
+
+	mu   sync.Mutex
+	runs []*Run // 按启动时间排序
+}
+
+// NewManager 创建压测管理器
+// baseURL 为本服务地址（如 http://127.0.0.1:8080），tracker 用于关联压测期间的样本，
+// annotations 用于记录压测开始和结束，均可为 nil；
+// jobs 非空时压测作为任务运行，占用全局 goroutine 预算、出现在 /api/jobs 中且 goroutine 带任务标签
+func NewManager(baseURL string, tracker *metrics.Tracker, annotations *metrics.AnnotationLog, jobs *workload.Manager) *Manager {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = maxConcurrency
+	transport.MaxIdleConnsPerHost = maxConcurrency
+	return &Manager{
+		baseURL:     baseURL,
+		tracker:     tracker,
+		annotations: annotations,
+		jobs:        jobs,
+		client:      &http.Client{Transport: transport},
+	}
+}
+
+// Start 校验场景并在后台启动压测
+func (m *Manager) Start(sc Scenario) (*Run, error) {
+	c, err := compile(sc)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	active := 0
+	for _, r := range m.runs {
+		if r.running() {
+			active++
+		}
+	}
+	if active >= maxActiveRuns {
+		return nil, ErrTooManyRuns
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Run{
+		id:        newID(),
+		sc:        c,
+		tracker:   m.tracker,
+		trace:     otlp.NewSpanContext(),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		state:     StateRunning,
+		startedAt: time.Now(),
+		statuses:  make(map[int]uint64),
+	}
+	if m.jobs == nil {
+		go m.run(ctx, r)
+	} else {
+		// 每个并发占一个请求 goroutine 和连接的读写两个 goroutine，外加开放模型的调度 goroutine；
+		// 时限多留一个请求超时，等待进行中的请求结束
+		spec := workload.Spec{
+			Kind:        workload.KindLoadTest,
+			Workers:     3*c.Concurrency + 1,
+			DurationSec: (c.duration + c.timeout).Seconds(),
+		}
+		_, err := m.jobs.StartFunc("loadtest "+r.id, spec, func(jobCtx context.Context) error {
+			// 通过 /api/jobs 取消任务时停止压测
+			stop := context.AfterFunc(jobCtx, r.Stop)
+			defer stop()
+			m.run(ctx, r)
+			return nil
+		})
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	m.runs = append(m.runs, r)
+	m.prune()
+	return r, nil
+}
+
+// run 执行压测，开始和结束时记录注释
+func (m *Manager) run(ctx context.Context, r *Run) {
+	title := r.id
+	if r.sc.Name != "" {
+		title += " (" + r.sc.Name + ")"
+	}
+	m.annotations.Record("Load test "+title+" started: "+r.sc.Method+" "+r.sc.Path, metrics.TagLoadTest)
+	r.execute(ctx, m.client, m.baseURL)
+
+	r.mu.Lock()
+	state := r.state
+	r.mu.Unlock()
+	m.annotations.Record("Load test "+title+" "+string(state), metrics.TagLoadTest)
+}
+
+// prune 丢弃超出保留数量的已结束压测，调用方需持有 mu
+func (m *Manager) prune() {
+	finished := 0
+	for _, r := range m.runs {
+		if !r.running() {
+			finished++
+		}
+	}
+	m.runs = slices.DeleteFunc(m.runs, func(r *Run) bool {
+		if finished > maxFinishedRuns && !r.running() {
+			finished--
+			return true
+		}
+		return false
+	})
+}
+
+// Get 按 ID 查找压测
+func (m *Manager) Get(id string) (*Run, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, r := range m.runs {
+		if r.id == id {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// Stop 停止指定压测
+func (m *Manager) Stop(id string) (*Run, error) {
+	r, ok := m.Get(id)
+	if !ok {
+		return nil, ErrRunNotFound
+	}
+	r.Stop()
+	return r, nil
+}
+
+// Reports 返回所有压测的报告（不含样本），最新的在前
+func (m *Manager) Reports() []Report {
+	m.mu.Lock()
+	runs := slices.Clone(m.runs)
+	m.mu.Unlock()
+
+	reports := make([]Report, 0, len(runs))
+	for _, r := range slices.Backward(runs) {
+		reports = append(reports, r.Report(false))
+	}
+	return reports
+}
+
+// StopAll 停止所有运行中的压测并等待结束或 ctx 到期
+func (m *Manager) StopAll(ctx context.Context) {
+	m.mu.Lock()
+	runs := slices.Clone(m.runs)
+	m.mu.Unlock()
+
+	for _, r := range runs {
+		r.Stop()
+	}
+	for _, r := range runs {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// running 压测是否仍在执行
+func (r *Run) running() bool {
+	select {
+	case <-r.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// newID 生成压测 ID
+func newID() string {
+	var b [6]byte
+	crand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,359 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"analyseGo/internal/metrics"
+	"analyseGo/internal/otlp"
+)
+
+const (
+	// maxLatencySamples 延迟蓄水池容量，超过后随机替换，内存与请求数无关
+	maxLatencySamples = 100000
+	// dispatchInterval 开放模型下的调度间隔
+	dispatchInterval = 5 * time.Millisecond
+	// RunHeader 压测请求携带的请求头，值为压测 ID
+	RunHeader = "X-Loadtest-Run"
+)
+
+// State 压测状态
+type State string
+
+const (
+	StateRunning   State = "running"   // 运行中
+	StateCompleted State = "completed" // 按时完成
+	StateStopped   State = "stopped"   // 被手动停止
+)
+
+// LatencyStats 延迟统计（毫秒）
+type LatencyStats struct {
+	Count  int     `json:"count"` // 参与统计的样本数（可能小于请求数）
+	MeanMs float64 `json:"meanMs"`
+	P50Ms  float64 `json:"p50Ms"`
+	P90Ms  float64 `json:"p90Ms"`
+	P95Ms  float64 `json:"p95Ms"`
+	P99Ms  float64 `json:"p99Ms"`
+	MaxMs  float64 `json:"maxMs"`
+}
+
+// SampleSummary 压测期间 Tracker 样本的概要
+type SampleSummary struct {
+	Samples        int     `json:"samples"`        // 样本数
+	PeakGoroutines int     `json:"peakGoroutines"` // 最大 goroutine 数
+	PeakHeapAlloc  uint64  `json:"peakHeapAlloc"`  // 最大堆内存（字节）
+	PeakInFlight   int     `json:"peakInFlight"`   // 最大并发请求数
+	PeakQPS        float64 `json:"peakQps"`        // 最大 QPS
+	GCCount        uint32  `json:"gcCount"`        // 期间 GC 次数
+}
+
+// Report 压测报告
+type Report struct {
+	ID            string            `json:"id"`
+	Scenario      Scenario          `json:"scenario"`
+	State         State             `json:"state"`
+	TraceID       string            `json:"traceId"`    // 压测请求共用的追踪 ID，可在请求日志和 OTLP 后端中按此查找
+	StartedAt     int64             `json:"startedAt"`  // 开始时间戳（毫秒）
+	EndedAt       int64             `json:"endedAt"`    // 结束时间戳（毫秒），运行中为 0
+	ElapsedSec    float64           `json:"elapsedSec"` // 已运行时间（秒）
+	Sent          uint64            `json:"sent"`       // 已发出的请求数
+	Completed     uint64            `json:"completed"`  // 收到响应的请求数
+	Errors        uint64            `json:"errors"`     // 网络错误或超时
+	Canceled      uint64            `json:"canceled"`   // 停止压测时被中断的请求数
+	Dropped       uint64            `json:"dropped"`    // 开放模型下因并发已满未能发出的请求数
+	Statuses      map[string]uint64 `json:"statuses"`   // 按状态码统计
+	Throughput    float64           `json:"throughput"` // 实际吞吐（完成请求数/秒）
+	Latency       LatencyStats      `json:"latency"`
+	SampleSummary *SampleSummary    `json:"sampleSummary,omitempty"`
+	Samples       []metrics.Sample  `json:"samples,omitempty"` // 压测期间的 Tracker 样本
+}
+
+// Run 一次压测
+type Run struct {
+	id      string
+	sc      *compiled
+	tracker *metrics.Tracker
+	trace   otlp.SpanContext // 压测的根 span，每个请求以其子 span 通过 traceparent 传播
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	sent      atomic.Uint64
+	completed atomic.Uint64
+	errors    atomic.Uint64
+	canceled  atomic.Uint64
+	dropped   atomic.Uint64
+	seq       atomic.Uint64
+
+	mu        sync.Mutex
+	state     State
+	startedAt time.Time
+	endedAt   time.Time
+	statuses  map[int]uint64
+	latencies []float64 // 蓄水池
+	latSeen   uint64    // 进入蓄水池的总样本数
+	latSum    float64
+	latMax    float64
+}
+
+// ID 返回压测 ID
+func (r *Run) ID() string { return r.id }
+
+// Done 返回压测结束后关闭的通道
+func (r *Run) Done() <-chan struct{} { return r.done }
+
+// Stop 停止压测，进行中的请求会被取消
+func (r *Run) Stop() {
+	r.mu.Lock()
+	if r.state == StateRunning {
+		r.state = StateStopped
+	}
+	r.mu.Unlock()
+	r.cancel()
+}
+
+// record 记录一次请求结果
+func (r *Run) record(status int, latency time.Duration) {
+	ms := float64(latency) / float64(time.Millisecond)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statuses[status]++
+	r.latSeen++
+	r.latSum += ms
+	r.latMax = max(r.latMax, ms)
+	if len(r.latencies) < maxLatencySamples {
+		r.latencies = append(r.latencies, ms)
+	} else if i := rand.Uint64N(r.latSeen); i < maxLatencySamples {
+		r.latencies[i] = ms
+	}
+}
+
+// Report 生成报告，withSamples 为 true 时附带压测期间的 Tracker 样本
+func (r *Run) Report(withSamples bool) Report {
+	r.mu.Lock()
+	rep := Report{
+		ID:        r.id,
+		Scenario:  r.sc.Scenario,
+		State:     r.state,
+		TraceID:   r.trace.TraceIDHex(),
+		StartedAt: r.startedAt.UnixMilli(),
+		Statuses:  make(map[string]uint64, len(r.statuses)),
+	}
+	end := time.Now()
+	if !r.endedAt.IsZero() {
+		end = r.endedAt
+		rep.EndedAt = r.endedAt.UnixMilli()
+	}
+	for status, n := range r.statuses {
+		rep.Statuses[strconv.Itoa(status)] = n
+	}
+	lat := slices.Clone(r.latencies)
+	rep.Latency = LatencyStats{Count: len(lat), MaxMs: r.latMax}
+	if r.latSeen > 0 {
+		rep.Latency.MeanMs = r.latSum / float64(r.latSeen)
+	}
+	r.mu.Unlock()
+
+	slices.Sort(lat)
+	rep.Latency.P50Ms = percentile(lat, 0.50)
+	rep.Latency.P90Ms = percentile(lat, 0.90)
+	rep.Latency.P95Ms = percentile(lat, 0.95)
+	rep.Latency.P99Ms = percentile(lat, 0.99)
+
+	rep.ElapsedSec = end.Sub(r.startedAt).Seconds()
+	rep.Sent = r.sent.Load()
+	rep.Completed = r.completed.Load()
+	rep.Errors = r.errors.Load()
+	rep.Canceled = r.canceled.Load()
+	rep.Dropped = r.dropped.Load()
+	if rep.ElapsedSec > 0 {
+		rep.Throughput = float64(rep.Completed) / rep.ElapsedSec
+	}
+
+	if r.tracker != nil {
+		samples := samplesBetween(r.tracker.SamplesSince(rep.StartedAt-1), end.UnixMilli())
+		rep.SampleSummary = summarize(samples)
+		if withSamples {
+			rep.Samples = samples
+		}
+	}
+	return rep
+}
+
+// percentile 返回已排序数据的 q 分位数（最近秩）
+func percentile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(q*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+// samplesBetween 截取时间戳不晚于 end 的样本
+func samplesBetween(samples []metrics.Sample, end int64) []metrics.Sample {
+	for i, s := range samples {
+		if s.Time > end {
+			return samples[:i]
+		}
+	}
+	return samples
+}
+
+// summarize 计算样本概要
+func summarize(samples []metrics.Sample) *SampleSummary {
+	sum := &SampleSummary{Samples: len(samples)}
+	for _, s := range samples {
+		sum.PeakGoroutines = max(sum.PeakGoroutines, s.Goroutines)
+		sum.PeakHeapAlloc = max(sum.PeakHeapAlloc, s.HeapAlloc)
+		sum.PeakInFlight = max(sum.PeakInFlight, s.PeakInFlight)
+		sum.PeakQPS = max(sum.PeakQPS, s.QPS)
+		sum.GCCount += s.GCIncrement
+	}
+	return sum
+}
+
+// execute 执行压测直到持续时间结束或被停止；ctx 被取消时进行中的请求也会被取消
+func (r *Run) execute(ctx context.Context, client *http.Client, baseURL string) {
+	defer close(r.done)
+
+	// issueCtx 只控制是否继续发起新请求，到时后等待进行中的请求自然结束
+	issueCtx, cancelIssue := context.WithTimeout(ctx, r.sc.duration)
+	defer cancelIssue()
+
+	var wg sync.WaitGroup
+	if r.sc.RPS > 0 {
+		r.runOpen(issueCtx, ctx, client, baseURL, &wg)
+	} else {
+		r.runClosed(issueCtx, ctx, client, baseURL, &wg)
+	}
+	wg.Wait()
+
+	r.mu.Lock()
+	r.endedAt = time.Now()
+	if r.state == StateRunning {
+		r.state = StateCompleted
+	}
+	r.mu.Unlock()
+}
+
+// runOpen 开放模型：按目标速率（含爬坡）调度请求，并发已满时丢弃
+func (r *Run) runOpen(issueCtx, reqCtx context.Context, client *http.Client, baseURL string, wg *sync.WaitGroup) {
+	sem := make(chan struct{}, r.sc.Concurrency)
+	ticker := time.NewTicker(dispatchInterval)
+	defer ticker.Stop()
+
+	var scheduled uint64
+	for {
+		select {
+		case <-issueCtx.Done():
+			return
+		case now := <-ticker.C:
+			due := r.expected(now.Sub(r.startedAt))
+			for ; scheduled < due; scheduled++ {
+				select {
+				case sem <- struct{}{}:
+					worker := int(scheduled % uint64(r.sc.Concurrency))
+					wg.Go(func() {
+						defer func() { <-sem }()
+						r.do(reqCtx, client, baseURL, worker)
+					})
+				default:
+					r.dropped.Add(1)
+				}
+			}
+		}
+	}
+}
+
+// expected 返回开放模型下截至 elapsed 应发出的请求数，爬坡期间速率线性增加
+func (r *Run) expected(elapsed time.Duration) uint64 {
+	t := elapsed.Seconds()
+	ramp := r.sc.rampUp.Seconds()
+	var n float64
+	switch {
+	case ramp <= 0:
+		n = r.sc.RPS * t
+	case t < ramp:
+		n = r.sc.RPS * t * t / (2 * ramp)
+	default:
+		n = r.sc.RPS*ramp/2 + r.sc.RPS*(t-ramp)
+	}
+	return uint64(n)
+}
+
+// runClosed 闭合模型：Concurrency 个 worker 循环请求，爬坡期间依次启动
+func (r *Run) runClosed(issueCtx, reqCtx context.Context, client *http.Client, baseURL string, wg *sync.WaitGroup) {
+	n := r.sc.Concurrency
+	for w := 0; w < n; w++ {
+		delay := time.Duration(int64(r.sc.rampUp) * int64(w) / int64(n))
+		wg.Go(func() {
+			if delay > 0 {
+				timer := time.NewTimer(delay)
+				defer timer.Stop()
+				select {
+				case <-issueCtx.Done():
+					return
+				case <-timer.C:
+				}
+			}
+			for issueCtx.Err() == nil {
+				r.do(reqCtx, client, baseURL, w)
+			}
+		})
+	}
+}
+
+// do 发出一次请求并记录结果
+func (r *Run) do(ctx context.Context, client *http.Client, baseURL string, worker int) {
+	path, body, err := r.sc.render(templateData{Seq: r.seq.Add(1), Worker: worker})
+	if err != nil {
+		r.errors.Add(1)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.sc.timeout)
+	defer cancel()
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, r.sc.Method, baseURL+path, reader)
+	if err != nil {
+		r.errors.Add(1)
+		return
+	}
+	// 场景自带的 traceparent 优先
+	otlp.Inject(otlp.ContextWithSpan(ctx, r.trace.Child()), req.Header)
+	if r.sc.Authorization != "" {
+		req.Header.Set("Authorization", r.sc.Authorization)
+	}
+	for k, v := range r.sc.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set(RunHeader, r.id)
+
+	r.sent.Add(1)
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			r.canceled.Add(1)
+		} else {
+			r.errors.Add(1)
+		}
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	r.completed.Add(1)
+	r.record(resp.StatusCode, time.Since(start))
+}
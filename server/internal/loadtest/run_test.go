@@ -0,0 +1,251 @@
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]float64, 100)
+	for i := range sorted {
+		sorted[i] = float64(i + 1)
+	}
+	tests := []struct{ q, want float64 }{{0, 1}, {0.5, 50}, {0.9, 90}, {0.95, 95}, {0.99, 99}, {1, 100}}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.q); got != tt.want {
+			t.Errorf("percentile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+	if got := percentile(nil, 0.5); got != 0 {
+		t.Errorf("percentile(nil) = %v", got)
+	}
+}
+
+func TestReportLatency(t *testing.T) {
+	c, _ := compile(Scenario{Path: "/", DurationSec: 1})
+	r := &Run{sc: c, startedAt: time.Now(), statuses: make(map[int]uint64)}
+	for i := 1; i <= 100; i++ {
+		status := http.StatusOK
+		if i%10 == 0 {
+			status = http.StatusInternalServerError
+		}
+		r.record(status, time.Duration(i)*time.Millisecond)
+	}
+	rep := r.Report(false)
+	want := LatencyStats{Count: 100, MeanMs: 50.5, P50Ms: 50, P90Ms: 90, P95Ms: 95, P99Ms: 99, MaxMs: 100}
+	if rep.Latency != want {
+		t.Errorf("latency = %+v, want %+v", rep.Latency, want)
+	}
+	if rep.Statuses["200"] != 90 || rep.Statuses["500"] != 10 {
+		t.Errorf("statuses = %v", rep.Statuses)
+	}
+}
+
+func TestExpectedRampUp(t *testing.T) {
+	c, _ := compile(Scenario{Path: "/", RPS: 100, DurationSec: 10, RampUpSec: 2})
+	r := &Run{sc: c}
+	flat, _ := compile(Scenario{Path: "/", RPS: 100, DurationSec: 10})
+	tests := []struct {
+		run     *Run
+		elapsed time.Duration
+		want    uint64
+	}{
+		{r, 0, 0},
+		{r, time.Second, 25},      // 爬坡一半：100·1²/(2·2)
+		{r, 2 * time.Second, 100}, // 爬坡结束：平均速率为目标的一半
+		{r, 3 * time.Second, 200}, // 之后按目标速率
+		{&Run{sc: flat}, 1500 * time.Millisecond, 150},
+	}
+	for _, tt := range tests {
+		if got := tt.run.expected(tt.elapsed); got != tt.want {
+			t.Errorf("expected(%v, ramp %v) = %d, want %d", tt.elapsed, tt.run.sc.rampUp, got, tt.want)
+		}
+	}
+}
+
+// target 记录压测请求的测试服务
+type target struct {
+	delay    time.Duration
+	inFlight atomic.Int64
+	peak     atomic.Int64
+
+	mu       sync.Mutex
+	paths    []string
+	bodies   []string
+	headers  []http.Header
+	arrivals []time.Time
+}
+
+func (tg *target) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := tg.inFlight.Add(1)
+	defer tg.inFlight.Add(-1)
+	for p := tg.peak.Load(); n > p && !tg.peak.CompareAndSwap(p, n); p = tg.peak.Load() {
+	}
+	body, _ := io.ReadAll(r.Body)
+	tg.mu.Lock()
+	tg.paths = append(tg.paths, r.URL.RequestURI())
+	tg.bodies = append(tg.bodies, string(body))
+	tg.headers = append(tg.headers, r.Header.Clone())
+	tg.arrivals = append(tg.arrivals, time.Now())
+	tg.mu.Unlock()
+
+	select {
+	case <-time.After(tg.delay):
+	case <-r.Context().Done():
+		return
+	}
+	if r.URL.Query().Get("fail") != "" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+// runScenario 对测试服务执行场景并等待结束
+func runScenario(t *testing.T, tg *target, sc Scenario) Report {
+	t.Helper()
+	srv := httptest.NewServer(tg)
+	defer srv.Close()
+	m := NewManager(srv.URL, nil, nil, nil)
+	r, err := m.Start(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-r.Done():
+	case <-time.After(10 * time.Second):
+		t.Fatal("load test did not finish")
+	}
+	return r.Report(false)
+}
+
+func TestClosedModel(t *testing.T) {
+	tg := &target{delay: 10 * time.Millisecond}
+	rep := runScenario(t, tg, Scenario{
+		Method:        "POST",
+		Path:          "/echo/{{.Seq}}?w={{.Worker}}",
+		Body:          `{"seq":{{.Seq}}}`,
+		Headers:       map[string]string{"X-Scenario": "closed"},
+		Concurrency:   3,
+		DurationSec:   0.3,
+		Authorization: "Bearer secret",
+	})
+
+	if rep.State != StateCompleted || rep.EndedAt == 0 {
+		t.Errorf("state = %s, endedAt = %d", rep.State, rep.EndedAt)
+	}
+	if got := tg.peak.Load(); got != 3 {
+		t.Errorf("peak concurrency = %d, want 3", got)
+	}
+	// 每个 worker 约 30 个请求
+	if rep.Completed < 30 || rep.Completed != rep.Statuses["200"] || rep.Sent != rep.Completed || rep.Errors != 0 {
+		t.Errorf("sent=%d completed=%d errors=%d statuses=%v", rep.Sent, rep.Completed, rep.Errors, rep.Statuses)
+	}
+
+	// 模板按请求序号和 worker 渲染，序号不重复
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	seen := make(map[string]bool)
+	for i, p := range tg.paths {
+		var seq, worker int
+		if _, err := fmt.Sscanf(p, "/echo/%d?w=%d", &seq, &worker); err != nil || worker < 0 || worker > 2 {
+			t.Fatalf("path %q", p)
+		}
+		if tg.bodies[i] != `{"seq":`+strconv.Itoa(seq)+`}` {
+			t.Errorf("body %q for path %q", tg.bodies[i], p)
+		}
+		if seen[p] {
+			t.Errorf("duplicate seq in %q", p)
+		}
+		seen[p] = true
+
+		h := tg.headers[i]
+		if h.Get("X-Scenario") != "closed" || h.Get("Authorization") != "Bearer secret" || h.Get(RunHeader) != rep.ID {
+			t.Errorf("headers = %v", h)
+		}
+		if tp := h.Get("Traceparent"); len(tp) != 55 || tp[3:35] != rep.TraceID {
+			t.Errorf("traceparent %q not in trace %s", tp, rep.TraceID)
+		}
+	}
+}
+
+func TestOpenModel(t *testing.T) {
+	tg := &target{}
+	rep := runScenario(t, tg, Scenario{Path: "/ok", RPS: 200, DurationSec: 0.5})
+	// 500ms 内应发出约 100 个请求，调度粒度 5ms
+	if rep.Sent < 90 || rep.Sent > 101 || rep.Dropped != 0 {
+		t.Errorf("sent = %d dropped = %d, want ~100 and 0", rep.Sent, rep.Dropped)
+	}
+
+	// 并发上限 1、每个请求 100ms：超出的请求计为 dropped 而不是排队
+	slow := &target{delay: 100 * time.Millisecond}
+	rep = runScenario(t, slow, Scenario{Path: "/ok?fail=1", RPS: 100, Concurrency: 1, DurationSec: 0.3})
+	if got := slow.peak.Load(); got != 1 {
+		t.Errorf("peak concurrency = %d, want 1", got)
+	}
+	if rep.Sent > 4 || rep.Dropped < 20 || rep.Sent+rep.Dropped < 28 {
+		t.Errorf("sent = %d dropped = %d", rep.Sent, rep.Dropped)
+	}
+	if rep.Statuses["503"] != rep.Completed {
+		t.Errorf("statuses = %v", rep.Statuses)
+	}
+}
+
+func TestClosedModelRampUp(t *testing.T) {
+	tg := &target{delay: 5 * time.Millisecond}
+	start := time.Now()
+	runScenario(t, tg, Scenario{Path: "/ok?w={{.Worker}}", Concurrency: 4, DurationSec: 0.4, RampUpSec: 0.4})
+
+	// worker i 在 rampUp·i/4 后启动
+	first := make(map[string]time.Duration)
+	tg.mu.Lock()
+	for i, p := range tg.paths {
+		if _, ok := first[p]; !ok {
+			first[p] = tg.arrivals[i].Sub(start)
+		}
+	}
+	tg.mu.Unlock()
+	for w := range 4 {
+		got, ok := first["/ok?w="+strconv.Itoa(w)]
+		want := time.Duration(w) * 100 * time.Millisecond
+		if !ok || got < want || got > want+80*time.Millisecond {
+			t.Errorf("worker %d first request at %v, want ~%v", w, got, want)
+		}
+	}
+}
+
+func TestHandleStopBounded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewManager("http://127.0.0.1:0", nil, nil, nil)
+	c, _ := compile(Scenario{Path: "/", DurationSec: 1})
+	// 一个永远不结束的压测
+	stuck := &Run{id: "stuck", sc: c, cancel: func() {}, done: make(chan struct{}), state: StateRunning, startedAt: time.Now(), statuses: map[int]uint64{}}
+	m.runs = append(m.runs, stuck)
+
+	router := gin.New()
+	router.POST("/runs/:id/stop", m.HandleStop)
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/runs/stuck/stop", nil)
+	w := httptest.NewRecorder()
+
+	begin := time.Now()
+	router.ServeHTTP(w, req)
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("HandleStop blocked for %v", elapsed)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	if rep := stuck.Report(false); rep.State != StateStopped || rep.EndedAt != 0 {
+		t.Errorf("report = %+v", rep)
+	}
+}
@@ -0,0 +1,162 @@
+// Package loadtest 对本服务自身的路由发起压测，报告吞吐和延迟分位数，并关联压测期间的 Tracker 样本
+package loadtest
+
+import (
+	"bytes"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// maxConcurrency 单个场景最多并发请求数
+	maxConcurrency = 500
+	// maxRPS 单个场景最大目标 RPS
+	maxRPS = 10000
+	// maxDuration 单个场景最长持续时间
+	maxDuration = 10 * time.Minute
+	// defaultConcurrency 开放模型（指定 RPS）下默认的并发上限
+	defaultConcurrency = 50
+	// defaultTimeout 单个请求默认超时
+	defaultTimeout = 10 * time.Second
+)
+
+// Scenario 压测场景
+//
+// 指定 RPS 时按固定速率发起请求（开放模型），Concurrency 为并发上限，超出时计为 Dropped；
+// 未指定 RPS 时 Concurrency 个 worker 循环发起请求（闭合模型）。
+// RampUpSec 内速率或 worker 数从 0 线性增加到目标值。
+// Path 和 Body 为 text/template 模板，可用 .Seq、.Worker 和函数 randInt、randString、uuid。
+type Scenario struct {
+	Name        string            `json:"name"`
+	Method      string            `json:"method"`      // 默认 GET
+	Path        string            `json:"path"`        // 目标路径（含查询参数），例如 /api/ping/slow?ms=100
+	Headers     map[string]string `json:"headers"`     // 额外请求头
+	Body        string            `json:"body"`        // 请求体模板
+	RPS         float64           `json:"rps"`         // 目标每秒请求数，0 表示闭合模型
+	Concurrency int               `json:"concurrency"` // 并发数
+	DurationSec float64           `json:"durationSec"` // 持续时间（秒）
+	RampUpSec   float64           `json:"rampUpSec"`   // 爬坡时间（秒）
+	TimeoutMs   int               `json:"timeoutMs"`   // 单个请求超时（毫秒），默认10秒
+
+	// Authorization 发起者的凭据，Headers 未指定时随压测请求携带，不出现在报告中
+	Authorization string `json:"-"`
+}
+
+// templateData 模板可用的变量
+type templateData struct {
+	Seq    uint64 // 全局请求序号，从 1 开始
+	Worker int    // worker 序号
+}
+
+// templateFuncs 模板可用的函数
+var templateFuncs = template.FuncMap{
+	"randInt": func(n int) int {
+		if n <= 0 {
+			return 0
+		}
+		return rand.IntN(n)
+	},
+	"randString": func(n int) string {
+		const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+		b := make([]byte, max(n, 0))
+		for i := range b {
+			b[i] = letters[rand.IntN(len(letters))]
+		}
+		return string(b)
+	},
+	"uuid": func() string {
+		var b [16]byte
+		crand.Read(b[:])
+		b[6] = b[6]&0x0f | 0x40
+		b[8] = b[8]&0x3f | 0x80
+		h := hex.EncodeToString(b[:])
+		return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+	},
+}
+
+// compiled 校验并编译后的场景
+type compiled struct {
+	Scenario
+	duration time.Duration
+	rampUp   time.Duration
+	timeout  time.Duration
+	path     *template.Template
+	body     *template.Template // 为空表示无请求体
+}
+
+// compile 校验场景、填充默认值并编译模板
+func compile(sc Scenario) (*compiled, error) {
+	var errs []error
+	if sc.Method == "" {
+		sc.Method = http.MethodGet
+	}
+	sc.Method = strings.ToUpper(sc.Method)
+	if !strings.HasPrefix(sc.Path, "/") {
+		errs = append(errs, errors.New("path must start with /"))
+	}
+	if sc.RPS < 0 || sc.RPS > maxRPS {
+		errs = append(errs, fmt.Errorf("rps must be between 0 and %d", maxRPS))
+	}
+	if sc.Concurrency == 0 {
+		if sc.RPS > 0 {
+			sc.Concurrency = defaultConcurrency
+		} else {
+			sc.Concurrency = 1
+		}
+	}
+	if sc.Concurrency < 0 || sc.Concurrency > maxConcurrency {
+		errs = append(errs, fmt.Errorf("concurrency must be between 1 and %d", maxConcurrency))
+	}
+	c := &compiled{
+		duration: time.Duration(sc.DurationSec * float64(time.Second)),
+		rampUp:   time.Duration(sc.RampUpSec * float64(time.Second)),
+		timeout:  time.Duration(sc.TimeoutMs) * time.Millisecond,
+	}
+	if c.duration <= 0 || c.duration > maxDuration {
+		errs = append(errs, fmt.Errorf("durationSec must be positive and at most %v", maxDuration.Seconds()))
+	}
+	if c.rampUp < 0 || c.rampUp > c.duration {
+		errs = append(errs, errors.New("rampUpSec must be between 0 and durationSec"))
+	}
+	if c.timeout <= 0 {
+		c.timeout = defaultTimeout
+	}
+
+	var err error
+	if c.path, err = template.New("path").Funcs(templateFuncs).Parse(sc.Path); err != nil {
+		errs = append(errs, fmt.Errorf("path template: %w", err))
+	}
+	if sc.Body != "" {
+		if c.body, err = template.New("body").Funcs(templateFuncs).Parse(sc.Body); err != nil {
+			errs = append(errs, fmt.Errorf("body template: %w", err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	c.Scenario = sc
+	return c, nil
+}
+
+// render 渲染一次请求的路径和请求体
+func (c *compiled) render(data templateData) (string, []byte, error) {
+	var path bytes.Buffer
+	if err := c.path.Execute(&path, data); err != nil {
+		return "", nil, err
+	}
+	if c.body == nil {
+		return path.String(), nil, nil
+	}
+	var body bytes.Buffer
+	if err := c.body.Execute(&body, data); err != nil {
+		return "", nil, err
+	}
+	return path.String(), body.Bytes(), nil
+}
@@ -0,0 +1,83 @@
+package loadtest
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileDefaults(t *testing.T) {
+	tests := []struct {
+		name            string
+		sc              Scenario
+		wantMethod      string
+		wantConcurrency int
+		wantTimeout     time.Duration
+	}{
+		{"closed model", Scenario{Path: "/api/ping", DurationSec: 1}, "GET", 1, defaultTimeout},
+		{"open model", Scenario{Path: "/api/ping", RPS: 10, DurationSec: 1}, "GET", defaultConcurrency, defaultTimeout},
+		{"explicit", Scenario{Method: "post", Path: "/x", Concurrency: 7, DurationSec: 1, TimeoutMs: 250}, "POST", 7, 250 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		c, err := compile(tt.sc)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if c.Method != tt.wantMethod || c.Concurrency != tt.wantConcurrency || c.timeout != tt.wantTimeout {
+			t.Errorf("%s: method=%s concurrency=%d timeout=%v", tt.name, c.Method, c.Concurrency, c.timeout)
+		}
+	}
+}
+
+func TestCompileRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		sc   Scenario
+		want string
+	}{
+		{"relative path", Scenario{Path: "api", DurationSec: 1}, "path must start with /"},
+		{"rps too high", Scenario{Path: "/", RPS: maxRPS + 1, DurationSec: 1}, "rps"},
+		{"negative rps", Scenario{Path: "/", RPS: -1, DurationSec: 1}, "rps"},
+		{"concurrency too high", Scenario{Path: "/", Concurrency: maxConcurrency + 1, DurationSec: 1}, "concurrency"},
+		{"no duration", Scenario{Path: "/"}, "durationSec"},
+		{"too long", Scenario{Path: "/", DurationSec: 601}, "durationSec"},
+		{"ramp longer than run", Scenario{Path: "/", DurationSec: 1, RampUpSec: 2}, "rampUpSec"},
+		{"bad path template", Scenario{Path: "/{{.Seq", DurationSec: 1}, "path template"},
+		{"bad body template", Scenario{Path: "/", Body: "{{nope}}", DurationSec: 1}, "body template"},
+	}
+	for _, tt := range tests {
+		if _, err := compile(tt.sc); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: err = %v, want mention of %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	c, err := compile(Scenario{
+		Path:        "/api/posts/{{.Seq}}?w={{.Worker}}&n={{randInt 10}}",
+		Body:        `{"id":"{{uuid}}","title":"{{randString 12}}"}`,
+		DurationSec: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, body, err := c.render(templateData{Seq: 42, Worker: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := regexp.MustCompile(`^/api/posts/42\?w=3&n=(\d+)$`).FindStringSubmatch(path); m == nil {
+		t.Errorf("path = %q", path)
+	} else if n, _ := strconv.Atoi(m[1]); n >= 10 {
+		t.Errorf("randInt 10 = %d", n)
+	}
+	if !regexp.MustCompile(`^\{"id":"[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}","title":"[a-zA-Z0-9]{12}"\}$`).Match(body) {
+		t.Errorf("body = %s", body)
+	}
+
+	plain, _ := compile(Scenario{Path: "/api/ping", DurationSec: 1})
+	if _, body, _ := plain.render(templateData{}); body != nil {
+		t.Errorf("no body template rendered %q", body)
+	}
+}
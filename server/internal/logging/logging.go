@@ -0,0 +1,175 @@
+// Package logging 基于 log/slog 的结构化分级日志
+//
+// 日志按组件划分（app、access、gorm），每个组件有独立的、可在运行时调整的级别；
+// 通过 *Context 系列方法记录的日志会自动附带 ctx 中的请求 ID。
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"analyseGo/internal/reqid"
+)
+
+// 内置组件
+const (
+	ComponentApp    = "app"    // 应用日志，slog.Default 使用该组件
+	ComponentAccess = "access" // 访问日志
+	ComponentGorm   = "gorm"   // SQL 日志
+)
+
+var (
+	mu     sync.RWMutex
+	base   slog.Handler = slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+	levels              = map[string]*slog.LevelVar{}
+)
+
+// Setup 初始化日志输出，format 为 json 或 text，level 为所有组件的初始级别
+// 同时替换 slog.Default，标准库 log 的输出随之转为结构化日志
+func Setup(w io.Writer, format string, level string) error {
+	lv, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	// 底层 handler 放行所有级别，由组件级别负责过滤
+	opts := &slog.HandlerOptions{Level: slog.LevelDebug}
+	var h slog.Handler
+	switch format {
+	case "json", "":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return fmt.Errorf("unknown log format: %s", format)
+	}
+
+	mu.Lock()
+	base = h
+	for _, c := range []string{ComponentApp, ComponentAccess, ComponentGorm} {
+		levelVar(c)
+	}
+	for _, v := range levels {
+		v.Set(lv)
+	}
+	mu.Unlock()
+
+	// 标准库 log 的输出也会转到 app 组件
+	slog.SetDefault(Logger(ComponentApp))
+	return nil
+}
+
+// Logger 返回组件日志，组件不存在时自动注册
+func Logger(component string) *slog.Logger {
+	mu.Lock()
+	lv := levelVar(component)
+	h := base
+	mu.Unlock()
+	return slog.New(&handler{inner: h, level: lv}).With("component", component)
+}
+
+// levelVar 返回组件级别，须持有 mu
+func levelVar(component string) *slog.LevelVar {
+	lv, ok := levels[component]
+	if !ok {
+		lv = new(slog.LevelVar)
+		levels[component] = lv
+	}
+	return lv
+}
+
+// SetLevel 运行时调整组件级别，component 为空时调整所有组件
+func SetLevel(component, level string) error {
+	lv, err := ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if component == "" {
+		for _, v := range levels {
+			v.Set(lv)
+		}
+		return nil
+	}
+	if _, ok := levels[component]; !ok {
+		return fmt.Errorf("unknown log component: %s", component)
+	}
+	levels[component].Set(lv)
+	return nil
+}
+
+// Levels 返回所有组件当前级别
+func Levels() map[string]string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	out := make(map[string]string, len(levels))
+	for c, v := range levels {
+		out[c] = strings.ToLower(v.Level().String())
+	}
+	return out
+}
+
+// Components 返回已注册的组件名，按名称排序
+func Components() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	out := make([]string, 0, len(levels))
+	for c := range levels {
+		out = append(out, c)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ParseLevel 解析 debug/info/warn/error（不区分大小写）
+func ParseLevel(s string) (slog.Level, error) {
+	var lv slog.Level
+	if err := lv.UnmarshalText([]byte(s)); err != nil {
+		return lv, fmt.Errorf("invalid log level %q", s)
+	}
+	return lv, nil
+}
+
+// handler 按组件级别过滤，并附加 ctx 中的请求 ID 和追踪 ID
+type handler struct {
+	inner slog.Handler
+	level *slog.LevelVar
+}
+
+// Enabled 按组件级别判断
+func (h *handler) Enabled(ctx context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+// Handle 附加请求 ID 和追踪 ID 后交给底层 handler
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		if id := reqid.FromContext(ctx); id != "" {
+			r.AddAttrs(slog.String("request_id", id))
+		}
+		if id := reqid.TraceIDFromContext(ctx); id != "" {
+			r.AddAttrs(slog.String("trace_id", id))
+		}
+	}
+	return h.inner.Handle(ctx, r)
+}
+
+// WithAttrs 实现 slog.Handler
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &handler{inner: h.inner.WithAttrs(attrs), level: h.level}
+}
+
+// WithGroup 实现 slog.Handler
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{inner: h.inner.WithGroup(name), level: h.level}
+}
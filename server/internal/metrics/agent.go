@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+const (
+	// defaultPushInterval Agent 默认推送间隔
+	defaultPushInterval = 5 * time.Second
+	// pushTimeout 单次推送的超时时间
+	pushTimeout = 10 * time.Second
+)
+
+// Batch Agent 推送给 Collector 的一批数据
+type Batch struct {
+	Instance string      `json:"instance"` // 实例标识
+	Time     int64       `json:"time"`     // 推送时间戳（毫秒）
+	Samples  []Sample    `json:"samples"`  // 自上次成功推送以来的新样本
+	Routes   []RouteStat `json:"routes"`   // 推送时刻的路由统计
+}
+
+// Agent 定期把本进程的样本和路由统计推送到 Collector
+type Agent struct {
+	tracker  *Tracker
+	instance string
+	url      string // Collector 接收地址，例如 http://host:8099/api/collector/push
+	token    string // 非空时以 Authorization: Bearer 携带
+	interval time.Duration
+	client   *http.Client
+	// propagate 向推送请求写入追踪上下文，为空时不写
+	propagate func(ctx context.Context, h http.Header)
+
+	lastSent int64 // 最后一次成功推送的样本时间戳（毫秒），仅在 Run 协程内访问
+}
+
+// NewAgent 创建新的 Agent 实例
+// token 为 Collector 的 API token，Collector 未启用认证时为空；interval <= 0 时使用默认推送间隔
+func NewAgent(tracker *Tracker, instance, url, token string, interval time.Duration) *Agent {
+	if interval <= 0 {
+		interval = defaultPushInterval
+	}
+	return &Agent{
+		tracker:  tracker,
+		instance: instance,
+		url:      url,
+		token:    token,
+		interval: interval,
+		client:   &http.Client{Timeout: pushTimeout},
+	}
+}
+
+// SetPropagator 设置推送请求的追踪上下文注入函数（如 otlp.Propagate），须在 Run 之前调用
+func (a *Agent) SetPropagator(p func(ctx context.Context, h http.Header)) {
+	a.propagate = p
+}
+
+// Run 按间隔推送数据，直到 ctx 结束；结束时再推送一次剩余样本
+func (a *Agent) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+			if err := a.Push(flushCtx); err != nil {
+				slog.Warn("Failed to push final metrics to collector", "url", a.url, "error", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := a.Push(ctx); err != nil {
+				slog.Warn("Failed to push metrics to collector", "url", a.url, "error", err)
+			}
+		}
+	}
+}
+
+// Push 推送一次数据，失败时保留未发送的样本等待下次重试
+func (a *Agent) Push(ctx context.Context) error {
+	batch := Batch{
+		Instance: a.instance,
+		Time:     time.Now().UnixMilli(),
+		Samples:  a.tracker.SamplesSince(a.lastSent),
+		Routes:   a.tracker.RouteStats(),
+	}
+
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if a.token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.token)
+	}
+	if a.propagate != nil {
+		a.propagate(ctx, req.Header)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector responded %s", resp.Status)
+	}
+
+	if n := len(batch.Samples); n > 0 {
+		a.lastSent = batch.Samples[n-1].Time
+	}
+	return nil
+}
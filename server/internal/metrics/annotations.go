@@ -0,0 +1,356 @@
+package metrics
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	rtmetrics "runtime/metrics"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultAnnotationLogSize 默认保留的注释数
+	defaultAnnotationLogSize = 1000
+	// maxAnnotationTitle、maxAnnotationText、maxAnnotationTags 客户端提交的注释限制
+	maxAnnotationTitle = 200
+	maxAnnotationText  = 2000
+	maxAnnotationTags  = 10
+	// forcedGCMetric 运行时统计的强制 GC 次数（runtime.GC、debug.FreeOSMemory 等）
+	forcedGCMetric = "/gc/cycles/forced:gc-cycles"
+)
+
+// 注释来源
+const (
+	AnnotationClient = "client" // 客户端提交
+	AnnotationServer = "server" // 服务端自动记录
+)
+
+// 服务端自动记录的注释标签
+const (
+	TagStartup  = "startup"
+	TagGC       = "gc"
+	TagProfile  = "profile"
+	TagJob      = "job"
+	TagLoadTest = "loadtest"
+	TagAlert    = "alert"
+)
+
+// errAnnotationNotFound 注释不存在
+var errAnnotationNotFound = errors.New("Annotation not found")
+
+// Annotation 图表时间轴上的事件注释，如部署、配置变更、压测开始结束
+type Annotation struct {
+	ID     uint64   `json:"id"`
+	Time   int64    `json:"time"` // 事件时间戳（毫秒）
+	Title  string   `json:"title"`
+	Text   string   `json:"text,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	Source string   `json:"source"` // client / server
+}
+
+// hasTag 是否带有 tags 中任意一个标签，tags 为空时返回 true
+func (a Annotation) hasTag(tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, t := range a.Tags {
+		if slices.Contains(tags, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// AnnotationLog 按时间排序保存最近的注释，超出容量时丢弃最早的
+// 方法在 nil 接收者上为空操作，便于各组件可选地记录事件
+type AnnotationLog struct {
+	size int
+
+	mu     sync.RWMutex
+	items  []Annotation // 按 Time 升序
+	lastID uint64
+}
+
+// NewAnnotationLog 创建注释日志，size <= 0 时使用默认容量
+func NewAnnotationLog(size int) *AnnotationLog {
+	if size <= 0 {
+		size = defaultAnnotationLogSize
+	}
+	return &AnnotationLog{size: size}
+}
+
+// Add 分配 ID 并保存注释，Time 为 0 时使用当前时间，Source 为空时视为客户端提交
+func (l *AnnotationLog) Add(a Annotation) Annotation {
+	if l == nil {
+		return a
+	}
+	if a.Time == 0 {
+		a.Time = time.Now().UnixMilli()
+	}
+	if a.Source == "" {
+		a.Source = AnnotationClient
+	}
+	a.Tags = slices.Clone(a.Tags)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastID++
+	a.ID = l.lastID
+	// 同一时间戳的注释按提交顺序排列
+	i, _ := slices.BinarySearchFunc(l.items, a.Time+1, func(x Annotation, t int64) int {
+		return cmp.Compare(x.Time, t)
+	})
+	l.items = slices.Insert(l.items, i, a)
+	if len(l.items) > l.size {
+		l.items = slices.Delete(l.items, 0, len(l.items)-l.size)
+	}
+	return a
+}
+
+// Record 记录一条服务端事件
+func (l *AnnotationLog) Record(title string, tags ...string) {
+	if l == nil {
+		return
+	}
+	l.Add(Annotation{Title: title, Tags: tags, Source: AnnotationServer})
+}
+
+// Range 返回时间在 [from, to] 内且带有任一 tags 的注释，tags 为空时不过滤
+func (l *AnnotationLog) Range(from, to int64, tags []string) []Annotation {
+	if l == nil {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	i, _ := slices.BinarySearchFunc(l.items, from, func(x Annotation, t int64) int {
+		return cmp.Compare(x.Time, t)
+	})
+	var out []Annotation
+	for _, a := range l.items[i:] {
+		if a.Time > to {
+			break
+		}
+		if a.hasTag(tags) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// Since 返回 ID 大于 id 的注释（按时间排序），用于推送新增注释
+func (l *AnnotationLog) Since(id uint64) []Annotation {
+	if l == nil {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var out []Annotation
+	for _, a := range l.items {
+		if a.ID > id {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// LastID 返回最近分配的 ID
+func (l *AnnotationLog) LastID() uint64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.lastID
+}
+
+// Delete 删除注释，不存在时返回 false
+func (l *AnnotationLog) Delete(id uint64) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	i := slices.IndexFunc(l.items, func(a Annotation) bool { return a.ID == id })
+	if i < 0 {
+		return false
+	}
+	l.items = slices.Delete(l.items, i, i+1)
+	return true
+}
+
+// WatchForcedGC 每隔 interval 检查运行时的强制 GC 计数，有增加时记录注释，直到 ctx 取消
+func (l *AnnotationLog) WatchForcedGC(ctx context.Context, interval time.Duration) {
+	sample := []rtmetrics.Sample{{Name: forcedGCMetric}}
+	rtmetrics.Read(sample)
+	if sample[0].Value.Kind() != rtmetrics.KindUint64 {
+		slog.Warn("Forced GC metric unavailable", "metric", forcedGCMetric)
+		return
+	}
+	last := sample[0].Value.Uint64()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			rtmetrics.Read(sample)
+			if n := sample[0].Value.Uint64(); n > last {
+				l.Record(fmt.Sprintf("GC forced (%d)", n-last), TagGC)
+				last = n
+			}
+		}
+	}
+}
+
+// sendAnnotations 以 annotation 事件推送 ID 大于 id 的注释，返回新的最大 ID
+func sendAnnotations(w http.ResponseWriter, flusher http.Flusher, l *AnnotationLog, id uint64) uint64 {
+	if l == nil {
+		return id
+	}
+	for _, a := range l.Since(id) {
+		sendAnnotation(w, a)
+		id = max(id, a.ID)
+	}
+	flusher.Flush()
+	return id
+}
+
+// sendAnnotation 写出一个 annotation 事件，由调用方 Flush
+func sendAnnotation(w http.ResponseWriter, a Annotation) {
+	data, err := json.Marshal(a)
+	if err != nil {
+		slog.Error("Failed to marshal annotation", "error", err)
+		return
+	}
+	fmt.Fprintf(w, "event: annotation\ndata: %s\n\n", data)
+}
+
+// HistoryWithAnnotations 历史样本及同一时间窗口内的注释
+type HistoryWithAnnotations struct {
+	Samples     []Sample     `json:"samples"`
+	Annotations []Annotation `json:"annotations"`
+}
+
+// historyWithAnnotations 返回最近 windowSec 秒的样本和注释
+// 注释窗口与样本窗口的终点一致，导入的快照取其最后一个样本的时间
+func (a *API) historyWithAnnotations(src Source, windowSec int, tags []string) HistoryWithAnnotations {
+	end := time.Now().UnixMilli()
+	if ws, ok := src.(windowSource); ok {
+		end = ws.WindowEnd()
+	}
+	out := HistoryWithAnnotations{
+		Samples:     src.HistoryWindow(windowSec),
+		Annotations: a.opts.Annotations.Range(end-int64(windowSec)*1000, end, tags),
+	}
+	if out.Annotations == nil {
+		out.Annotations = []Annotation{}
+	}
+	return out
+}
+
+// parseTags 解析逗号分隔的标签
+func parseTags(v string) []string {
+	return trimTags(strings.Split(v, ","))
+}
+
+// trimTags 去掉标签首尾空白并丢弃空标签
+func trimTags(in []string) []string {
+	var tags []string
+	for _, t := range in {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// ServeAnnotations 查询注释：默认最近窗口（window/minutes/hours），或 from/to 毫秒时间戳；tags 逗号分隔，匹配任一
+func (a *API) ServeAnnotations(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	now := time.Now().UnixMilli()
+	windowSec := ParseWindowSeconds(q, a.opts.MaxWindowSec, a.opts.DefaultWindowSec)
+	from, to := now-int64(windowSec)*1000, now
+	if v := q.Get("from"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, errors.New("from must be a millisecond timestamp"))
+			return
+		}
+		from = n
+	}
+	if v := q.Get("to"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, errors.New("to must be a millisecond timestamp"))
+			return
+		}
+		to = n
+	}
+	out := a.opts.Annotations.Range(from, to, parseTags(q.Get("tags")))
+	if out == nil {
+		out = []Annotation{}
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// annotationRequest 客户端提交的注释
+type annotationRequest struct {
+	Time  int64    `json:"time"` // 毫秒时间戳，默认当前时间
+	Title string   `json:"title"`
+	Text  string   `json:"text"`
+	Tags  []string `json:"tags"`
+}
+
+// ServeAddAnnotation 提交注释，如部署、配置变更
+func (a *API) ServeAddAnnotation(w http.ResponseWriter, r *http.Request) {
+	var req annotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	var errs []error
+	if req.Title == "" || len(req.Title) > maxAnnotationTitle {
+		errs = append(errs, fmt.Errorf("title is required and must be at most %d bytes", maxAnnotationTitle))
+	}
+	if len(req.Text) > maxAnnotationText {
+		errs = append(errs, fmt.Errorf("text must be at most %d bytes", maxAnnotationText))
+	}
+	if len(req.Tags) > maxAnnotationTags {
+		errs = append(errs, fmt.Errorf("at most %d tags are allowed", maxAnnotationTags))
+	}
+	if req.Time < 0 {
+		errs = append(errs, errors.New("time must be a millisecond timestamp"))
+	}
+	if err := errors.Join(errs...); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	ann := a.opts.Annotations.Add(Annotation{
+		Time:  req.Time,
+		Title: req.Title,
+		Text:  req.Text,
+		Tags:  trimTags(req.Tags),
+	})
+	writeJSON(w, http.StatusCreated, ann)
+}
+
+// ServeDeleteAnnotation 删除注释（?id=）
+func (a *API) ServeDeleteAnnotation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || !a.opts.Annotations.Delete(id) {
+		writeError(w, r, http.StatusNotFound, errAnnotationNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
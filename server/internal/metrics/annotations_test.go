@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistoryWithAnnotationsWindowEnd(t *testing.T) {
+	// 导入的快照：窗口以最后一个样本为终点，而不是当前时间
+	last := time.Now().Add(-time.Hour).UnixMilli()
+	imported := NewReadOnlyTracker("imported", Snapshot{History: []Sample{
+		{Time: last - 90_000},
+		{Time: last - 30_000},
+		{Time: last},
+	}})
+
+	// 手动时钟的追踪器：窗口以追踪器时钟为终点
+	clock := newManualClock()
+	clocked := NewTracker(TrackerConfig{Clock: clock})
+	clocked.PushSample()
+	clockNow := clock.Now().UnixMilli()
+
+	annotations := NewAnnotationLog(0)
+	for _, ts := range []int64{last - 120_000, last - 10_000, clockNow - 10_000, clockNow + 10_000} {
+		annotations.Add(Annotation{Time: ts, Title: "event"})
+	}
+	annotations.Add(Annotation{Title: "now"})
+
+	api := NewAPI(clocked, NewHub(), APIOptions{Annotations: annotations})
+	tests := []struct {
+		name        string
+		src         Source
+		wantSamples int
+		wantTimes   []int64
+	}{
+		{"imported", imported, 2, []int64{last - 10_000}},
+		{"manual clock", clocked, 1, []int64{clockNow - 10_000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := api.historyWithAnnotations(tt.src, 60, nil)
+			if len(got.Samples) != tt.wantSamples {
+				t.Errorf("samples = %d, want %d", len(got.Samples), tt.wantSamples)
+			}
+			if len(got.Annotations) != len(tt.wantTimes) {
+				t.Fatalf("annotations = %+v, want times %v", got.Annotations, tt.wantTimes)
+			}
+			for i, a := range got.Annotations {
+				if a.Time != tt.wantTimes[i] {
+					t.Errorf("annotation %d time = %d, want %d", i, a.Time, tt.wantTimes[i])
+				}
+			}
+		})
+	}
+}
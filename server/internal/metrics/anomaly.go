@@ -0,0 +1,315 @@
+package metrics
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAnomalyAlpha     = 0.05
+	defaultAnomalyThreshold = 4
+	defaultAnomalyWarmup    = 30
+	// maxAnomalyEvents 保留的最近异常事件数
+	maxAnomalyEvents = 500
+	// anomalyRouteIdle 路由连续该数量的采样周期没有请求后丢弃其序列
+	anomalyRouteIdle = 300
+	// minRelStdDev、minAbsStdDev 标准差下限（相对均值和绝对值），避免平稳序列的微小波动被放大
+	minRelStdDev = 0.05
+	minAbsStdDev = 0.5
+)
+
+// 路由序列名
+const (
+	SeriesRouteRequests  = "requests"  // 采样周期内的请求数
+	SeriesRouteLatencyMs = "latencyMs" // 采样周期内的平均耗时（毫秒）
+)
+
+// anomalySkipFields 不参与检测的 Sample 字段：时间戳和单调递增的累计值
+var anomalySkipFields = []string{"time", "bytesInTotal", "bytesOutTotal", "numGC"}
+
+// anomalyFields 参与检测的 Sample 数值字段
+var anomalyFields = func() []exportField {
+	t := reflect.TypeFor[Sample]()
+	var out []exportField
+	for _, f := range exportFields(t) {
+		switch t.Field(f.index).Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			if !slices.Contains(anomalySkipFields, f.name) {
+				out = append(out, f)
+			}
+		}
+	}
+	return out
+}()
+
+// AnomalyOptions 异常检测配置，零值字段使用默认值
+type AnomalyOptions struct {
+	Disabled  bool    // 关闭异常检测
+	Alpha     float64 // EWMA 平滑系数，越大越快适应新水平，默认 0.05
+	Threshold float64 // |z| 超过该值视为异常，默认 4
+	Warmup    int     // 序列积累该数量的样本后才开始判定，默认 30
+	// OnEvent 产生异常事件（序列由正常变为异常）时调用，tracker 为追踪器名称，在采样协程中同步执行
+	OnEvent func(tracker string, a Anomaly)
+}
+
+// Anomaly 异常点：序列值偏离其 EWMA 均值超过阈值个标准差
+type Anomaly struct {
+	Seq    uint64  `json:"seq,omitempty"`   // 事件序号，仅事件列表和推送中带出
+	Time   int64   `json:"time"`            // 样本时间戳（毫秒）
+	Series string  `json:"series"`          // Sample 字段名，或路由序列 requests / latencyMs
+	Route  string  `json:"route,omitempty"` // 路由序列所属的路由
+	Value  float64 `json:"value"`
+	Mean   float64 `json:"mean"`   // 判定时的 EWMA 均值
+	StdDev float64 `json:"stdDev"` // 判定时的 EWMA 标准差
+	Z      float64 `json:"z"`
+}
+
+// ewma 指数加权的均值和方差
+type ewma struct {
+	n         int
+	mean      float64
+	variance  float64
+	anomalous bool // 上一个点是否异常，连续异常只在开始时产生事件
+}
+
+// observe 计算 x 相对更新前均值的 z 分数并更新统计，样本不足 warmup 时 ready 为 false
+// 预热期间平滑系数不小于 1/n，使均值尽快收敛到序列水平
+func (e *ewma) observe(x, alpha float64, warmup int) (z, mean, sd float64, ready bool) {
+	if e.n == 0 {
+		e.n, e.mean = 1, x
+		return 0, x, 0, false
+	}
+	mean, sd = e.mean, math.Sqrt(e.variance)
+	z = (x - mean) / max(sd, minRelStdDev*math.Abs(mean), minAbsStdDev)
+	ready = e.n >= warmup
+
+	a := max(alpha, 1/float64(e.n+1))
+	diff := x - e.mean
+	e.mean += a * diff
+	e.variance = (1 - a) * (e.variance + a*diff*diff)
+	e.n++
+	return z, mean, sd, ready
+}
+
+// routeSeries 一个路由的请求数和耗时序列
+type routeSeries struct {
+	requests ewma
+	latency  ewma
+	idle     int // 连续无请求的采样周期数
+}
+
+// routeWindow 当前采样周期内一个路由的请求累计
+type routeWindow struct {
+	count   int
+	latency time.Duration
+}
+
+// anomalyDetector 对 Sample 数值字段和按路由的请求数、耗时做 EWMA z 分数检测
+type anomalyDetector struct {
+	tracker string
+	opts    AnomalyOptions
+
+	mu      sync.Mutex
+	fields  []ewma // 与 anomalyFields 对应
+	routes  map[string]*routeSeries
+	window  map[string]*routeWindow
+	events  []Anomaly // 最近的异常事件
+	lastSeq uint64
+}
+
+// newAnomalyDetector 为追踪器创建检测器，关闭时返回 nil
+func newAnomalyDetector(tracker string, opts AnomalyOptions) *anomalyDetector {
+	if opts.Disabled {
+		return nil
+	}
+	if opts.Alpha <= 0 || opts.Alpha > 1 {
+		opts.Alpha = defaultAnomalyAlpha
+	}
+	if opts.Threshold <= 0 {
+		opts.Threshold = defaultAnomalyThreshold
+	}
+	if opts.Warmup <= 0 {
+		opts.Warmup = defaultAnomalyWarmup
+	}
+	return &anomalyDetector{
+		tracker: tracker,
+		opts:    opts,
+		fields:  make([]ewma, len(anomalyFields)),
+		routes:  make(map[string]*routeSeries),
+		window:  make(map[string]*routeWindow),
+	}
+}
+
+// record 累计一次请求到当前采样周期
+func (d *anomalyDetector) record(info RequestInfo) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	w := d.window[info.Route]
+	if w == nil {
+		w = &routeWindow{}
+		d.window[info.Route] = w
+	}
+	w.count++
+	w.latency += info.Duration
+}
+
+// observe 检测样本和本采样周期的路由序列，返回样本上的异常点
+// 序列从正常变为异常时记录事件并写日志，持续异常的后续点只标注不重复产生事件
+func (d *anomalyDetector) observe(s Sample) []Anomaly {
+	var out, events []Anomaly
+	defer func() {
+		if d.opts.OnEvent != nil {
+			for _, a := range events {
+				d.opts.OnEvent(d.tracker, a)
+			}
+		}
+	}()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	check := func(e *ewma, series, route string, x float64) {
+		z, mean, sd, ready := e.observe(x, d.opts.Alpha, d.opts.Warmup)
+		if !ready || math.Abs(z) < d.opts.Threshold {
+			e.anomalous = false
+			return
+		}
+		a := Anomaly{Time: s.Time, Series: series, Route: route, Value: x, Mean: mean, StdDev: sd, Z: z}
+		out = append(out, a)
+		if !e.anomalous {
+			events = append(events, d.emit(a))
+		}
+		e.anomalous = true
+	}
+
+	v := reflect.ValueOf(s)
+	for i, f := range anomalyFields {
+		check(&d.fields[i], f.name, "", numericValue(v.Field(f.index)))
+	}
+
+	for route := range d.window {
+		if d.routes[route] == nil {
+			d.routes[route] = &routeSeries{}
+		}
+	}
+	for route, rs := range d.routes {
+		w := d.window[route]
+		if w == nil {
+			if rs.idle++; rs.idle > anomalyRouteIdle {
+				delete(d.routes, route)
+				continue
+			}
+			check(&rs.requests, SeriesRouteRequests, route, 0)
+			continue
+		}
+		rs.idle = 0
+		check(&rs.requests, SeriesRouteRequests, route, float64(w.count))
+		check(&rs.latency, SeriesRouteLatencyMs, route, float64(w.latency)/float64(time.Millisecond)/float64(w.count))
+	}
+	clear(d.window)
+	return out
+}
+
+// emit 记录异常事件并返回带序号的事件，调用方需持有 mu
+func (d *anomalyDetector) emit(a Anomaly) Anomaly {
+	d.lastSeq++
+	a.Seq = d.lastSeq
+	d.events = append(d.events, a)
+	if len(d.events) > maxAnomalyEvents {
+		d.events = d.events[len(d.events)-maxAnomalyEvents:]
+	}
+	slog.Warn("Metric anomaly detected", "tracker", d.tracker, "series", a.Series, "route", a.Route, "value", a.Value, "mean", a.Mean, "z", a.Z)
+	return a
+}
+
+// since 返回序号大于 seq 的事件
+func (d *anomalyDetector) since(seq uint64) []Anomaly {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	i, _ := slices.BinarySearchFunc(d.events, seq+1, func(a Anomaly, seq uint64) int {
+		return cmp.Compare(a.Seq, seq)
+	})
+	return slices.Clone(d.events[i:])
+}
+
+// numericValue 把整数或浮点字段转为 float64
+func numericValue(v reflect.Value) float64 {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
+
+// Anomalies 返回序号大于 since 的异常事件（最多保留最近 500 个），未启用检测时返回 nil
+func (t *Tracker) Anomalies(since uint64) []Anomaly {
+	if t.anomalies == nil {
+		return nil
+	}
+	return t.anomalies.since(since)
+}
+
+// anomalySource 支持异常事件的数据源
+type anomalySource interface {
+	Anomalies(since uint64) []Anomaly
+}
+
+// lastAnomalySeq 返回数据源当前最新的异常事件序号
+func lastAnomalySeq(as anomalySource) uint64 {
+	events := as.Anomalies(0)
+	if len(events) == 0 {
+		return 0
+	}
+	return events[len(events)-1].Seq
+}
+
+// ServeAnomalies 获取最近的异常事件，since 为上次取到的最大序号
+func (a *API) ServeAnomalies(w http.ResponseWriter, r *http.Request) {
+	src, err := a.source(r)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	}
+	as, ok := src.(anomalySource)
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, errLocalOnly)
+		return
+	}
+	since, _ := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+	events := as.Anomalies(since)
+	if events == nil {
+		events = []Anomaly{}
+	}
+	writeJSON(w, http.StatusOK, events)
+}
+
+// sendAnomalies 以 anomaly 事件推送序号大于 seq 的异常，返回新的最大序号
+func sendAnomalies(w http.ResponseWriter, flusher http.Flusher, as anomalySource, seq uint64) uint64 {
+	if as == nil {
+		return seq
+	}
+	for _, a := range as.Anomalies(seq) {
+		data, err := json.Marshal(a)
+		if err != nil {
+			slog.Error("Failed to marshal anomaly", "error", err)
+			continue
+		}
+		fmt.Fprintf(w, "event: anomaly\ndata: %s\n\n", data)
+		seq = a.Seq
+	}
+	flusher.Flush()
+	return seq
+}
@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+// feed 向检测器写入 n 个 goroutines 恒为 value 的样本
+func feed(d *anomalyDetector, n, value int) {
+	for range n {
+		d.observe(Sample{Goroutines: value})
+	}
+}
+
+func TestAnomalyWarmup(t *testing.T) {
+	// 恒定序列的标准差取下限 5%·100 = 5，1000 的 z 远超阈值；只有积累 Warmup 个样本后才判定
+	tests := []struct {
+		before int
+		want   int
+	}{
+		{0, 0},
+		{1, 0},
+		{9, 0},
+		{10, 1},
+		{50, 1},
+	}
+	for _, tt := range tests {
+		d := newAnomalyDetector("test", AnomalyOptions{Warmup: 10})
+		feed(d, tt.before, 100)
+		if got := d.observe(Sample{Goroutines: 1000}); len(got) != tt.want {
+			t.Errorf("after %d samples: anomalies = %+v, want %d", tt.before, got, tt.want)
+		}
+	}
+}
+
+func TestAnomalyThreshold(t *testing.T) {
+	// 均值 100，标准差下限 5：119 → z = 3.8，121 → z = 4.2，79 → z = -4.2
+	tests := []struct {
+		name      string
+		threshold float64
+		value     int
+		want      bool
+	}{
+		{"below default threshold", 0, 119, false},
+		{"above default threshold", 0, 121, true},
+		{"drop below mean", 0, 79, true},
+		{"above custom threshold", 3.5, 119, true},
+		{"below custom threshold", 5, 121, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newAnomalyDetector("test", AnomalyOptions{Threshold: tt.threshold, Warmup: 10})
+			feed(d, 20, 100)
+			got := d.observe(Sample{Time: 42, Goroutines: tt.value})
+			if (len(got) == 1) != tt.want || len(got) > 1 {
+				t.Fatalf("anomalies = %+v, want flagged %v", got, tt.want)
+			}
+			if !tt.want {
+				return
+			}
+			a := got[0]
+			wantZ := float64(tt.value-100) / 5
+			if a.Series != "goroutines" || a.Time != 42 || a.Mean != 100 || a.StdDev != 0 || !near(a.Z, wantZ) {
+				t.Errorf("anomaly = %+v, want goroutines z=%v", a, wantZ)
+			}
+		})
+	}
+}
+
+// near 浮点数近似相等
+func near(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
+
+func TestAnomalyEventsOnTransition(t *testing.T) {
+	var events []Anomaly
+	d := newAnomalyDetector("test", AnomalyOptions{Warmup: 10, OnEvent: func(tracker string, a Anomaly) {
+		if tracker != "test" {
+			t.Errorf("OnEvent tracker = %q", tracker)
+		}
+		events = append(events, a)
+	}})
+	feed(d, 20, 100)
+
+	// 连续两个异常点都被标注，但只在开始时产生一个事件
+	if got := d.observe(Sample{Goroutines: 1000}); len(got) != 1 {
+		t.Fatalf("first spike: %+v", got)
+	}
+	if got := d.observe(Sample{Goroutines: 1000}); len(got) != 1 {
+		t.Fatalf("second spike: %+v", got)
+	}
+	if len(events) != 1 || events[0].Seq != 1 {
+		t.Fatalf("events = %+v, want one with seq 1", events)
+	}
+
+	// 回落到正常水平后再次异常，产生新事件
+	feed(d, 1, 100)
+	d.observe(Sample{Goroutines: -5000})
+	if len(events) != 2 || events[1].Seq != 2 {
+		t.Fatalf("events = %+v, want a second event", events)
+	}
+	if got := d.since(1); len(got) != 1 || got[0].Seq != 2 {
+		t.Errorf("since(1) = %+v", got)
+	}
+	if got := d.since(2); len(got) != 0 {
+		t.Errorf("since(2) = %+v", got)
+	}
+}
+
+func TestAnomalyRouteSeries(t *testing.T) {
+	d := newAnomalyDetector("test", AnomalyOptions{Warmup: 10})
+	period := func(n int, latency time.Duration) []Anomaly {
+		for range n {
+			d.record(RequestInfo{Route: "/api/posts", Duration: latency})
+		}
+		return d.observe(Sample{})
+	}
+	for range 20 {
+		if got := period(10, 10*time.Millisecond); len(got) != 0 {
+			t.Fatalf("steady traffic flagged: %+v", got)
+		}
+	}
+
+	got := period(10, time.Second)
+	if len(got) != 1 || got[0].Series != SeriesRouteLatencyMs || got[0].Route != "/api/posts" || got[0].Value != 1000 {
+		t.Fatalf("latency spike: anomalies = %+v", got)
+	}
+
+	// 路由突然没有请求：请求数序列记为 0，偏离均值
+	got = d.observe(Sample{})
+	if len(got) != 1 || got[0].Series != SeriesRouteRequests || got[0].Value != 0 {
+		t.Errorf("traffic stop: anomalies = %+v", got)
+	}
+}
+
+func TestAnomalyDisabled(t *testing.T) {
+	if d := newAnomalyDetector("test", AnomalyOptions{Disabled: true}); d != nil {
+		t.Error("disabled detector is not nil")
+	}
+	d := newAnomalyDetector("test", AnomalyOptions{Alpha: 2, Threshold: -1, Warmup: -1})
+	if d.opts.Alpha != defaultAnomalyAlpha || d.opts.Threshold != defaultAnomalyThreshold || d.opts.Warmup != defaultAnomalyWarmup {
+		t.Errorf("invalid options not defaulted: %+v", d.opts)
+	}
+}
@@ -0,0 +1,541 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"analyseGo/internal/reqid"
+)
+
+const (
+	defaultAPIWindowSec = 600   // 默认10分钟
+	maxAPIWindowSec     = 86400 // 最大24小时
+	// reconnectDelayMs 服务关闭时建议 SSE 客户端的重连间隔（毫秒）
+	reconnectDelayMs = 1000
+)
+
+// errInstanceNotFound collector 中不存在请求的实例
+var errInstanceNotFound = errors.New("Instance not found")
+
+// errTrackerNotFound 注册表中不存在请求的追踪器
+var errTrackerNotFound = errors.New("Tracker not found")
+
+// errLocalOnly 接口只支持本地追踪器，不支持 collector 视图
+var errLocalOnly = errors.New("Only available for local trackers")
+
+// errProfileForbidden 当前连接无权执行 profile 命令
+var errProfileForbidden = errors.New("Profiling not permitted")
+
+// errRequestLogDisabled 未配置 RequestLog
+var errRequestLogDisabled = errors.New("Request log not enabled")
+
+// errRegistryDisabled 未配置 Registry
+var errRegistryDisabled = errors.New("Tracker registry not enabled")
+
+// rateSource 支持多窗口速率的数据源
+type rateSource interface {
+	Rates() RateStats
+}
+
+// cardinalitySource 支持路由基数统计的数据源
+type cardinalitySource interface {
+	RouteCardinality() RouteCardinality
+}
+
+// sizeSource 支持大小分布的数据源
+type sizeSource interface {
+	Sizes() SizeStats
+}
+
+// windowSource 历史窗口终点不是当前系统时间的数据源（手动时钟、导入的快照）
+type windowSource interface {
+	WindowEnd() int64
+}
+
+// totalsSource 支持累计统计的数据源
+type totalsSource interface {
+	Totals() TotalsSnapshot
+	ResetTotals() TotalsSnapshot
+}
+
+// APIOptions 指标 API 的可选配置，零值使用默认值
+type APIOptions struct {
+	Collector        *Collector     // 非空时启用 instance 参数和汇聚接口
+	RequestLog       *RequestLog    // 非空时启用请求日志查询接口
+	Annotations      *AnnotationLog // 非空时启用注释接口，历史查询和实时推送带出注释
+	Registry         *Registry      // 非空时启用 tracker 参数和追踪器列表接口
+	Interval         time.Duration  // SSE/WebSocket 定时推送间隔，默认1秒
+	DefaultWindowSec int            // 历史查询默认窗口（秒），默认600
+	MaxWindowSec     int            // 历史查询最大窗口（秒），默认86400
+	// WSOrigins 允许建立 WebSocket 连接的跨站来源，如 http://localhost:5173；同源和非浏览器客户端总是允许，不支持 *
+	WSOrigins []string
+	// CanProfile 判断 WebSocket 连接能否执行 profile 命令，参数为握手请求；为空时不限制
+	CanProfile func(*http.Request) bool
+	// Principal 返回请求的调用方标识，回放会话只接受创建者的控制请求；为空时不校验
+	Principal func(*http.Request) string
+}
+
+// API 基于 net/http 的指标接口实现
+// 可通过 Handler 整体挂载，也可把单个 Serve* 方法注册到其他路由框架
+type API struct {
+	tracker *Tracker
+	hub     *Hub
+	opts    APIOptions
+	replays replayRegistry // 进行中的 SSE 回放会话
+}
+
+// NewAPI 创建新的指标 API 实例
+func NewAPI(tracker *Tracker, hub *Hub, opts APIOptions) *API {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.DefaultWindowSec <= 0 {
+		opts.DefaultWindowSec = defaultAPIWindowSec
+	}
+	if opts.MaxWindowSec <= 0 {
+		opts.MaxWindowSec = maxAPIWindowSec
+	}
+	return &API{tracker: tracker, hub: hub, opts: opts}
+}
+
+// Handler 返回包含全部指标接口的 http.Handler，路径相对于挂载点：
+//
+//	GET  /metrics              当前指标快照
+//	GET  /metrics/history      历史指标（window/minutes/hours），annotations=1 时一并返回注释
+//	GET  /metrics/routes       按路由统计
+//	GET  /metrics/rates        多窗口请求数与 QPS（10s/1m/5m）
+//	GET  /metrics/routes/cardinality  路由基数统计
+//	GET  /metrics/routes/totals        按路由累计统计
+//	GET  /metrics/routes/sizes         请求体/响应体大小分布
+//	POST /metrics/routes/totals/reset  返回累计统计快照并清零
+//	GET  /metrics/export/history       导出历史指标（format=csv/ndjson、fields）
+//	GET  /metrics/export/routes        导出按路由统计（format=csv/ndjson、fields）
+//	GET  /metrics/snapshot             下载快照包（历史、路由统计、goroutine 栈、堆 profile）
+//	GET  /metrics/stream       SSE 实时推送；replay=1 时回放历史（from、speed、paused）
+//	POST /metrics/stream/replay  控制回放会话（session；pause/resume/seek/speed）
+//	GET  /metrics/ws           WebSocket 实时推送与命令
+//	GET  /metrics/anomalies    最近的异常事件（since）
+//	GET  /metrics/trackers     命名追踪器列表（需 Registry）
+//	POST /metrics/import       导入快照包为只读追踪器（需 Registry，collector 不支持）
+//	DELETE /metrics/import     删除导入的只读追踪器（name）
+//	GET  /metrics/annotations  注释查询（window/from/to、tags，需 Annotations）
+//	POST /metrics/annotations  提交注释（需 Annotations）
+//	DELETE /metrics/annotations  删除注释（id，需 Annotations）
+//	GET  /metrics/requests     请求日志 / 慢请求查询（需 RequestLog）
+//	GET  /metrics/requests/thresholds  慢请求阈值（需 RequestLog）
+//	PUT  /metrics/requests/thresholds  设置慢请求阈值（需 RequestLog）
+//	POST /collector/push       接收 Agent 推送（仅 collector）
+//	GET  /collector/instances  实例列表（仅 collector）
+//
+// 例如挂载到 /api 下：mux.Handle("/api/", http.StripPrefix("/api", api.Handler()))
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", a.ServeSample)
+	mux.HandleFunc("GET /metrics/history", a.ServeHistory)
+	mux.HandleFunc("GET /metrics/routes", a.ServeRoutes)
+	mux.HandleFunc("GET /metrics/rates", a.ServeRates)
+	mux.HandleFunc("GET /metrics/routes/cardinality", a.ServeRouteCardinality)
+	mux.HandleFunc("GET /metrics/routes/totals", a.ServeRouteTotals)
+	mux.HandleFunc("GET /metrics/routes/sizes", a.ServeRouteSizes)
+	mux.HandleFunc("POST /metrics/routes/totals/reset", a.ServeResetRouteTotals)
+	mux.HandleFunc("GET /metrics/export/history", a.ServeExportHistory)
+	mux.HandleFunc("GET /metrics/export/routes", a.ServeExportRoutes)
+	mux.HandleFunc("GET /metrics/snapshot", a.ServeSnapshot)
+	mux.HandleFunc("GET /metrics/stream", a.ServeStream)
+	mux.HandleFunc("POST /metrics/stream/replay", a.ServeReplayControl)
+	mux.HandleFunc("GET /metrics/ws", a.ServeWS)
+	mux.HandleFunc("GET /metrics/anomalies", a.ServeAnomalies)
+	if a.opts.Registry != nil {
+		mux.HandleFunc("GET /metrics/trackers", a.ServeTrackers)
+		mux.HandleFunc("POST /metrics/import", a.ServeImport)
+		mux.HandleFunc("DELETE /metrics/import", a.ServeDeleteImport)
+	}
+	if a.opts.Annotations != nil {
+		mux.HandleFunc("GET /metrics/annotations", a.ServeAnnotations)
+		mux.HandleFunc("POST /metrics/annotations", a.ServeAddAnnotation)
+		mux.HandleFunc("DELETE /metrics/annotations", a.ServeDeleteAnnotation)
+	}
+	if a.opts.RequestLog != nil {
+		mux.HandleFunc("GET /metrics/requests", a.ServeRequests)
+		mux.HandleFunc("GET /metrics/requests/thresholds", a.ServeSlowThresholds)
+		mux.HandleFunc("PUT /metrics/requests/thresholds", a.ServeSetSlowThreshold)
+	}
+	if a.opts.Collector != nil {
+		mux.HandleFunc("POST /collector/push", a.ServeCollectorPush)
+		mux.HandleFunc("GET /collector/instances", a.ServeCollectorInstances)
+	}
+	return mux
+}
+
+// source 根据 instance / tracker 参数选择指标数据源
+// collector 模式下未指定 instance 时返回集群聚合视图；未指定 tracker 时返回默认追踪器
+func (a *API) source(r *http.Request) (Source, error) {
+	if a.opts.Collector == nil {
+		name := r.URL.Query().Get("tracker")
+		if name == "" || a.opts.Registry == nil {
+			return a.tracker, nil
+		}
+		t, ok := a.opts.Registry.Get(name)
+		if !ok {
+			return nil, errTrackerNotFound
+		}
+		return t, nil
+	}
+	instance := r.URL.Query().Get("instance")
+	if instance == "" || instance == "all" {
+		return a.opts.Collector.Aggregate(), nil
+	}
+	src, ok := a.opts.Collector.Instance(instance)
+	if !ok {
+		return nil, errInstanceNotFound
+	}
+	return src, nil
+}
+
+// ServeSample 获取当前指标快照
+func (a *API) ServeSample(w http.ResponseWriter, r *http.Request) {
+	src, err := a.source(r)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, src.CurrentSample())
+}
+
+// ServeHistory 获取历史指标数据
+func (a *API) ServeHistory(w http.ResponseWriter, r *http.Request) {
+	src, err := a.source(r)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	}
+	q := r.URL.Query()
+	windowSec := ParseWindowSeconds(q, a.opts.MaxWindowSec, a.opts.DefaultWindowSec)
+	if q.Get("annotations") == "1" && a.opts.Annotations != nil {
+		writeJSON(w, http.StatusOK, a.historyWithAnnotations(src, windowSec, parseTags(q.Get("tags"))))
+		return
+	}
+	writeJSON(w, http.StatusOK, src.HistoryWindow(windowSec))
+}
+
+// ServeRoutes 获取按路由统计的指标
+func (a *API) ServeRoutes(w http.ResponseWriter, r *http.Request) {
+	src, err := a.source(r)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, src.RouteStats())
+}
+
+// ServeRates 获取全局和按路由的多窗口请求速率
+func (a *API) ServeRates(w http.ResponseWriter, r *http.Request) {
+	src, err := a.source(r)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	}
+	rs, ok := src.(rateSource)
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, errLocalOnly)
+		return
+	}
+	writeJSON(w, http.StatusOK, rs.Rates())
+}
+
+// ServeRouteCardinality 获取路由基数统计
+func (a *API) ServeRouteCardinality(w http.ResponseWriter, r *http.Request) {
+	src, err := a.source(r)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	}
+	cs, ok := src.(cardinalitySource)
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, errLocalOnly)
+		return
+	}
+	writeJSON(w, http.StatusOK, cs.RouteCardinality())
+}
+
+// ServeRouteSizes 获取全局和按路由的请求体、响应体大小分布
+func (a *API) ServeRouteSizes(w http.ResponseWriter, r *http.Request) {
+	src, err := a.source(r)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	}
+	ss, ok := src.(sizeSource)
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, errLocalOnly)
+		return
+	}
+	writeJSON(w, http.StatusOK, ss.Sizes())
+}
+
+// ServeRouteTotals 获取按路由累计统计
+func (a *API) ServeRouteTotals(w http.ResponseWriter, r *http.Request) {
+	ts, ok := a.totalsFor(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, ts.Totals())
+}
+
+// ServeResetRouteTotals 返回累计统计快照并清零
+func (a *API) ServeResetRouteTotals(w http.ResponseWriter, r *http.Request) {
+	ts, ok := a.totalsFor(w, r)
+	if !ok {
+		return
+	}
+	snap := ts.ResetTotals()
+	slog.InfoContext(r.Context(), "Route totals reset", "since", snap.Since, "routes", len(snap.Routes))
+	writeJSON(w, http.StatusOK, snap)
+}
+
+// totalsFor 选择支持累计统计的数据源，失败时写出错误响应
+func (a *API) totalsFor(w http.ResponseWriter, r *http.Request) (totalsSource, bool) {
+	src, err := a.source(r)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err)
+		return nil, false
+	}
+	ts, ok := src.(totalsSource)
+	if !ok {
+		writeError(w, r, http.StatusNotImplemented, errLocalOnly)
+		return nil, false
+	}
+	return ts, true
+}
+
+// ServeTrackers 获取命名追踪器列表
+func (a *API) ServeTrackers(w http.ResponseWriter, r *http.Request) {
+	if a.opts.Registry == nil {
+		writeError(w, r, http.StatusNotFound, errRegistryDisabled)
+		return
+	}
+	writeJSON(w, http.StatusOK, a.opts.Registry.Names())
+}
+
+// ServeStream SSE 流式推送实时指标，本地追踪器新产生的异常以 anomaly 事件推送，新注释以 annotation 事件推送
+// replay=1 时从 from（毫秒时间戳，默认最早的样本）开始按 speed 倍速回放历史样本，
+// 先推送 replay 事件告知会话 ID，之后可通过 ServeReplayControl 暂停、继续、跳转和调速
+func (a *API) ServeStream(w http.ResponseWriter, r *http.Request) {
+	src, err := a.source(r)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	}
+
+	q := r.URL.Query()
+	replay := q.Get("replay") == "1"
+	var from int64
+	var speed float64
+	if replay {
+		if v := q.Get("from"); v != "" {
+			if from, err = strconv.ParseInt(v, 10, 64); err != nil {
+				writeError(w, r, http.StatusBadRequest, errors.New("from must be a millisecond timestamp"))
+				return
+			}
+		}
+		if speed, err = parseReplaySpeed(q.Get("speed")); err != nil {
+			writeError(w, r, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, http.StatusInternalServerError, errors.New("streaming unsupported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	if replay {
+		a.serveReplay(w, r, flusher, src, from, speed)
+		return
+	}
+
+	// 订阅通知通道
+	ch := a.hub.Subscribe()
+	defer a.hub.Unsubscribe(ch)
+
+	// 异常事件和注释只推送连接建立之后产生的
+	as, _ := src.(anomalySource)
+	var anomalySeq uint64
+	if as != nil {
+		anomalySeq = lastAnomalySeq(as)
+	}
+	annotationID := a.opts.Annotations.LastID()
+
+	// 定时推送
+	ticker := time.NewTicker(a.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-a.hub.Done():
+			// 服务关闭：告知客户端稍后重连到其他实例或重启后的实例
+			fmt.Fprintf(w, "retry: %d\nevent: shutdown\ndata: {}\n\n", reconnectDelayMs)
+			flusher.Flush()
+			return
+		case <-ticker.C:
+			sendSample(w, flusher, src.CurrentSample())
+			anomalySeq = sendAnomalies(w, flusher, as, anomalySeq)
+			annotationID = sendAnnotations(w, flusher, a.opts.Annotations, annotationID)
+		case <-ch:
+			// 有新请求时立即推送
+			sendSample(w, flusher, src.CurrentSample())
+		}
+	}
+}
+
+// ServeRequests 查询请求日志，slow=1 时查询慢请求（含栈快照）
+// 过滤参数：route、method、path、status、minStatus、minLatencyMs、requestId、traceId、since（毫秒时间戳）、limit
+func (a *API) ServeRequests(w http.ResponseWriter, r *http.Request) {
+	if a.opts.RequestLog == nil {
+		writeError(w, r, http.StatusNotFound, errRequestLogDisabled)
+		return
+	}
+	q := r.URL.Query()
+	f := RequestFilter{
+		Route:     q.Get("route"),
+		Method:    q.Get("method"),
+		Path:      q.Get("path"),
+		RequestID: q.Get("requestId"),
+		TraceID:   q.Get("traceId"),
+	}
+	f.Status, _ = strconv.Atoi(q.Get("status"))
+	f.MinStatus, _ = strconv.Atoi(q.Get("minStatus"))
+	f.Limit, _ = strconv.Atoi(q.Get("limit"))
+	f.Since, _ = strconv.ParseInt(q.Get("since"), 10, 64)
+	if ms, err := strconv.ParseFloat(q.Get("minLatencyMs"), 64); err == nil && ms > 0 {
+		f.MinLatency = time.Duration(ms * float64(time.Millisecond))
+	}
+
+	if slow, _ := strconv.ParseBool(q.Get("slow")); slow {
+		writeJSON(w, http.StatusOK, a.opts.RequestLog.QuerySlow(f))
+		return
+	}
+	writeJSON(w, http.StatusOK, a.opts.RequestLog.Query(f))
+}
+
+// ServeSlowThresholds 获取慢请求阈值（毫秒），空字符串键为默认阈值
+func (a *API) ServeSlowThresholds(w http.ResponseWriter, r *http.Request) {
+	if a.opts.RequestLog == nil {
+		writeError(w, r, http.StatusNotFound, errRequestLogDisabled)
+		return
+	}
+	writeJSON(w, http.StatusOK, a.opts.RequestLog.SlowThresholds())
+}
+
+// slowThresholdRequest 设置慢请求阈值的请求体
+type slowThresholdRequest struct {
+	Route       string  `json:"route"`       // 为空时设置默认阈值
+	ThresholdMs float64 `json:"thresholdMs"` // <= 0 表示关闭该路由的慢请求检测
+}
+
+// ServeSetSlowThreshold 设置路由的慢请求阈值
+func (a *API) ServeSetSlowThreshold(w http.ResponseWriter, r *http.Request) {
+	if a.opts.RequestLog == nil {
+		writeError(w, r, http.StatusNotFound, errRequestLogDisabled)
+		return
+	}
+	var req slowThresholdRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	a.opts.RequestLog.SetSlowThreshold(req.Route, time.Duration(req.ThresholdMs*float64(time.Millisecond)))
+	writeJSON(w, http.StatusOK, a.opts.RequestLog.SlowThresholds())
+}
+
+// ServeCollectorPush 接收 Agent 推送的指标数据
+func (a *API) ServeCollectorPush(w http.ResponseWriter, r *http.Request) {
+	var batch Batch
+	if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if batch.Instance == "" {
+		writeError(w, r, http.StatusBadRequest, errors.New("instance is required"))
+		return
+	}
+	if err := a.opts.Collector.Ingest(batch); err != nil {
+		writeError(w, r, http.StatusTooManyRequests, err)
+		return
+	}
+	a.hub.Notify()
+	writeJSON(w, http.StatusOK, map[string]int{"accepted": len(batch.Samples)})
+}
+
+// ServeCollectorInstances 获取已上报的实例列表
+func (a *API) ServeCollectorInstances(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.opts.Collector.Instances())
+}
+
+// sendSample 发送单个 SSE 样本数据
+func sendSample(w http.ResponseWriter, flusher http.Flusher, sample Sample) {
+	data, err := json.Marshal(sample)
+	if err != nil {
+		slog.Error("Failed to marshal sample", "error", err)
+		return
+	}
+	fmt.Fprintf(w, "data: %s\n\n", string(data))
+	flusher.Flush()
+}
+
+// writeJSON 以 JSON 格式写出响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("Failed to write response", "error", err)
+	}
+}
+
+// writeError 以 {"error": "...", "requestId": "..."} 格式写出错误响应，与 gin 接口保持一致
+func writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	body := map[string]string{"error": err.Error()}
+	if id := reqid.FromContext(r.Context()); id != "" {
+		body["requestId"] = id
+	}
+	writeJSON(w, status, body)
+}
+
+// ParseWindowSeconds 解析时间窗口参数（支持 window/minutes/hours）
+// 参数优先级：window > minutes > hours
+// 如果所有参数都无效，返回 defaultWindowSec
+// 如果解析的值超过 maxWindowSec，则限制为 maxWindowSec
+func ParseWindowSeconds(q url.Values, maxWindowSec, defaultWindowSec int) int {
+	units := []struct {
+		key string
+		sec int
+	}{
+		{"window", 1},
+		{"minutes", 60},
+		{"hours", 3600},
+	}
+	for _, u := range units {
+		if v, err := strconv.Atoi(q.Get(u.key)); err == nil && v > 0 {
+			sec := v * u.sec
+			if sec > maxWindowSec {
+				sec = maxWindowSec
+			}
+			return sec
+		}
+	}
+	return defaultWindowSec
+}
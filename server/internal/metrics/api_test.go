@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptionalHandlersDisabled(t *testing.T) {
+	// 直接挂载处理函数（如 main 中经 gin 注册）时，未配置的依赖返回 404 而不是 panic
+	api := NewAPI(NewTracker(TrackerConfig{}), NewHub(), APIOptions{})
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		body    string
+		want    error
+	}{
+		{"requests", api.ServeRequests, http.MethodGet, "", errRequestLogDisabled},
+		{"thresholds", api.ServeSlowThresholds, http.MethodGet, "", errRequestLogDisabled},
+		{"set threshold", api.ServeSetSlowThreshold, http.MethodPut, `{"route":"/a","thresholdMs":100}`, errRequestLogDisabled},
+		{"trackers", api.ServeTrackers, http.MethodGet, "", errRegistryDisabled},
+		{"annotations", api.ServeAnnotations, http.MethodGet, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tt.handler(w, httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body)))
+			if tt.want == nil {
+				if w.Code != http.StatusOK {
+					t.Errorf("status = %d, want 200", w.Code)
+				}
+				return
+			}
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want 404", w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.want.Error()) {
+				t.Errorf("body = %s, want %q", w.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSlowThresholdHandlers(t *testing.T) {
+	api := NewAPI(NewTracker(TrackerConfig{}), NewHub(), APIOptions{RequestLog: NewRequestLog(10, 10, time.Second)})
+
+	w := httptest.NewRecorder()
+	api.ServeSetSlowThreshold(w, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"route":"/api/a","thresholdMs":250}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("set: status = %d, body %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	api.ServeSlowThresholds(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	var got map[string]float64
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["/api/a"] != 250 || got[""] != 1000 {
+		t.Errorf("thresholds = %v, want /api/a=250 and default 1000", got)
+	}
+
+	w = httptest.NewRecorder()
+	api.ServeSetSlowThreshold(w, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad body: status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,186 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	pprof "runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// reGoroutineHeader 匹配栈转储的头部行，如 `goroutine 7 [chan receive, 2 minutes] {job: x1, route: "/a b"}:`
+	reGoroutineHeader = regexp.MustCompile(`^goroutine\s+\d+\s+\[(.*?)\](?:\s+\{(.*)\})?:$`)
+	// reWaitDuration 匹配状态中的等待时长，转储只在等待≥1分钟时给出
+	reWaitDuration = regexp.MustCompile(`(\d+)\s*(minute|minutes|second|seconds)`)
+)
+
+// ErrLabelsUnavailable 栈转储头部行不带 pprof 标签，无法按路由或任务统计 goroutine
+// Go 1.27 之前的模块默认关闭该设置，嵌入本包的服务需自行在主模块 go.mod 中加 godebug tracebacklabels=1
+// 或以 GODEBUG=tracebacklabels=1 运行
+var ErrLabelsUnavailable = errors.New("Goroutine labels unavailable: set GODEBUG=tracebacklabels=1")
+
+// probeLabel 探测标签是否出现在栈转储中所用的标签键
+const probeLabel = "analysego_probe"
+
+// LabelsAvailable 栈转储头部行是否带 pprof 标签，首次调用时起一个带标签的 goroutine 探测一次
+var LabelsAvailable = sync.OnceValue(func() bool {
+	started, stop := make(chan struct{}), make(chan struct{})
+	defer close(stop)
+	go pprof.Do(context.Background(), pprof.Labels(probeLabel, "1"), func(context.Context) {
+		close(started)
+		<-stop
+	})
+	<-started
+	for _, g := range dumpGoroutines() {
+		if _, ok := g.label(probeLabel); ok {
+			return true
+		}
+	}
+	return false
+})
+
+// LabelBlocks 带某个 pprof 标签值的 goroutine 数及阻塞分类
+type LabelBlocks struct {
+	Goroutines int `json:"goroutines"` // goroutine 数
+	BlockLock  int `json:"blockLock"`  // 锁阻塞数
+	BlockIO    int `json:"blockIO"`    // IO阻塞数
+	BlockPerm  int `json:"blockPerm"`  // 持续≥10秒阻塞数
+}
+
+// goroutineState 从栈转储头部行解析出的 goroutine 状态
+type goroutineState struct {
+	state   string // 小写的状态，如 "chan receive, 2 minutes"
+	waitSec int    // 等待时长（秒）
+	labels  string // 未解析的标签，如 `job: x1, route: "/a b"`
+}
+
+// dumpGoroutines 解析当前所有 goroutine 的状态和标签
+func dumpGoroutines() []goroutineState {
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 2) // 深度1按栈聚合、不含状态行，须用深度2
+
+	var out []goroutineState
+	for _, line := range strings.Split(buf.String(), "\n") {
+		m := reGoroutineHeader.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		g := goroutineState{state: strings.ToLower(m[1]), labels: m[2]}
+		// 解析持续时间
+		if dm := reWaitDuration.FindStringSubmatch(g.state); dm != nil {
+			if v, err := strconv.Atoi(dm[1]); err == nil {
+				if strings.HasPrefix(dm[2], "minute") {
+					g.waitSec = v * 60
+				} else {
+					g.waitSec = v
+				}
+			}
+		}
+		out = append(out, g)
+	}
+	return out
+}
+
+// classify 返回 goroutine 属于哪些阻塞分类（锁、IO、持续≥10秒）
+func (g goroutineState) classify() (lock, io, perm bool) {
+	lock = isLockWait(g.state)
+	io = strings.Contains(g.state, "io wait") || strings.Contains(g.state, "syscall")
+	perm = g.waitSec >= 10 && (lock || io ||
+		strings.Contains(g.state, "chan receive") ||
+		strings.Contains(g.state, "chan send") ||
+		strings.Contains(g.state, "select"))
+	return
+}
+
+// label 返回标签 key 的值
+// 标签格式为 `k1: v1, k2: "v 2"`，含特殊字符的键值带引号
+func (g goroutineState) label(key string) (string, bool) {
+	rest := g.labels
+	for rest != "" {
+		k, r, ok := labelToken(rest)
+		if !ok || !strings.HasPrefix(r, ": ") {
+			return "", false
+		}
+		v, r, ok := labelToken(r[2:])
+		if !ok {
+			return "", false
+		}
+		if k == key {
+			return v, true
+		}
+		rest = strings.TrimPrefix(r, ", ")
+	}
+	return "", false
+}
+
+// labelToken 读取一个可能带引号的键或值，返回其内容和剩余部分
+func labelToken(s string) (tok, rest string, ok bool) {
+	if strings.HasPrefix(s, `"`) {
+		q, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", "", false
+		}
+		tok, err = strconv.Unquote(q)
+		return tok, s[len(q):], err == nil
+	}
+	end := len(s)
+	if i := strings.IndexAny(s, ":,"); i >= 0 {
+		end = i
+	}
+	return s[:end], s[end:], true
+}
+
+// isLockWait 状态是否为锁等待
+// Go 1.20 起 sync.Mutex/RWMutex 的等待显示为 "sync.Mutex.Lock" 等，其余信号量等待仍为 "semacquire"
+func isLockWait(state string) bool {
+	return strings.Contains(state, "semacquire") ||
+		strings.Contains(state, "sync.mutex.lock") ||
+		strings.Contains(state, "sync.rwmutex.")
+}
+
+// classifyBlocks 分析并分类当前 goroutine 的阻塞状态
+// 返回: (锁阻塞数, IO阻塞数, 持续≥10秒阻塞数)
+func classifyBlocks() (lock int, io int, perm int) {
+	for _, g := range dumpGoroutines() {
+		l, i, p := g.classify()
+		lock += b2i(l)
+		io += b2i(i)
+		perm += b2i(p)
+	}
+	return
+}
+
+// BlocksByLabel 按 pprof 标签 key 的取值统计 goroutine 数和阻塞分类
+// 没有该标签的 goroutine 不计入；栈转储不带标签时返回 ErrLabelsUnavailable，而不是空统计
+func BlocksByLabel(key string) (map[string]LabelBlocks, error) {
+	if !LabelsAvailable() {
+		return nil, ErrLabelsUnavailable
+	}
+	stats := make(map[string]LabelBlocks)
+	for _, g := range dumpGoroutines() {
+		v, ok := g.label(key)
+		if !ok {
+			continue
+		}
+		l, i, p := g.classify()
+		cur := stats[v]
+		cur.Goroutines++
+		cur.BlockLock += b2i(l)
+		cur.BlockIO += b2i(i)
+		cur.BlockPerm += b2i(p)
+		stats[v] = cur
+	}
+	return stats, nil
+}
+
+// b2i 布尔值转为 0/1
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
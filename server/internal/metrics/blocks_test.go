@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"context"
+	pprof "runtime/pprof"
+	"testing"
+)
+
+func TestBlocksByLabel(t *testing.T) {
+	// 本模块的 go.mod 设置了 godebug tracebacklabels=1，测试二进制同样生效
+	if !LabelsAvailable() {
+		t.Fatal("labels unavailable despite godebug tracebacklabels=1 in go.mod")
+	}
+	started, stop := make(chan struct{}), make(chan struct{})
+	defer close(stop)
+	for range 3 {
+		go pprof.Do(t.Context(), pprof.Labels("route", "/api/a b"), func(context.Context) {
+			started <- struct{}{}
+			<-stop
+		})
+		<-started
+	}
+
+	blocks, err := BlocksByLabel("route")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := blocks["/api/a b"].Goroutines; got != 3 {
+		t.Errorf("goroutines = %d, want 3 (all: %+v)", got, blocks)
+	}
+}
+
+func TestGoroutineLabel(t *testing.T) {
+	g := goroutineState{labels: `job: x1, route: "/a, b: c", "odd key": v`}
+	tests := []struct {
+		key, want string
+		ok        bool
+	}{
+		{"job", "x1", true},
+		{"route", "/a, b: c", true},
+		{"odd key", "v", true},
+		{"missing", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := g.label(tt.key); got != tt.want || ok != tt.ok {
+			t.Errorf("label(%q) = %q, %v; want %q, %v", tt.key, got, ok, tt.want, tt.ok)
+		}
+	}
+}
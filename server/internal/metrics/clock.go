@@ -0,0 +1,36 @@
+package metrics
+
+import "time"
+
+// Clock 时间来源，测试或回放时可替换为手动推进的实现
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker 与 time.Ticker 对应的最小接口
+type Ticker interface {
+	C() <-chan time.Time
+	Reset(d time.Duration)
+	Stop()
+}
+
+// SystemClock 基于系统时间的 Clock
+type SystemClock struct{}
+
+// Now 返回当前系统时间
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// NewTicker 创建 time.Ticker
+func (SystemClock) NewTicker(d time.Duration) Ticker {
+	return systemTicker{time.NewTicker(d)}
+}
+
+// systemTicker 包装 time.Ticker
+type systemTicker struct {
+	t *time.Ticker
+}
+
+func (s systemTicker) C() <-chan time.Time   { return s.t.C }
+func (s systemTicker) Reset(d time.Duration) { s.t.Reset(d) }
+func (s systemTicker) Stop()                 { s.t.Stop() }
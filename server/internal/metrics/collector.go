@@ -0,0 +1,318 @@
+package metrics
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// instanceStaleAfter 超过该时长未推送的实例不计入集群当前值
+	instanceStaleAfter = 30 * time.Second
+	// instanceEvictAfter 超过该时长未推送的实例连同历史一起删除
+	instanceEvictAfter = 10 * time.Minute
+	// maxInstances Collector 最多保存的实例数，超过后拒绝新实例的推送
+	maxInstances = 256
+)
+
+// errTooManyInstances 实例数已达上限，且没有可淘汰的空闲实例
+var errTooManyInstances = errors.New("Too many instances")
+
+// Source 指标数据源，Tracker 和 Collector 的视图都实现该接口
+type Source interface {
+	CurrentSample() Sample
+	HistoryWindow(seconds int) []Sample
+	RouteStats() []RouteStat
+}
+
+// InstanceInfo 实例概况
+type InstanceInfo struct {
+	Instance string `json:"instance"` // 实例标识
+	LastSeen int64  `json:"lastSeen"` // 最后一次推送时间（毫秒）
+	Samples  int    `json:"samples"`  // 已保存的样本数
+	Stale    bool   `json:"stale"`    // 是否已过期
+}
+
+// instanceData 单个实例保存的数据
+type instanceData struct {
+	history  []Sample
+	routes   []RouteStat
+	lastSeen int64
+}
+
+// Collector 接收多个 Agent 推送的数据，按实例分别保存
+type Collector struct {
+	maxHistory int
+	clock      Clock
+
+	mu        sync.RWMutex
+	instances map[string]*instanceData
+}
+
+// NewCollector 创建新的 Collector 实例，maxHistory 为每个实例最多保留的样本数
+// maxHistory <= 0 时使用默认值
+func NewCollector(maxHistory int) *Collector {
+	if maxHistory <= 0 {
+		maxHistory = defaultMaxHistory
+	}
+	return &Collector{
+		maxHistory: maxHistory,
+		clock:      SystemClock{},
+		instances:  make(map[string]*instanceData),
+	}
+}
+
+// Ingest 保存一批推送数据，每个实例最多保留 maxHistory 个样本
+// 超过 instanceEvictAfter 未推送的实例会被删除；实例数已达 maxInstances 时拒绝新实例
+func (c *Collector) Ingest(b Batch) error {
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evictIdle(now)
+	d, ok := c.instances[b.Instance]
+	if !ok {
+		if len(c.instances) >= maxInstances {
+			return errTooManyInstances
+		}
+		d = &instanceData{}
+		c.instances[b.Instance] = d
+	}
+
+	// 丢弃重复推送的旧样本，保持时间递增
+	for _, s := range b.Samples {
+		if n := len(d.history); n > 0 && s.Time <= d.history[n-1].Time {
+			continue
+		}
+		d.history = append(d.history, s)
+	}
+	if len(d.history) > c.maxHistory {
+		d.history = d.history[len(d.history)-c.maxHistory:]
+	}
+
+	d.routes = b.Routes
+	d.lastSeen = now.UnixMilli()
+	return nil
+}
+
+// evictIdle 删除超过 instanceEvictAfter 未推送的实例，调用方须持有写锁
+func (c *Collector) evictIdle(now time.Time) {
+	cutoff := now.Add(-instanceEvictAfter).UnixMilli()
+	for name, d := range c.instances {
+		if d.lastSeen < cutoff {
+			delete(c.instances, name)
+		}
+	}
+}
+
+// Instances 返回所有实例概况，按名称排序
+func (c *Collector) Instances() []InstanceInfo {
+	staleCutoff := c.clock.Now().Add(-instanceStaleAfter).UnixMilli()
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	out := make([]InstanceInfo, 0, len(c.instances))
+	for name, d := range c.instances {
+		out = append(out, InstanceInfo{
+			Instance: name,
+			LastSeen: d.lastSeen,
+			Samples:  len(d.history),
+			Stale:    d.lastSeen < staleCutoff,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Instance < out[j].Instance })
+	return out
+}
+
+// Instance 返回单个实例的数据视图，实例不存在时返回 false
+func (c *Collector) Instance(name string) (Source, bool) {
+	c.mu.RLock()
+	_, ok := c.instances[name]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, false
+	}
+	return instanceView{c: c, name: name}, true
+}
+
+// Aggregate 返回集群聚合视图
+func (c *Collector) Aggregate() Source {
+	return aggregateView{c: c}
+}
+
+// instanceView 单个实例的只读视图
+type instanceView struct {
+	c    *Collector
+	name string
+}
+
+// CurrentSample 返回该实例最近一次推送的样本
+func (v instanceView) CurrentSample() Sample {
+	v.c.mu.RLock()
+	defer v.c.mu.RUnlock()
+
+	d := v.c.instances[v.name]
+	if d == nil || len(d.history) == 0 {
+		return Sample{Time: v.c.clock.Now().UnixMilli()}
+	}
+	return d.history[len(d.history)-1]
+}
+
+// HistoryWindow 返回该实例指定时间窗口内的历史数据（秒）
+func (v instanceView) HistoryWindow(seconds int) []Sample {
+	cutoff := windowCutoff(seconds)
+
+	v.c.mu.RLock()
+	defer v.c.mu.RUnlock()
+
+	d := v.c.instances[v.name]
+	if d == nil {
+		return []Sample{}
+	}
+	return samplesAfter(d.history, cutoff)
+}
+
+// RouteStats 返回该实例最近一次推送的路由统计
+func (v instanceView) RouteStats() []RouteStat {
+	v.c.mu.RLock()
+	defer v.c.mu.RUnlock()
+
+	d := v.c.instances[v.name]
+	if d == nil {
+		return []RouteStat{}
+	}
+	out := make([]RouteStat, len(d.routes))
+	copy(out, d.routes)
+	return out
+}
+
+// aggregateView 集群聚合视图，数值字段按实例求和
+type aggregateView struct {
+	c *Collector
+}
+
+// CurrentSample 汇总所有未过期实例的最新样本
+func (v aggregateView) CurrentSample() Sample {
+	now := v.c.clock.Now()
+	staleCutoff := now.Add(-instanceStaleAfter).UnixMilli()
+
+	v.c.mu.RLock()
+	defer v.c.mu.RUnlock()
+
+	agg := Sample{Time: now.UnixMilli()}
+	for _, d := range v.c.instances {
+		if d.lastSeen < staleCutoff || len(d.history) == 0 {
+			continue
+		}
+		addSample(&agg, d.history[len(d.history)-1])
+	}
+	return agg
+}
+
+// HistoryWindow 按秒对齐各实例样本后求和
+func (v aggregateView) HistoryWindow(seconds int) []Sample {
+	cutoff := windowCutoff(seconds)
+
+	v.c.mu.RLock()
+	buckets := make(map[int64]*Sample)
+	for _, d := range v.c.instances {
+		for _, s := range samplesAfter(d.history, cutoff) {
+			sec := s.Time / 1000
+			b, ok := buckets[sec]
+			if !ok {
+				b = &Sample{Time: sec * 1000}
+				buckets[sec] = b
+			}
+			addSample(b, s)
+		}
+	}
+	v.c.mu.RUnlock()
+
+	out := make([]Sample, 0, len(buckets))
+	for _, b := range buckets {
+		out = append(out, *b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Time < out[j].Time })
+	return out
+}
+
+// RouteStats 按路由合并所有未过期实例的统计
+func (v aggregateView) RouteStats() []RouteStat {
+	staleCutoff := v.c.clock.Now().Add(-instanceStaleAfter).UnixMilli()
+
+	v.c.mu.RLock()
+	merged := make(map[string]*RouteStat)
+	for _, d := range v.c.instances {
+		if d.lastSeen < staleCutoff {
+			continue
+		}
+		for _, rs := range d.routes {
+			m, ok := merged[rs.Route]
+			if !ok {
+				m = &RouteStat{Route: rs.Route}
+				merged[rs.Route] = m
+			}
+			m.Requests += rs.Requests
+			m.QPS += rs.QPS
+			m.BytesInRate += rs.BytesInRate
+			m.BytesOutRate += rs.BytesOutRate
+			m.BytesInTotal += rs.BytesInTotal
+			m.BytesOutTotal += rs.BytesOutTotal
+			m.InFlight += rs.InFlight
+			m.PeakInFlight += rs.PeakInFlight
+			m.MemoryUsage += rs.MemoryUsage
+			m.CPUUsage += rs.CPUUsage
+			m.BlockLock += rs.BlockLock
+			m.BlockIO += rs.BlockIO
+			m.BlockPerm += rs.BlockPerm
+		}
+	}
+	v.c.mu.RUnlock()
+
+	out := make([]RouteStat, 0, len(merged))
+	for _, m := range merged {
+		out = append(out, *m)
+	}
+	return out
+}
+
+// addSample 把 s 的数值字段累加到 agg
+func addSample(agg *Sample, s Sample) {
+	agg.Goroutines += s.Goroutines
+	agg.Requests += s.Requests
+	agg.QPS += s.QPS
+	agg.BytesInRate += s.BytesInRate
+	agg.BytesOutRate += s.BytesOutRate
+	agg.BytesInTotal += s.BytesInTotal
+	agg.BytesOutTotal += s.BytesOutTotal
+	agg.InFlight += s.InFlight
+	agg.PeakInFlight += s.PeakInFlight
+	agg.HeapAlloc += s.HeapAlloc
+	agg.HeapInuse += s.HeapInuse
+	agg.HeapSys += s.HeapSys
+	agg.HeapObjects += s.HeapObjects
+	agg.NumGC += s.NumGC
+	agg.GCIncrement += s.GCIncrement
+	agg.BlockLock += s.BlockLock
+	agg.BlockIO += s.BlockIO
+	agg.BlockPerm += s.BlockPerm
+}
+
+// windowCutoff 计算时间窗口的起始时间戳（毫秒），seconds <= 0 时默认10分钟
+func windowCutoff(seconds int) int64 {
+	if seconds <= 0 {
+		seconds = 600
+	}
+	return time.Now().Add(-time.Duration(seconds) * time.Second).UnixMilli()
+}
+
+// samplesAfter 返回 h 中时间戳不早于 cutoff 的样本拷贝，h 须按时间递增
+func samplesAfter(h []Sample, cutoff int64) []Sample {
+	idx := sort.Search(len(h), func(i int) bool { return h[i].Time >= cutoff })
+	out := make([]Sample, len(h)-idx)
+	copy(out, h[idx:])
+	return out
+}
@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// manualClock 手动推进的 Clock，测试用
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newManualClock() *manualClock {
+	return &manualClock{now: time.UnixMilli(1_700_000_000_000)}
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func (c *manualClock) NewTicker(d time.Duration) Ticker {
+	return SystemClock{}.NewTicker(d)
+}
+
+// getJSON 请求 url 并把响应解码到 v
+func getJSON(t *testing.T, url string, v any) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %s", url, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCollectorAgentRoundTrip(t *testing.T) {
+	collector := NewCollector(0)
+	api := NewAPI(NewTracker(TrackerConfig{}), NewHub(), APIOptions{Collector: collector})
+	srv := httptest.NewServer(api.Handler())
+	defer srv.Close()
+
+	agents := map[string]int{"node-a": 3, "node-b": 5}
+	trackers := make(map[string]*Tracker)
+	for name, requests := range agents {
+		tr := NewTracker(TrackerConfig{})
+		app := httptest.NewServer(Middleware(tr, NewHub(), nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		})))
+		for range requests {
+			resp, err := http.Get(app.URL + "/posts")
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+		}
+		app.Close()
+		tr.PushSample()
+		trackers[name] = tr
+
+		agent := NewAgent(tr, name, srv.URL+"/collector/push", "", time.Second)
+		if err := agent.Push(context.Background()); err != nil {
+			t.Fatalf("push %s: %v", name, err)
+		}
+		// 第二次推送没有新样本，不应重复写入历史
+		if err := agent.Push(context.Background()); err != nil {
+			t.Fatalf("second push %s: %v", name, err)
+		}
+	}
+
+	var instances []InstanceInfo
+	getJSON(t, srv.URL+"/collector/instances", &instances)
+	if len(instances) != 2 || instances[0].Instance != "node-a" || instances[1].Instance != "node-b" {
+		t.Fatalf("instances = %+v", instances)
+	}
+	for _, info := range instances {
+		if info.Samples != 1 || info.Stale {
+			t.Errorf("instance %s: samples=%d stale=%v, want 1 sample and not stale", info.Instance, info.Samples, info.Stale)
+		}
+	}
+
+	var wantRequests int
+	for name, tr := range trackers {
+		var got Sample
+		getJSON(t, srv.URL+"/metrics?instance="+name, &got)
+		want := tr.History()[0]
+		if got.Time != want.Time || got.Requests != want.Requests {
+			t.Errorf("instance %s: got time=%d requests=%d, want time=%d requests=%d",
+				name, got.Time, got.Requests, want.Time, want.Requests)
+		}
+		wantRequests += want.Requests
+	}
+
+	var agg Sample
+	getJSON(t, srv.URL+"/metrics", &agg)
+	if agg.Requests != wantRequests {
+		t.Errorf("aggregate requests = %d, want %d", agg.Requests, wantRequests)
+	}
+
+	var routes []RouteStat
+	getJSON(t, srv.URL+"/metrics/routes", &routes)
+	if len(routes) != 1 || routes[0].Route != "/posts" || routes[0].Requests != 8 {
+		t.Errorf("aggregate routes = %+v, want one route with 8 requests", routes)
+	}
+
+	resp, err := http.Get(srv.URL + "/metrics?instance=missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown instance status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestCollectorEvictsIdleInstances(t *testing.T) {
+	clock := newManualClock()
+	c := NewCollector(0)
+	c.clock = clock
+
+	if err := c.Ingest(Batch{Instance: "old"}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(instanceStaleAfter + time.Second)
+	if err := c.Ingest(Batch{Instance: "new"}); err != nil {
+		t.Fatal(err)
+	}
+
+	infos := c.Instances()
+	if len(infos) != 2 || !infos[1].Stale || infos[0].Stale {
+		t.Fatalf("after stale period: %+v, want old stale and new fresh", infos)
+	}
+
+	clock.Advance(instanceEvictAfter)
+	if err := c.Ingest(Batch{Instance: "new"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Instance("old"); ok {
+		t.Error("idle instance was not evicted")
+	}
+	if _, ok := c.Instance("new"); !ok {
+		t.Error("active instance was evicted")
+	}
+}
+
+func TestCollectorInstanceCap(t *testing.T) {
+	clock := newManualClock()
+	c := NewCollector(0)
+	c.clock = clock
+
+	for i := range maxInstances {
+		if err := c.Ingest(Batch{Instance: fmt.Sprintf("node-%d", i)}); err != nil {
+			t.Fatalf("instance %d: %v", i, err)
+		}
+	}
+	if err := c.Ingest(Batch{Instance: "overflow"}); err != errTooManyInstances {
+		t.Fatalf("over cap: err = %v, want %v", err, errTooManyInstances)
+	}
+	// 已有实例仍可继续推送
+	if err := c.Ingest(Batch{Instance: "node-0"}); err != nil {
+		t.Fatalf("existing instance rejected: %v", err)
+	}
+
+	// 其他实例空闲被淘汰后可以接收新实例
+	clock.Advance(instanceEvictAfter + time.Second)
+	if err := c.Ingest(Batch{Instance: "overflow"}); err != nil {
+		t.Fatalf("after eviction: %v", err)
+	}
+	if n := len(c.Instances()); n != 1 {
+		t.Errorf("instances after eviction = %d, want 1", n)
+	}
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import "time"
+
+// ringCounter 按秒分桶的环形计数器，内存固定为 size 个桶，与请求量无关
+// 非并发安全，由调用方加锁
+type ringCounter struct {
+	secs   []int64  // 每个桶对应的 Unix 秒，用于判断桶是否过期
+	counts []uint64 // 每个桶的计数
+	last   int64    // 最近一次写入的 Unix 秒
+}
+
+// newRingCounter 创建覆盖 size 秒的计数器
+func newRingCounter(size int) *ringCounter {
+	if size <= 0 {
+		size = 1
+	}
+	return &ringCounter{
+		secs:   make([]int64, size),
+		counts: make([]uint64, size),
+	}
+}
+
+// add 在 sec 秒的桶上累加 n，桶属于更早的秒时先清零
+// 早于最近写入 len(secs) 秒以上的迟到写入会覆盖仍有效的新桶，直接丢弃
+func (c *ringCounter) add(sec int64, n uint64) {
+	if sec <= c.last-int64(len(c.secs)) {
+		return
+	}
+	i := int(sec % int64(len(c.secs)))
+	if c.secs[i] != sec {
+		c.secs[i] = sec
+		c.counts[i] = 0
+	}
+	c.counts[i] += n
+	if sec > c.last {
+		c.last = sec
+	}
+}
+
+// sum 返回截至 now 秒（含）最近 window 秒内的计数，window 超过容量时按容量计算
+func (c *ringCounter) sum(now int64, window int) uint64 {
+	if window > len(c.secs) {
+		window = len(c.secs)
+	}
+	var total uint64
+	for sec := now - int64(window) + 1; sec <= now; sec++ {
+		i := int(sec % int64(len(c.secs)))
+		if c.secs[i] == sec {
+			total += c.counts[i]
+		}
+	}
+	return total
+}
+
+// idle 判断截至 now 秒计数器是否已没有任何有效桶
+func (c *ringCounter) idle(now int64) bool {
+	return c.last <= now-int64(len(c.secs))
+}
+
+// WindowRate 单个时间窗口内的请求数和 QPS
+type WindowRate struct {
+	Window  string  `json:"window"`  // 窗口，例如 "1m0s"
+	Seconds int     `json:"seconds"` // 窗口长度（秒）
+	Count   uint64  `json:"count"`   // 窗口内请求数
+	QPS     float64 `json:"qps"`     // 窗口内平均每秒请求数
+}
+
+// RateStats 全局和按路由的多窗口请求速率
+type RateStats struct {
+	Time   int64                   `json:"time"`   // 统计时间戳（毫秒）
+	Total  []WindowRate            `json:"total"`  // 全部请求
+	Routes map[string][]WindowRate `json:"routes"` // 按路由
+}
+
+// windowRates 按 windows 计算 c 的多窗口速率
+func windowRates(c *ringCounter, now int64, windows []time.Duration) []WindowRate {
+	out := make([]WindowRate, 0, len(windows))
+	for _, w := range windows {
+		sec := windowSeconds(w)
+		n := c.sum(now, sec)
+		out = append(out, WindowRate{
+			Window:  w.String(),
+			Seconds: sec,
+			Count:   n,
+			QPS:     float64(n) / float64(sec),
+		})
+	}
+	return out
+}
+
+// windowSeconds 把时间窗口换算为整秒，至少1秒
+func windowSeconds(d time.Duration) int {
+	sec := int(d / time.Second)
+	if sec < 1 {
+		sec = 1
+	}
+	return sec
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import "testing"
+
+func TestRingCounterSum(t *testing.T) {
+	c := newRingCounter(10)
+	for sec := int64(100); sec < 115; sec++ {
+		c.add(sec, 1)
+	}
+
+	tests := []struct {
+		now    int64
+		window int
+		want   uint64
+	}{
+		{114, 1, 1},
+		{114, 5, 5},
+		{114, 10, 10},
+		{114, 60, 10}, // 超过容量按容量计算
+		{120, 10, 4},  // 105..114 中只有 111..114 仍在窗口内
+		{130, 10, 0},
+	}
+	for _, tt := range tests {
+		if got := c.sum(tt.now, tt.window); got != tt.want {
+			t.Errorf("sum(%d, %d) = %d, want %d", tt.now, tt.window, got, tt.want)
+		}
+	}
+}
+
+func TestRingCounterLateAdd(t *testing.T) {
+	c := newRingCounter(10)
+	c.add(100, 3)
+
+	// 仍在容量内的迟到写入正常累加
+	c.add(95, 2)
+	if got := c.sum(100, 10); got != 5 {
+		t.Errorf("sum after in-range late add = %d, want 5", got)
+	}
+
+	// 与 100 落在同一个桶、早了整整一圈的写入被丢弃，不能清掉 100 的计数
+	c.add(90, 7)
+	if got := c.sum(100, 1); got != 3 {
+		t.Errorf("sum(100, 1) after stale add = %d, want 3", got)
+	}
+	if got := c.sum(100, 10); got != 5 {
+		t.Errorf("sum(100, 10) after stale add = %d, want 5", got)
+	}
+}
+
+func TestRingCounterIdle(t *testing.T) {
+	c := newRingCounter(10)
+	if !c.idle(100) {
+		t.Error("new counter should be idle")
+	}
+	c.add(100, 1)
+	if c.idle(109) {
+		t.Error("counter with a bucket in range reported idle")
+	}
+	if !c.idle(110) {
+		t.Error("counter with only expired buckets not idle")
+	}
+}
@@ -0,0 +1,199 @@
+package metrics
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 导出格式
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// errFormat 不支持的导出格式
+var errFormat = errors.New("format must be csv or ndjson")
+
+// exportField 可导出的字段：JSON 名称及其在结构体中的下标
+type exportField struct {
+	name  string
+	index int
+}
+
+// exportFields 返回结构体类型按声明顺序的 JSON 字段
+func exportFields(t reflect.Type) []exportField {
+	var out []exportField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		out = append(out, exportField{name: name, index: i})
+	}
+	return out
+}
+
+// selectFields 按逗号分隔的名称选择字段，为空时返回全部；未知字段返回错误
+func selectFields(t reflect.Type, spec string) ([]exportField, error) {
+	all := exportFields(t)
+	if strings.TrimSpace(spec) == "" {
+		return all, nil
+	}
+	var out []exportField
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		i := slices.IndexFunc(all, func(f exportField) bool { return f.name == name })
+		if i < 0 {
+			names := make([]string, len(all))
+			for j, f := range all {
+				names[j] = f.name
+			}
+			return nil, fmt.Errorf("unknown field %q, available: %s", name, strings.Join(names, ","))
+		}
+		out = append(out, all[i])
+	}
+	return out, nil
+}
+
+// WriteCSV 把结构体切片按所选字段写为带表头的 CSV，fields 为空时导出全部字段
+func WriteCSV[T any](w io.Writer, rows []T, fields string) error {
+	sel, err := selectFields(reflect.TypeFor[T](), fields)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	record := make([]string, len(sel))
+	for i, f := range sel {
+		record[i] = f.name
+	}
+	cw.Write(record)
+	for _, row := range rows {
+		v := reflect.ValueOf(row)
+		for i, f := range sel {
+			record[i] = formatCell(v.Field(f.index))
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteNDJSON 把结构体切片按所选字段写为每行一个 JSON 对象，字段顺序与声明一致
+func WriteNDJSON[T any](w io.Writer, rows []T, fields string) error {
+	sel, err := selectFields(reflect.TypeFor[T](), fields)
+	if err != nil {
+		return err
+	}
+	var line []byte
+	for _, row := range rows {
+		v := reflect.ValueOf(row)
+		line = append(line[:0], '{')
+		for i, f := range sel {
+			if i > 0 {
+				line = append(line, ',')
+			}
+			line = strconv.AppendQuote(line, f.name)
+			line = append(line, ':')
+			b, err := json.Marshal(v.Field(f.index).Interface())
+			if err != nil {
+				return err
+			}
+			line = append(line, b...)
+		}
+		line = append(line, '}', '\n')
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatCell 把字段值格式化为 CSV 单元格
+func formatCell(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	default:
+		b, _ := json.Marshal(v.Interface())
+		return string(b)
+	}
+}
+
+// writeExport 按 format 参数写出 CSV 或 NDJSON 附件
+func writeExport[T any](w http.ResponseWriter, r *http.Request, name string, rows []T) {
+	q := r.URL.Query()
+	format := q.Get("format")
+	if format == "" {
+		format = FormatCSV
+	}
+	fields := q.Get("fields")
+	// 先校验字段，出错时还能返回 JSON 错误
+	if _, err := selectFields(reflect.TypeFor[T](), fields); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var write func(io.Writer, []T, string) error
+	switch format {
+	case FormatCSV:
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		write = WriteCSV[T]
+	case FormatNDJSON:
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		write = WriteNDJSON[T]
+	default:
+		writeError(w, r, http.StatusBadRequest, errFormat)
+		return
+	}
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().Format("20060102-150405"), format)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	if err := write(w, rows, fields); err != nil {
+		// 响应头已写出，只能记录
+		slog.WarnContext(r.Context(), "Export failed", "error", err)
+	}
+}
+
+// ServeExportHistory 导出历史指标，参数：format（csv/ndjson）、fields（逗号分隔）、window/minutes/hours
+func (a *API) ServeExportHistory(w http.ResponseWriter, r *http.Request) {
+	src, err := a.source(r)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	}
+	windowSec := ParseWindowSeconds(r.URL.Query(), a.opts.MaxWindowSec, a.opts.DefaultWindowSec)
+	writeExport(w, r, "history", src.HistoryWindow(windowSec))
+}
+
+// ServeExportRoutes 导出按路由统计，参数：format（csv/ndjson）、fields（逗号分隔）
+func (a *API) ServeExportRoutes(w http.ResponseWriter, r *http.Request) {
+	src, err := a.source(r)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	}
+	routes := src.RouteStats()
+	slices.SortFunc(routes, func(a, b RouteStat) int { return strings.Compare(a.Route, b.Route) })
+	writeExport(w, r, "routes", routes)
+}
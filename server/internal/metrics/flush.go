@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// HistoryFlusher 在服务关闭时把历史样本写入持久化存储
+type HistoryFlusher interface {
+	FlushHistory(ctx context.Context, samples []Sample) error
+}
+
+// HistoryFlusherFunc 函数形式的 HistoryFlusher
+type HistoryFlusherFunc func(ctx context.Context, samples []Sample) error
+
+// FlushHistory 调用 f
+func (f HistoryFlusherFunc) FlushHistory(ctx context.Context, samples []Sample) error {
+	return f(ctx, samples)
+}
+
+// AddFlusher 注册历史持久化钩子，在 Flush 时按注册顺序调用
+func (t *Tracker) AddFlusher(f HistoryFlusher) {
+	t.hookMu.Lock()
+	t.flushers = append(t.flushers, f)
+	t.hookMu.Unlock()
+}
+
+// Flush 把当前全部历史交给已注册的钩子，返回所有钩子的错误
+func (t *Tracker) Flush(ctx context.Context) error {
+	t.hookMu.RLock()
+	flushers := t.flushers
+	t.hookMu.RUnlock()
+	if len(flushers) == 0 {
+		return nil
+	}
+
+	samples := t.History()
+	var errs []error
+	for _, f := range flushers {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		if err := f.FlushHistory(ctx, samples); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FileFlusher 把历史样本以 JSON 数组写入文件
+// 先写临时文件再重命名，避免进程被强制结束时留下半个文件
+type FileFlusher struct {
+	Path string
+}
+
+// FlushHistory 写入文件
+func (f FileFlusher) FlushHistory(ctx context.Context, samples []Sample) error {
+	data, err := json.Marshal(samples)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create history file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.Path); err != nil {
+		return fmt.Errorf("failed to rename history file: %w", err)
+	}
+	return nil
+}
@@ -6,15 +6,29 @@ import "sync"
 type Hub struct {
 	mu   sync.RWMutex
 	subs []chan struct{} // 订阅者通道列表
+
+	closeOnce sync.Once
+	done      chan struct{} // 关闭后通知所有长连接断开
 }
 
 // NewHub 创建新的 Hub 实例
 func NewHub() *Hub {
 	return &Hub{
 		subs: make([]chan struct{}, 0),
+		done: make(chan struct{}),
 	}
 }
 
+// Close 通知所有 SSE/WebSocket 长连接服务即将关闭，可重复调用
+func (h *Hub) Close() {
+	h.closeOnce.Do(func() { close(h.done) })
+}
+
+// Done 返回在 Close 后关闭的通道
+func (h *Hub) Done() <-chan struct{} {
+	return h.done
+}
+
 // Subscribe 订阅通知，返回一个通知通道
 // 注意：使用完毕后必须调用 Unsubscribe 以避免内存泄漏
 func (h *Hub) Subscribe() chan struct{} {
@@ -0,0 +1,57 @@
+package metrics
+
+// concurrency 进行中请求数和自上次采样以来的峰值，由 Tracker.mu 保护
+type concurrency struct {
+	current int
+	peak    int
+}
+
+// begin 记录一个请求开始处理
+func (c *concurrency) begin() {
+	c.current++
+	if c.current > c.peak {
+		c.peak = c.current
+	}
+}
+
+// end 记录一个请求处理完成
+func (c *concurrency) end() {
+	if c.current > 0 {
+		c.current--
+	}
+}
+
+// beginRequest 记录请求进入处理器
+func (t *Tracker) beginRequest(route string) {
+	t.mu.Lock()
+	t.inFlight.begin()
+	c, ok := t.inFlightByRoute[route]
+	if !ok {
+		c = &concurrency{}
+		t.inFlightByRoute[route] = c
+	}
+	c.begin()
+	t.mu.Unlock()
+}
+
+// endRequest 记录请求处理完成
+func (t *Tracker) endRequest(route string) {
+	t.mu.Lock()
+	t.inFlight.end()
+	if c, ok := t.inFlightByRoute[route]; ok {
+		c.end()
+	}
+	t.mu.Unlock()
+}
+
+// resetPeaks 开始新的采样周期：峰值重置为当前值，并清理已空闲的路由，调用方须持有 t.mu
+func (t *Tracker) resetPeaks() {
+	t.inFlight.peak = t.inFlight.current
+	for route, c := range t.inFlightByRoute {
+		if c.current == 0 {
+			delete(t.inFlightByRoute, route)
+			continue
+		}
+		c.peak = c.current
+	}
+}
@@ -0,0 +1,266 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"runtime"
+	pprof "runtime/pprof"
+	"sync/atomic"
+	"time"
+
+	"analyseGo/internal/reqid"
+)
+
+// RouteFunc 从请求中提取用于统计的路由名
+// 在请求进入处理器之前调用，返回值会作为 pprof 的 route 标签
+type RouteFunc func(r *http.Request) string
+
+// PathRoute 直接使用请求路径作为路由名
+func PathRoute(r *http.Request) string {
+	return r.URL.Path
+}
+
+// ServeMuxRoute 使用 ServeMux 匹配到的模式作为路由名（如 "GET /posts/{id}"）
+// 未匹配到任何模式时归入 UnmatchedRoute
+func ServeMuxRoute(mux *http.ServeMux) RouteFunc {
+	return func(r *http.Request) string {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			return pattern
+		}
+		return UnmatchedRoute
+	}
+}
+
+// ResponseInfo 由框架适配层在处理器返回后提供的响应信息
+type ResponseInfo struct {
+	Status int   // 响应状态码
+	Bytes  int64 // 响应体字节数
+	// ClientIP 框架按受信代理解析出的客户端 IP（如 gin 的 c.ClientIP()），为空时使用连接地址
+	ClientIP string
+}
+
+// RequestInfo 一次请求完成后的汇总信息
+type RequestInfo struct {
+	Method     string        // 请求方法
+	Path       string        // 请求路径
+	Route      string        // 路由名
+	Status     int           // 响应状态码
+	Bytes      int64         // 响应体字节数
+	BytesIn    int64         // 请求体字节数（Content-Length，缺失时为实际读取的字节数）
+	ClientIP   string        // 客户端 IP
+	RequestID  string        // 请求 ID
+	TraceID    string        // 追踪 ID，启用 OTLP 导出时由钩子写入 ctx
+	Start      time.Time     // 开始时间
+	Duration   time.Duration // 处理耗时
+	AllocBytes uint64        // 处理期间的堆内存增量（字节）
+}
+
+// RequestHook 请求生命周期钩子，通过 Tracker.AddHook 注册
+type RequestHook interface {
+	// StartRequest 在请求进入处理器之前调用，返回的 ctx 会传给处理器和 EndRequest
+	StartRequest(ctx context.Context, r *http.Request, route string) context.Context
+	// EndRequest 在请求处理完成后调用
+	EndRequest(ctx context.Context, info RequestInfo)
+}
+
+// readHeapAlloc 读取当前堆内存分配量；runtime.ReadMemStats 会短暂停止所有 goroutine，每个请求只读两次
+var readHeapAlloc = func() uint64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
+
+// memReadings 同一请求中嵌套的 Track（如全局追踪器与路由分组追踪器两层中间件）共用的堆内存读数
+// 最外层在请求前读取 before，最内层在处理器返回后读取 after，外层直接使用，因此每个请求只读两次。
+// 同一层中先后调用的多个 Track 各自重新读取 after，但 before 沿用外层的读数，内存增量会包含之前兄弟调用的分配
+type memReadings struct {
+	before uint64
+	after  atomic.Uint64
+	fresh  atomic.Bool // after 是在最近一次进入的 Track 之后读取的
+}
+
+type memReadingsKey struct{}
+
+// Track 记录一次请求，并在带 route 标签的 pprof 上下文中执行 next
+// next 返回响应信息；框架无关，gin 与 net/http 中间件共用。
+// 嵌套调用时（ctx 来自外层 Track 传给 next 的 ctx）共用堆内存读数，见 memReadings
+func Track(r *http.Request, tracker *Tracker, hub *Hub, route string, next func(ctx context.Context) ResponseInfo) {
+	// 记录请求开始时间（用于计算CPU时间）
+	startTime := tracker.Now()
+
+	// 归一化路由名并受基数限制，之后的统计、标签和钩子都使用归一化后的名称
+	route = tracker.Route(route)
+
+	// 分块请求没有 Content-Length，统计处理器实际读取的字节数
+	var body *countingBody
+	if r.ContentLength < 0 && r.Body != nil && r.Body != http.NoBody {
+		body = &countingBody{ReadCloser: r.Body}
+		r.Body = body
+	}
+
+	// 记录请求前的内存，外层 Track 已读取时沿用
+	ctx := r.Context()
+	mem, _ := ctx.Value(memReadingsKey{}).(*memReadings)
+	if mem == nil {
+		mem = &memReadings{before: readHeapAlloc()}
+		ctx = context.WithValue(ctx, memReadingsKey{}, mem)
+	}
+	mem.fresh.Store(false)
+
+	// 记录请求
+	tracker.AddRequest(startTime.UnixMilli())
+	tracker.AddRequestRoute(route, startTime.UnixMilli())
+	if hub != nil {
+		hub.Notify()
+	}
+
+	// 进行中请求数，处理器 panic 时也要减回
+	tracker.beginRequest(route)
+	defer tracker.endRequest(route)
+
+	// 调用钩子
+	hooks := tracker.requestHooks()
+	for _, h := range hooks {
+		ctx = h.StartRequest(ctx, r, route)
+	}
+
+	// 添加 pprof 标签用于性能分析，有请求 ID 时一并标注
+	requestID := reqid.FromContext(ctx)
+	if requestID == "" {
+		requestID = r.Header.Get(reqid.Header)
+	}
+	labels := pprof.Labels("route", route)
+	if requestID != "" {
+		labels = pprof.Labels("route", route, "request_id", requestID)
+	}
+	pprof.Do(ctx, labels, func(pctx context.Context) {
+		resp := next(pctx)
+
+		// 请求完成后记录内存增量和CPU时间，内层 Track 已读取时沿用
+		if !mem.fresh.Load() {
+			mem.after.Store(readHeapAlloc())
+			mem.fresh.Store(true)
+		}
+
+		// 计算内存增量（使用 HeapAlloc 的变化）
+		var memDelta uint64
+		if after := mem.after.Load(); after > mem.before {
+			memDelta = after - mem.before
+			tracker.AddRouteMemory(route, memDelta)
+		}
+
+		// 计算CPU时间（纳秒）
+		elapsed := tracker.Now().Sub(startTime)
+		tracker.AddRouteCPUTime(route, elapsed.Nanoseconds())
+
+		bytesIn := r.ContentLength
+		if body != nil {
+			bytesIn = body.n.Load()
+		}
+
+		clientIP := resp.ClientIP
+		if clientIP == "" {
+			clientIP = ClientIP(r)
+		}
+		info := RequestInfo{
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Route:      route,
+			Status:     resp.Status,
+			Bytes:      resp.Bytes,
+			BytesIn:    bytesIn,
+			ClientIP:   clientIP,
+			RequestID:  requestID,
+			TraceID:    reqid.TraceIDFromContext(ctx),
+			Start:      startTime,
+			Duration:   elapsed,
+			AllocBytes: memDelta,
+		}
+		tracker.RecordRequest(info)
+		for _, h := range hooks {
+			h.EndRequest(ctx, info)
+		}
+	})
+}
+
+// ClientIP 返回连接的对端 IP
+// 不读取 X-Forwarded-For / X-Real-IP：任何客户端都能伪造这些头，是否信任应由适配层按受信代理判断
+func ClientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// statusRecorder 记录 net/http 处理器写出的状态码和字节数
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader 记录状态码
+func (w *statusRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write 未显式写状态码时视为 200
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush 支持 SSE 等流式响应
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 支持 WebSocket 等协议升级
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Middleware 返回 net/http 中间件，向 tracker 和 hub 上报请求
+// routeOf 为 nil 时使用 PathRoute
+func Middleware(tracker *Tracker, hub *Hub, routeOf RouteFunc) func(http.Handler) http.Handler {
+	if routeOf == nil {
+		routeOf = PathRoute
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routeOf(r)
+			Track(r, tracker, hub, route, func(ctx context.Context) ResponseInfo {
+				rec := &statusRecorder{ResponseWriter: w}
+				next.ServeHTTP(rec, r.WithContext(ctx))
+				if rec.status == 0 {
+					rec.status = http.StatusOK
+				}
+				return ResponseInfo{Status: rec.status, Bytes: rec.bytes}
+			})
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrackClientIP(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolved string // 适配层解析出的 IP
+		want     string
+	}{
+		{"ignores forwarded headers", "", "192.0.2.1"},
+		{"uses adapter resolution", "203.0.113.7", "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewTracker(TrackerConfig{})
+			log := NewRequestLog(4, 4, 0)
+			tracker.AddHook(log)
+
+			r := httptest.NewRequest(http.MethodGet, "/posts", nil)
+			r.RemoteAddr = "192.0.2.1:4321"
+			r.Header.Set("X-Forwarded-For", "6.6.6.6")
+			r.Header.Set("X-Real-IP", "6.6.6.6")
+			Track(r, tracker, nil, "/posts", func(ctx context.Context) ResponseInfo {
+				return ResponseInfo{Status: http.StatusOK, ClientIP: tt.resolved}
+			})
+
+			entries := log.Query(RequestFilter{})
+			if len(entries) != 1 || entries[0].ClientIP != tt.want {
+				t.Fatalf("entries = %+v, want client IP %s", entries, tt.want)
+			}
+		})
+	}
+}
+
+// stubHeapAlloc 替换 readHeapAlloc，依次返回 values，返回读取次数的计数器
+func stubHeapAlloc(t *testing.T, values ...uint64) *int {
+	t.Helper()
+	orig := readHeapAlloc
+	t.Cleanup(func() { readHeapAlloc = orig })
+	calls := new(int)
+	readHeapAlloc = func() uint64 {
+		v := values[min(*calls, len(values)-1)]
+		*calls++
+		return v
+	}
+	return calls
+}
+
+// allocHook 记录每个请求的内存增量
+type allocHook struct{ allocs []uint64 }
+
+func (h *allocHook) StartRequest(ctx context.Context, _ *http.Request, _ string) context.Context {
+	return ctx
+}
+
+func (h *allocHook) EndRequest(_ context.Context, info RequestInfo) {
+	h.allocs = append(h.allocs, info.AllocBytes)
+}
+
+// trackedAlloc 返回追踪器唯一一个请求的内存增量
+func trackedAlloc(t *testing.T, h *allocHook) uint64 {
+	t.Helper()
+	if len(h.allocs) != 1 {
+		t.Fatalf("requests = %v, want 1", h.allocs)
+	}
+	return h.allocs[0]
+}
+
+func TestTrackNestedSharesMemReadings(t *testing.T) {
+	calls := stubHeapAlloc(t, 1000, 5000)
+	outer, inner := NewTracker(TrackerConfig{}), NewTracker(TrackerConfig{})
+	outerHook, innerHook := &allocHook{}, &allocHook{}
+	outer.AddHook(outerHook)
+	inner.AddHook(innerHook)
+
+	r := httptest.NewRequest(http.MethodGet, "/posts", nil)
+	Track(r, outer, nil, "/posts", func(ctx context.Context) ResponseInfo {
+		Track(r.WithContext(ctx), inner, nil, "/posts", func(context.Context) ResponseInfo {
+			return ResponseInfo{Status: http.StatusOK}
+		})
+		return ResponseInfo{Status: http.StatusOK}
+	})
+
+	if *calls != 2 {
+		t.Errorf("heap read %d times, want 2", *calls)
+	}
+	if got := trackedAlloc(t, outerHook); got != 4000 {
+		t.Errorf("outer alloc = %d, want 4000", got)
+	}
+	if got := trackedAlloc(t, innerHook); got != 4000 {
+		t.Errorf("inner alloc = %d, want 4000", got)
+	}
+
+	// 没有外层 Track 的请求各自读取
+	*calls = 0
+	Track(httptest.NewRequest(http.MethodGet, "/posts", nil), NewTracker(TrackerConfig{}), nil, "/posts", func(context.Context) ResponseInfo {
+		return ResponseInfo{Status: http.StatusOK}
+	})
+	if *calls != 2 {
+		t.Errorf("separate request: heap read %d times, want 2", *calls)
+	}
+}
+
+func TestTrackSiblingsRereadAfter(t *testing.T) {
+	calls := stubHeapAlloc(t, 1000, 3000, 7000)
+	outer, first, second := NewTracker(TrackerConfig{}), NewTracker(TrackerConfig{}), NewTracker(TrackerConfig{})
+	outerHook, firstHook, secondHook := &allocHook{}, &allocHook{}, &allocHook{}
+	outer.AddHook(outerHook)
+	first.AddHook(firstHook)
+	second.AddHook(secondHook)
+
+	r := httptest.NewRequest(http.MethodGet, "/posts", nil)
+	Track(r, outer, nil, "/posts", func(ctx context.Context) ResponseInfo {
+		for _, tr := range []*Tracker{first, second} {
+			Track(r.WithContext(ctx), tr, nil, "/posts", func(context.Context) ResponseInfo {
+				return ResponseInfo{Status: http.StatusOK}
+			})
+		}
+		return ResponseInfo{Status: http.StatusOK}
+	})
+
+	// 外层使用最后一次读数；第二个兄弟沿用外层的 before，包含第一个兄弟的分配
+	if *calls != 3 {
+		t.Errorf("heap read %d times, want 3", *calls)
+	}
+	for _, tt := range []struct {
+		name string
+		hook *allocHook
+		want uint64
+	}{
+		{"outer", outerHook, 6000},
+		{"first", firstHook, 2000},
+		{"second", secondHook, 6000},
+	} {
+		if got := trackedAlloc(t, tt.hook); got != tt.want {
+			t.Errorf("%s alloc = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	pprof "runtime/pprof"
+	"time"
+)
+
+const (
+	// maxCPUProfileSeconds CPU 采样的最长持续时间
+	maxCPUProfileSeconds = 30
+)
+
+// CaptureProfile 抓取一次 pprof 性能数据，返回 pprof 二进制格式内容
+// kind 支持 cpu 以及 runtime/pprof 内置的 heap/goroutine/allocs/block/mutex/threadcreate
+// 仅 cpu 使用 seconds 参数，取值范围 1~30 秒
+func CaptureProfile(kind string, seconds int) ([]byte, error) {
+	var buf bytes.Buffer
+
+	if kind == "cpu" {
+		if seconds <= 0 {
+			seconds = 1
+		}
+		if seconds > maxCPUProfileSeconds {
+			seconds = maxCPUProfileSeconds
+		}
+		// 同一时间只能有一个 CPU 采样在运行
+		if err := pprof.StartCPUProfile(&buf); err != nil {
+			return nil, fmt.Errorf("failed to start cpu profile: %w", err)
+		}
+		time.Sleep(time.Duration(seconds) * time.Second)
+		pprof.StopCPUProfile()
+		return buf.Bytes(), nil
+	}
+
+	p := pprof.Lookup(kind)
+	if p == nil {
+		return nil, fmt.Errorf("unknown profile: %s", kind)
+	}
+	if err := p.WriteTo(&buf, 0); err != nil {
+		return nil, fmt.Errorf("failed to write %s profile: %w", kind, err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,168 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Registry 管理同一进程内多个命名追踪器，例如每个路由分组一个
+// 新建的追踪器共用创建 Registry 时的配置（名称除外）；钩子按追踪器分别注册
+type Registry struct {
+	base TrackerConfig
+
+	mu       sync.RWMutex
+	trackers map[string]*Tracker
+	runCtx   context.Context // 非空表示已 StartAll，之后新建的追踪器自动启动
+}
+
+// NewRegistry 创建追踪器注册表，base 作为新建追踪器的默认配置
+func NewRegistry(base TrackerConfig) *Registry {
+	return &Registry{
+		base:     base,
+		trackers: make(map[string]*Tracker),
+	}
+}
+
+// Register 注册已创建的追踪器，同名追踪器已存在时返回 false
+func (r *Registry) Register(t *Tracker) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.trackers[t.Name()]; ok {
+		return false
+	}
+	r.attach(t)
+	return true
+}
+
+// registerReadOnly 注册导入的只读追踪器，同名追踪器已存在或只读追踪器已达 limit 个时返回错误
+func (r *Registry) registerReadOnly(t *Tracker, limit int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.trackers[t.Name()]; ok {
+		return errTrackerExists
+	}
+	n := 0
+	for _, other := range r.trackers {
+		if other.ReadOnly() {
+			n++
+		}
+	}
+	if n >= limit {
+		return errTooManyImports
+	}
+	r.attach(t)
+	return nil
+}
+
+// removeReadOnly 删除导入的只读追踪器，本进程的追踪器不可删除
+func (r *Registry) removeReadOnly(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t, ok := r.trackers[name]
+	if !ok {
+		return errTrackerNotFound
+	}
+	if !t.ReadOnly() {
+		return errNotImported
+	}
+	delete(r.trackers, name)
+	return nil
+}
+
+// Tracker 返回指定名称的追踪器，不存在时按默认配置创建
+func (r *Registry) Tracker(name string) *Tracker {
+	if name == "" {
+		name = DefaultTrackerName
+	}
+
+	r.mu.RLock()
+	t, ok := r.trackers[name]
+	r.mu.RUnlock()
+	if ok {
+		return t
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.trackers[name]; ok {
+		return t
+	}
+	cfg := r.base
+	cfg.Name = name
+	t = NewTracker(cfg)
+	r.attach(t)
+	return t
+}
+
+// attach 保存追踪器并按需启动，调用方须持有 r.mu
+func (r *Registry) attach(t *Tracker) {
+	r.trackers[t.Name()] = t
+	if r.runCtx != nil {
+		t.Start(r.runCtx)
+	}
+}
+
+// Get 返回指定名称的追踪器，不存在时返回 false
+func (r *Registry) Get(name string) (*Tracker, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.trackers[name]
+	return t, ok
+}
+
+// Names 返回所有追踪器名称，按名称排序
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	out := make([]string, 0, len(r.trackers))
+	for name := range r.trackers {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// StartAll 启动所有追踪器的采样器，之后新建的追踪器也会自动启动
+func (r *Registry) StartAll(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.runCtx = ctx
+	for _, t := range r.trackers {
+		t.Start(ctx)
+	}
+}
+
+// StopAll 停止所有追踪器的采样器并等待退出
+func (r *Registry) StopAll() {
+	r.mu.Lock()
+	r.runCtx = nil
+	trackers := make([]*Tracker, 0, len(r.trackers))
+	for _, t := range r.trackers {
+		trackers = append(trackers, t)
+	}
+	r.mu.Unlock()
+
+	for _, t := range trackers {
+		t.Stop()
+	}
+}
+
+// Flush 依次调用所有追踪器的 Flush，返回全部错误
+func (r *Registry) Flush(ctx context.Context) error {
+	var errs []error
+	for _, name := range r.Names() {
+		t, _ := r.Get(name)
+		if err := t.Flush(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("tracker %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
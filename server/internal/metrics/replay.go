@@ -0,0 +1,278 @@
+package metrics
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// maxReplaySpeed 最大回放倍速
+	maxReplaySpeed = 1000
+	// minReplaySpeed 最小回放倍速
+	minReplaySpeed = 0.01
+	// maxReplayGap 两个样本之间的最长真实等待，历史中的空档（如进程重启）会被压缩
+	maxReplayGap = 5 * time.Second
+)
+
+// errReplayNotFound 回放会话不存在或已结束
+var errReplayNotFound = errors.New("Replay session not found")
+
+// ReplayState 回放会话状态，建立连接和每次控制后以 replay 事件推送
+type ReplayState struct {
+	Session  string  `json:"session"`  // 会话 ID，控制接口使用
+	From     int64   `json:"from"`     // 可回放的第一个样本时间戳（毫秒）
+	To       int64   `json:"to"`       // 可回放的最后一个样本时间戳（毫秒），随历史增长
+	Position int64   `json:"position"` // 已回放到的历史时间戳（毫秒）
+	Speed    float64 `json:"speed"`    // 倍速
+	Paused   bool    `json:"paused"`
+	Ended    bool    `json:"ended"` // 已回放到最新样本，有新样本时继续
+}
+
+// replaySession 一个 SSE 回放连接的可控状态
+type replaySession struct {
+	owner   string // 创建者标识，见 APIOptions.Principal
+	mu      sync.Mutex
+	state   ReplayState
+	changed chan struct{} // 控制操作通知，容量 1
+	seeked  bool          // 控制操作改变了位置，回放循环需重新定位
+}
+
+// snapshot 返回当前状态
+func (s *replaySession) snapshot() ReplayState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// set 修改状态并返回修改后的快照，由回放循环调用
+func (s *replaySession) set(fn func(st *ReplayState)) ReplayState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(&s.state)
+	return s.state
+}
+
+// update 修改状态并通知回放循环，由控制接口调用
+func (s *replaySession) update(fn func(st *ReplayState)) ReplayState {
+	st := s.set(fn)
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+	return st
+}
+
+// replayRegistry 进行中的回放会话
+type replayRegistry struct {
+	mu       sync.Mutex
+	sessions map[string]*replaySession
+}
+
+// add 登记 owner 的会话并分配 ID
+func (reg *replayRegistry) add(owner string, st ReplayState) *replaySession {
+	var b [8]byte
+	rand.Read(b[:])
+	st.Session = hex.EncodeToString(b[:])
+	s := &replaySession{owner: owner, state: st, changed: make(chan struct{}, 1)}
+
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.sessions == nil {
+		reg.sessions = make(map[string]*replaySession)
+	}
+	reg.sessions[st.Session] = s
+	return s
+}
+
+// get 查找会话
+func (reg *replayRegistry) get(id string) (*replaySession, bool) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	s, ok := reg.sessions[id]
+	return s, ok
+}
+
+// remove 注销会话
+func (reg *replayRegistry) remove(id string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	delete(reg.sessions, id)
+}
+
+// errReplaySpeed 倍速超出范围
+var errReplaySpeed = fmt.Errorf("speed must be between %g and %d", minReplaySpeed, maxReplaySpeed)
+
+// parseReplaySpeed 解析倍速，为空时为 1
+func parseReplaySpeed(v string) (float64, error) {
+	if v == "" {
+		return 1, nil
+	}
+	speed, err := strconv.ParseFloat(v, 64)
+	if err != nil || speed < minReplaySpeed || speed > maxReplaySpeed {
+		return 0, errReplaySpeed
+	}
+	return speed, nil
+}
+
+// sendReplayState 推送 replay 事件
+func sendReplayState(w http.ResponseWriter, flusher http.Flusher, st ReplayState) {
+	data, err := json.Marshal(st)
+	if err != nil {
+		slog.Error("Failed to marshal replay state", "error", err)
+		return
+	}
+	fmt.Fprintf(w, "event: replay\ndata: %s\n\n", data)
+	flusher.Flush()
+}
+
+// searchSamples 返回第一个时间戳不早于 ts 的样本下标
+func searchSamples(samples []Sample, ts int64) int {
+	return sort.Search(len(samples), func(i int) bool { return samples[i].Time >= ts })
+}
+
+// serveReplay 按历史时间间隔（除以倍速）重新推送历史样本，样本事件与实时推送相同
+// 两个样本之间的注释在推送后一个样本之前以 annotation 事件推送
+// 响应头已写出；from 之前的样本被跳过，paused=1 时以暂停状态开始
+func (a *API) serveReplay(w http.ResponseWriter, r *http.Request, flusher http.Flusher, src Source, from int64, speed float64) {
+	samples := src.HistoryWindow(a.opts.MaxWindowSec)
+	st := ReplayState{Speed: speed, Paused: r.URL.Query().Get("paused") == "1"}
+	if n := len(samples); n > 0 {
+		st.From, st.To = samples[0].Time, samples[n-1].Time
+		st.Position = min(max(from, st.From), st.To)
+	}
+	sess := a.replays.add(a.principal(r), st)
+	defer a.replays.remove(st.Session)
+
+	idx := searchSamples(samples, st.Position)
+	// annFrom 下一次推送注释的起始时间
+	annFrom := st.Position
+	sendReplayState(w, flusher, sess.snapshot())
+
+	timer := time.NewTimer(0)
+	timer.Stop()
+	defer timer.Stop()
+	// 回放到最新样本后按推送间隔检查新样本
+	poll := time.NewTicker(a.opts.Interval)
+	defer poll.Stop()
+
+	for {
+		st := sess.snapshot()
+		var due, more <-chan time.Time
+		switch {
+		case idx >= len(samples):
+			more = poll.C
+		case !st.Paused:
+			gap := time.Duration(float64(samples[idx].Time-st.Position) * float64(time.Millisecond) / st.Speed)
+			timer.Reset(min(max(gap, 0), maxReplayGap))
+			due = timer.C
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-a.hub.Done():
+			fmt.Fprintf(w, "retry: %d\nevent: shutdown\ndata: {}\n\n", reconnectDelayMs)
+			flusher.Flush()
+			return
+		case <-sess.changed:
+			timer.Stop()
+			sess.mu.Lock()
+			seeked := sess.seeked
+			sess.seeked = false
+			sess.mu.Unlock()
+			if seeked {
+				annFrom = sess.snapshot().Position
+				idx = searchSamples(samples, annFrom)
+			}
+			sendReplayState(w, flusher, sess.set(func(st *ReplayState) { st.Ended = idx >= len(samples) }))
+		case <-due:
+			pos := samples[idx].Time
+			for _, ann := range a.opts.Annotations.Range(annFrom, pos, nil) {
+				sendAnnotation(w, ann)
+			}
+			annFrom = pos + 1
+			sendSample(w, flusher, samples[idx])
+			idx++
+			if idx >= len(samples) {
+				sendReplayState(w, flusher, sess.set(func(st *ReplayState) {
+					st.Position = pos
+					st.Ended = true
+				}))
+				continue
+			}
+			sess.set(func(st *ReplayState) { st.Position = pos })
+		case <-more:
+			// 样本已回放完，有新样本时继续
+			latest := src.HistoryWindow(a.opts.MaxWindowSec)
+			if n := len(latest); n == 0 || latest[n-1].Time <= st.To {
+				continue
+			}
+			samples = latest
+			idx = sort.Search(len(samples), func(i int) bool { return samples[i].Time > st.Position })
+			sendReplayState(w, flusher, sess.set(func(st *ReplayState) {
+				st.From, st.To = samples[0].Time, samples[len(samples)-1].Time
+				st.Ended = false
+			}))
+		}
+	}
+}
+
+// replayControl 回放控制请求体
+type replayControl struct {
+	Action string  `json:"action"` // pause / resume / seek / speed
+	To     int64   `json:"to"`     // seek 的目标时间戳（毫秒）
+	Speed  float64 `json:"speed"`  // speed 的新倍速
+}
+
+// principal 返回请求的调用方标识，未配置 APIOptions.Principal 时为空
+func (a *API) principal(r *http.Request) string {
+	if a.opts.Principal == nil {
+		return ""
+	}
+	return a.opts.Principal(r)
+}
+
+// ServeReplayControl 控制回放会话（?session=），请求体 {"action": "pause|resume|seek|speed", "to": 毫秒, "speed": 倍速}
+// 只接受会话创建者的请求，其他调用方与会话不存在一样返回 404
+func (a *API) ServeReplayControl(w http.ResponseWriter, r *http.Request) {
+	sess, ok := a.replays.get(r.URL.Query().Get("session"))
+	if !ok || sess.owner != a.principal(r) {
+		writeError(w, r, http.StatusNotFound, errReplayNotFound)
+		return
+	}
+	var ctl replayControl
+	if err := json.NewDecoder(r.Body).Decode(&ctl); err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	var st ReplayState
+	switch ctl.Action {
+	case "pause", "resume":
+		st = sess.update(func(st *ReplayState) { st.Paused = ctl.Action == "pause" })
+	case "seek":
+		st = sess.update(func(st *ReplayState) {
+			st.Position = min(max(ctl.To, st.From), st.To)
+			sess.seeked = true
+		})
+	case "speed":
+		if ctl.Speed < minReplaySpeed || ctl.Speed > maxReplaySpeed {
+			writeError(w, r, http.StatusBadRequest, errReplaySpeed)
+			return
+		}
+		st = sess.update(func(st *ReplayState) { st.Speed = ctl.Speed })
+	default:
+		writeError(w, r, http.StatusBadRequest, fmt.Errorf("unknown action %q, want pause, resume, seek or speed", ctl.Action))
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
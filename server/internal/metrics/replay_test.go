@@ -0,0 +1,330 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sseEvent 一个 SSE 事件，样本事件的 name 为空
+type sseEvent struct {
+	name string
+	data string
+}
+
+// sseStream 读取 SSE 响应
+type sseStream struct {
+	t      *testing.T
+	resp   *http.Response
+	events chan sseEvent
+}
+
+// openStream 请求 SSE 接口，header 为附加请求头
+func openStream(t *testing.T, url string, header http.Header) *sseStream {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = header
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		t.Fatalf("GET %s: %s", url, resp.Status)
+	}
+	s := &sseStream{t: t, resp: resp, events: make(chan sseEvent, 64)}
+	t.Cleanup(func() { resp.Body.Close() })
+	go func() {
+		defer close(s.events)
+		sc := bufio.NewScanner(resp.Body)
+		var ev sseEvent
+		for sc.Scan() {
+			line := sc.Text()
+			switch {
+			case line == "":
+				s.events <- ev
+				ev = sseEvent{}
+			case strings.HasPrefix(line, "event: "):
+				ev.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return s
+}
+
+// next 返回下一个事件
+func (s *sseStream) next() sseEvent {
+	s.t.Helper()
+	select {
+	case ev, ok := <-s.events:
+		if !ok {
+			s.t.Fatal("stream closed")
+		}
+		return ev
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("timed out waiting for event")
+	}
+	return sseEvent{}
+}
+
+// state 读取下一个事件，要求为 replay 事件
+func (s *sseStream) state() ReplayState {
+	s.t.Helper()
+	ev := s.next()
+	if ev.name != "replay" {
+		s.t.Fatalf("event = %q %s, want replay", ev.name, ev.data)
+	}
+	var st ReplayState
+	if err := json.Unmarshal([]byte(ev.data), &st); err != nil {
+		s.t.Fatal(err)
+	}
+	return st
+}
+
+// sample 读取下一个事件，要求为样本，返回样本时间
+func (s *sseStream) sample() int64 {
+	s.t.Helper()
+	ev := s.next()
+	if ev.name != "" {
+		s.t.Fatalf("event = %q %s, want sample", ev.name, ev.data)
+	}
+	var sample Sample
+	if err := json.Unmarshal([]byte(ev.data), &sample); err != nil {
+		s.t.Fatal(err)
+	}
+	return sample.Time
+}
+
+// annotation 读取下一个事件，要求为注释，返回标题
+func (s *sseStream) annotation() string {
+	s.t.Helper()
+	ev := s.next()
+	if ev.name != "annotation" {
+		s.t.Fatalf("event = %q %s, want annotation", ev.name, ev.data)
+	}
+	var ann Annotation
+	if err := json.Unmarshal([]byte(ev.data), &ann); err != nil {
+		s.t.Fatal(err)
+	}
+	return ann.Title
+}
+
+// controlReplay 发送回放控制请求，返回状态码和解码后的状态
+func controlReplay(t *testing.T, base, session string, header http.Header, body string) (int, ReplayState) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, base+"/metrics/stream/replay?session="+session, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header = header
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var st ReplayState
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&st); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode, st
+}
+
+// replayHistory 每秒一个样本的历史，返回追踪器和样本时间
+func replayHistory(n int) (*Tracker, []int64) {
+	start := time.UnixMilli(1_700_000_000_000).UnixMilli()
+	history := make([]Sample, n)
+	times := make([]int64, n)
+	for i := range history {
+		times[i] = start + int64(i)*1000
+		history[i] = Sample{Time: times[i]}
+	}
+	return NewReadOnlyTracker("replay", Snapshot{History: history}), times
+}
+
+func TestReplayControl(t *testing.T) {
+	src, times := replayHistory(10)
+	annotations := NewAnnotationLog(0)
+	annotations.Add(Annotation{Time: times[2] - 500, Title: "skipped by seek"})
+	annotations.Add(Annotation{Time: times[7] - 500, Title: "deploy"})
+	annotations.Add(Annotation{Time: times[7], Title: "at sample"})
+	api := NewAPI(src, NewHub(), APIOptions{Annotations: annotations})
+	srv := httptest.NewServer(api.Handler())
+	t.Cleanup(srv.Close) // 在 openStream 关闭响应之后执行
+
+	s := openStream(t, fmt.Sprintf("%s/metrics/stream?replay=1&paused=1&speed=1000&from=%d", srv.URL, times[1]), nil)
+	st := s.state()
+	if !st.Paused || st.From != times[0] || st.To != times[9] || st.Position != times[1] || st.Speed != 1000 || st.Session == "" {
+		t.Fatalf("initial state = %+v", st)
+	}
+	control := func(body string) (int, ReplayState) { return controlReplay(t, srv.URL, st.Session, nil, body) }
+
+	if code, _ := control(`{"action":"speed","speed":2000}`); code != http.StatusBadRequest {
+		t.Errorf("speed out of range: status = %d, want 400", code)
+	}
+	if code, _ := control(`{"action":"rewind"}`); code != http.StatusBadRequest {
+		t.Errorf("unknown action: status = %d, want 400", code)
+	}
+	if code, got := control(`{"action":"speed","speed":500}`); code != http.StatusOK || got.Speed != 500 {
+		t.Errorf("speed: status = %d, state = %+v", code, got)
+	}
+	if got := s.state(); got.Speed != 500 || !got.Paused {
+		t.Errorf("after speed = %+v", got)
+	}
+
+	// 跳转超出范围时夹到最后一个样本，再跳回中间
+	if _, got := control(fmt.Sprintf(`{"action":"seek","to":%d}`, times[9]+60_000)); got.Position != times[9] {
+		t.Errorf("seek past end: position = %d, want %d", got.Position, times[9])
+	}
+	if got := s.state(); got.Position != times[9] {
+		t.Errorf("after seek past end = %+v", got)
+	}
+	if _, got := control(fmt.Sprintf(`{"action":"seek","to":%d}`, times[5]-1)); got.Position != times[5]-1 {
+		t.Errorf("seek: position = %d", got.Position)
+	}
+	if got := s.state(); got.Position != times[5]-1 || got.Ended {
+		t.Errorf("after seek = %+v", got)
+	}
+
+	// 继续后从跳转位置往后回放，注释在其后第一个样本之前推送，跳转位置之前的注释不推送
+	if _, got := control(`{"action":"resume"}`); got.Paused {
+		t.Errorf("resume: state = %+v", got)
+	}
+	if got := s.state(); got.Paused {
+		t.Errorf("after resume = %+v", got)
+	}
+	for _, want := range times[5:7] {
+		if got := s.sample(); got != want {
+			t.Fatalf("sample = %d, want %d", got, want)
+		}
+	}
+	if got := s.annotation(); got != "deploy" {
+		t.Errorf("annotation = %q, want deploy", got)
+	}
+	if got := s.annotation(); got != "at sample" {
+		t.Errorf("annotation = %q, want at sample", got)
+	}
+	for _, want := range times[7:] {
+		if got := s.sample(); got != want {
+			t.Fatalf("sample = %d, want %d", got, want)
+		}
+	}
+	if got := s.state(); !got.Ended || got.Position != times[9] {
+		t.Errorf("end state = %+v", got)
+	}
+
+	// 暂停只改变状态
+	if _, got := control(`{"action":"pause"}`); !got.Paused {
+		t.Errorf("pause: state = %+v", got)
+	}
+	if got := s.state(); !got.Paused || !got.Ended {
+		t.Errorf("after pause = %+v", got)
+	}
+}
+
+func TestReplayPauseResume(t *testing.T) {
+	src, times := replayHistory(3)
+	api := NewAPI(src, NewHub(), APIOptions{})
+	srv := httptest.NewServer(api.Handler())
+	t.Cleanup(srv.Close) // 在 openStream 关闭响应之后执行
+
+	// 5 倍速时样本间隔 200ms，第一个样本立即推送
+	s := openStream(t, srv.URL+"/metrics/stream?replay=1&speed=5", nil)
+	st := s.state()
+	if got := s.sample(); got != times[0] {
+		t.Fatalf("first sample = %d, want %d", got, times[0])
+	}
+	controlReplay(t, srv.URL, st.Session, nil, `{"action":"pause"}`)
+	if got := s.state(); !got.Paused || got.Position != times[0] {
+		t.Fatalf("after pause = %+v, want paused at %d", got, times[0])
+	}
+	select {
+	case ev := <-s.events:
+		t.Errorf("event while paused: %q %s", ev.name, ev.data)
+	case <-time.After(400 * time.Millisecond):
+	}
+
+	controlReplay(t, srv.URL, st.Session, nil, `{"action":"resume"}`)
+	if got := s.state(); got.Paused {
+		t.Fatalf("after resume = %+v", got)
+	}
+	if got := s.sample(); got != times[1] {
+		t.Errorf("sample after resume = %d, want %d", got, times[1])
+	}
+}
+
+func TestReplayNewSamples(t *testing.T) {
+	clock := newManualClock()
+	src := NewTracker(TrackerConfig{Clock: clock})
+	var times []int64
+	push := func() {
+		clock.Advance(time.Second)
+		src.PushSample()
+		times = append(times, clock.Now().UnixMilli())
+	}
+	for range 3 {
+		push()
+	}
+	api := NewAPI(src, NewHub(), APIOptions{Interval: 10 * time.Millisecond})
+	srv := httptest.NewServer(api.Handler())
+	t.Cleanup(srv.Close) // 在 openStream 关闭响应之后执行
+
+	s := openStream(t, srv.URL+"/metrics/stream?replay=1&speed=1000", nil)
+	if st := s.state(); st.From != times[0] || st.To != times[2] {
+		t.Fatalf("initial state = %+v, want %d..%d", st, times[0], times[2])
+	}
+	for _, want := range times {
+		if got := s.sample(); got != want {
+			t.Fatalf("sample = %d, want %d", got, want)
+		}
+	}
+	if st := s.state(); !st.Ended {
+		t.Fatalf("state = %+v, want ended", st)
+	}
+
+	// 回放完毕后产生的新样本继续推送
+	push()
+	if st := s.state(); st.Ended || st.To != times[3] {
+		t.Errorf("after new sample = %+v, want to %d and not ended", st, times[3])
+	}
+	if got := s.sample(); got != times[3] {
+		t.Errorf("new sample = %d, want %d", got, times[3])
+	}
+	if st := s.state(); !st.Ended || st.Position != times[3] {
+		t.Errorf("state = %+v, want ended at %d", st, times[3])
+	}
+}
+
+func TestReplayControlOwner(t *testing.T) {
+	src, _ := replayHistory(3)
+	api := NewAPI(src, NewHub(), APIOptions{
+		Principal: func(r *http.Request) string { return r.Header.Get("X-User") },
+	})
+	srv := httptest.NewServer(api.Handler())
+	t.Cleanup(srv.Close) // 在 openStream 关闭响应之后执行
+
+	alice := http.Header{"X-User": {"alice"}}
+	s := openStream(t, srv.URL+"/metrics/stream?replay=1&paused=1", alice)
+	st := s.state()
+
+	if code, _ := controlReplay(t, srv.URL, st.Session, http.Header{"X-User": {"bob"}}, `{"action":"resume"}`); code != http.StatusNotFound {
+		t.Errorf("other principal: status = %d, want 404", code)
+	}
+	if code, _ := controlReplay(t, srv.URL, "nope", alice, `{"action":"resume"}`); code != http.StatusNotFound {
+		t.Errorf("unknown session: status = %d, want 404", code)
+	}
+	if code, got := controlReplay(t, srv.URL, st.Session, alice, `{"action":"resume"}`); code != http.StatusOK || got.Paused {
+		t.Errorf("owner: status = %d, state = %+v", code, got)
+	}
+}
@@ -0,0 +1,330 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	pprof "runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"analyseGo/internal/reqid"
+)
+
+const (
+	// defaultRequestLogSize 请求日志默认容量
+	defaultRequestLogSize = 2000
+	// defaultSlowLogSize 慢请求默认容量
+	defaultSlowLogSize = 100
+	// defaultSlowThreshold 默认慢请求阈值
+	defaultSlowThreshold = time.Second
+	// maxRequestQueryLimit 单次查询最多返回的条数
+	maxRequestQueryLimit = 1000
+)
+
+// RequestEntry 请求日志中的一条记录
+type RequestEntry struct {
+	Time      int64   `json:"time"`              // 请求开始时间（毫秒）
+	Method    string  `json:"method"`            // 请求方法
+	Path      string  `json:"path"`              // 请求路径
+	Route     string  `json:"route"`             // 路由名
+	Status    int     `json:"status"`            // 响应状态码
+	LatencyMs float64 `json:"latencyMs"`         // 处理耗时（毫秒）
+	Bytes     int64   `json:"bytes"`             // 响应体字节数
+	ClientIP  string  `json:"clientIp"`          // 客户端 IP
+	RequestID string  `json:"requestId"`         // 请求 ID
+	TraceID   string  `json:"traceId,omitempty"` // 追踪 ID，未启用 OTLP 导出时为空
+}
+
+// SlowRequest 慢请求记录，附带超过阈值时刻的 goroutine 栈快照
+type SlowRequest struct {
+	RequestEntry
+	ThresholdMs float64 `json:"thresholdMs"` // 命中的阈值（毫秒）
+	Stack       string  `json:"stack"`       // 该请求 goroutine 的栈快照
+}
+
+// RequestFilter 请求日志查询条件，零值字段不参与过滤
+type RequestFilter struct {
+	Route      string        // 路由名精确匹配
+	Method     string        // 请求方法
+	Path       string        // 请求路径包含的子串
+	Status     int           // 状态码精确匹配
+	MinStatus  int           // 状态码下限（含）
+	MinLatency time.Duration // 耗时下限（含）
+	RequestID  string        // 请求 ID
+	TraceID    string        // 追踪 ID
+	Since      int64         // 开始时间下限（毫秒）
+	Limit      int           // 最多返回条数，按时间倒序
+}
+
+// match 判断记录是否满足过滤条件
+func (f RequestFilter) match(e RequestEntry) bool {
+	if f.Route != "" && e.Route != f.Route {
+		return false
+	}
+	if f.Method != "" && !strings.EqualFold(e.Method, f.Method) {
+		return false
+	}
+	if f.Path != "" && !strings.Contains(e.Path, f.Path) {
+		return false
+	}
+	if f.Status != 0 && e.Status != f.Status {
+		return false
+	}
+	if f.MinStatus != 0 && e.Status < f.MinStatus {
+		return false
+	}
+	if f.MinLatency > 0 && e.LatencyMs < float64(f.MinLatency)/float64(time.Millisecond) {
+		return false
+	}
+	if f.RequestID != "" && e.RequestID != f.RequestID {
+		return false
+	}
+	if f.TraceID != "" && e.TraceID != f.TraceID {
+		return false
+	}
+	if f.Since != 0 && e.Time < f.Since {
+		return false
+	}
+	return true
+}
+
+// pendingRequest 进行中请求的慢请求检测状态
+type pendingRequest struct {
+	mu        sync.Mutex
+	timer     *time.Timer
+	threshold time.Duration
+	stack     string
+}
+
+type pendingRequestKey struct{}
+
+// RequestLog 有界的请求日志与慢请求存储，实现 RequestHook
+type RequestLog struct {
+	mu      sync.RWMutex
+	entries []RequestEntry // 环形缓冲
+	next    int            // 下一个写入位置
+	full    bool
+
+	slowMu     sync.RWMutex
+	slow       []SlowRequest // 环形缓冲
+	slowNext   int
+	slowFull   bool
+	thresholds map[string]time.Duration // 按路由的慢请求阈值
+	defaultTh  time.Duration
+}
+
+// NewRequestLog 创建请求日志，size/slowSize <= 0 时使用默认容量
+// slowThreshold 为默认慢请求阈值，<= 0 时使用默认值1秒
+func NewRequestLog(size, slowSize int, slowThreshold time.Duration) *RequestLog {
+	if size <= 0 {
+		size = defaultRequestLogSize
+	}
+	if slowSize <= 0 {
+		slowSize = defaultSlowLogSize
+	}
+	if slowThreshold <= 0 {
+		slowThreshold = defaultSlowThreshold
+	}
+	return &RequestLog{
+		entries:    make([]RequestEntry, size),
+		slow:       make([]SlowRequest, slowSize),
+		thresholds: make(map[string]time.Duration),
+		defaultTh:  slowThreshold,
+	}
+}
+
+// SetSlowThreshold 设置路由的慢请求阈值，route 为空时设置默认阈值
+// d <= 0 表示该路由不做慢请求检测
+func (l *RequestLog) SetSlowThreshold(route string, d time.Duration) {
+	l.slowMu.Lock()
+	defer l.slowMu.Unlock()
+	if route == "" {
+		l.defaultTh = d
+		return
+	}
+	l.thresholds[route] = d
+}
+
+// SlowThresholds 返回所有阈值设置（毫秒），默认阈值的键为空字符串
+func (l *RequestLog) SlowThresholds() map[string]float64 {
+	l.slowMu.RLock()
+	defer l.slowMu.RUnlock()
+
+	out := make(map[string]float64, len(l.thresholds)+1)
+	out[""] = float64(l.defaultTh) / float64(time.Millisecond)
+	for r, d := range l.thresholds {
+		out[r] = float64(d) / float64(time.Millisecond)
+	}
+	return out
+}
+
+// slowThreshold 返回路由生效的慢请求阈值
+func (l *RequestLog) slowThreshold(route string) time.Duration {
+	l.slowMu.RLock()
+	defer l.slowMu.RUnlock()
+	if d, ok := l.thresholds[route]; ok {
+		return d
+	}
+	return l.defaultTh
+}
+
+// StartRequest 启动慢请求计时器，超过阈值时抓取该请求（无请求 ID 时为同路由）goroutine 的栈
+func (l *RequestLog) StartRequest(ctx context.Context, r *http.Request, route string) context.Context {
+	th := l.slowThreshold(route)
+	if th <= 0 {
+		return ctx
+	}
+
+	// 优先按请求 ID 标签定位，RequestID 中间件未启用时退回到路由标签
+	key, value := "route", route
+	if id := reqid.FromContext(ctx); id != "" {
+		key, value = "request_id", id
+	}
+
+	p := &pendingRequest{threshold: th}
+	p.timer = time.AfterFunc(th, func() {
+		stack := labeledStacks(key, value)
+		p.mu.Lock()
+		p.stack = stack
+		p.mu.Unlock()
+	})
+	return context.WithValue(ctx, pendingRequestKey{}, p)
+}
+
+// EndRequest 写入请求日志，超过阈值的请求同时写入慢请求存储
+func (l *RequestLog) EndRequest(ctx context.Context, info RequestInfo) {
+	e := RequestEntry{
+		Time:      info.Start.UnixMilli(),
+		Method:    info.Method,
+		Path:      info.Path,
+		Route:     info.Route,
+		Status:    info.Status,
+		LatencyMs: float64(info.Duration) / float64(time.Millisecond),
+		Bytes:     info.Bytes,
+		ClientIP:  info.ClientIP,
+		RequestID: info.RequestID,
+		TraceID:   info.TraceID,
+	}
+
+	l.mu.Lock()
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+	l.mu.Unlock()
+
+	p, ok := ctx.Value(pendingRequestKey{}).(*pendingRequest)
+	if !ok {
+		return
+	}
+	p.timer.Stop()
+	if info.Duration < p.threshold {
+		return
+	}
+
+	p.mu.Lock()
+	stack := p.stack
+	p.mu.Unlock()
+
+	l.slowMu.Lock()
+	l.slow[l.slowNext] = SlowRequest{
+		RequestEntry: e,
+		ThresholdMs:  float64(p.threshold) / float64(time.Millisecond),
+		Stack:        stack,
+	}
+	l.slowNext = (l.slowNext + 1) % len(l.slow)
+	if l.slowNext == 0 {
+		l.slowFull = true
+	}
+	l.slowMu.Unlock()
+}
+
+// Query 按条件查询请求日志，按时间倒序返回
+func (l *RequestLog) Query(f RequestFilter) []RequestEntry {
+	limit := queryLimit(f.Limit)
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	out := make([]RequestEntry, 0)
+	n := l.next
+	if l.full {
+		n = len(l.entries)
+	}
+	for i := 0; i < n && len(out) < limit; i++ {
+		e := l.entries[(l.next-1-i+len(l.entries))%len(l.entries)]
+		if f.match(e) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Between 返回开始时间在 [from, to]（毫秒）内的请求，按写入顺序
+// 日志已回绕且最早的记录晚于 from 时 complete 为 false，即区间开头的请求已被覆盖
+func (l *RequestLog) Between(from, to int64) (entries []RequestEntry, complete bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	n, oldest := l.next, 0
+	if l.full {
+		n, oldest = len(l.entries), l.next
+	}
+	for i := range n {
+		e := l.entries[(oldest+i)%len(l.entries)]
+		if e.Time >= from && e.Time <= to {
+			entries = append(entries, e)
+		}
+	}
+	complete = !l.full || l.entries[oldest].Time <= from
+	return entries, complete
+}
+
+// QuerySlow 按条件查询慢请求，按时间倒序返回
+func (l *RequestLog) QuerySlow(f RequestFilter) []SlowRequest {
+	limit := queryLimit(f.Limit)
+
+	l.slowMu.RLock()
+	defer l.slowMu.RUnlock()
+
+	out := make([]SlowRequest, 0)
+	n := l.slowNext
+	if l.slowFull {
+		n = len(l.slow)
+	}
+	for i := 0; i < n && len(out) < limit; i++ {
+		s := l.slow[(l.slowNext-1-i+len(l.slow))%len(l.slow)]
+		if f.match(s.RequestEntry) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// queryLimit 规范化查询条数
+func queryLimit(limit int) int {
+	if limit <= 0 || limit > maxRequestQueryLimit {
+		return maxRequestQueryLimit
+	}
+	return limit
+}
+
+// labeledStacks 返回带有指定 pprof 标签的 goroutine 栈（pprof debug=1 格式）
+func labeledStacks(key, value string) string {
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 1)
+
+	label := strconv.Quote(key) + ":" + strconv.Quote(value)
+	var out strings.Builder
+	for _, record := range strings.Split(buf.String(), "\n\n") {
+		if strings.Contains(record, "# labels: ") && strings.Contains(record, label) {
+			out.WriteString(strings.TrimSpace(record))
+			out.WriteString("\n\n")
+		}
+	}
+	return out.String()
+}
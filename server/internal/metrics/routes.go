@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"container/list"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// UnmatchedRoute 未匹配任何路由（404/405）的请求统一归入该路由，避免探测路径产生大量路由
+	UnmatchedRoute = "(unmatched)"
+	// OverflowRoute 路由数达到上限且没有可淘汰的空闲路由时，新路由归入该路由
+	OverflowRoute = "(other)"
+
+	// defaultMaxRoutes 默认最多跟踪的路由数
+	defaultMaxRoutes = 500
+	// defaultRouteIdleTTL 默认空闲路由淘汰时间
+	defaultRouteIdleTTL = 30 * time.Minute
+)
+
+// uuidRe、hexIDRe 匹配可折叠的 ID 段
+var (
+	uuidRe  = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hexIDRe = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+)
+
+// RouteRewrite 路由名正则改写规则，Replace 支持 $1 形式的分组引用
+type RouteRewrite struct {
+	Pattern *regexp.Regexp
+	Replace string
+}
+
+// RouteOptions 路由名归一化与基数限制，零值字段使用默认值
+type RouteOptions struct {
+	MaxRoutes   int            // 最多跟踪的路由数，默认500；不含 UnmatchedRoute 和 OverflowRoute
+	IdleTTL     time.Duration  // 超过该时长无请求的路由被淘汰，默认30分钟
+	Rewrites    []RouteRewrite // 按顺序应用全部规则
+	CollapseIDs bool           // 把纯数字、UUID、长十六进制路径段折叠为 :id
+}
+
+// RouteCardinality 路由基数统计
+type RouteCardinality struct {
+	Tracked    int    `json:"tracked"`    // 当前跟踪的路由数
+	MaxRoutes  int    `json:"maxRoutes"`  // 上限
+	Evicted    uint64 `json:"evicted"`    // 累计淘汰的空闲路由数
+	Overflowed uint64 `json:"overflowed"` // 累计归入 OverflowRoute 的请求数
+}
+
+// routeEntry LRU 中的路由
+type routeEntry struct {
+	route    string
+	lastSeen time.Time
+}
+
+// routeTable 按最近访问排序的路由表，由 Tracker.mu 保护
+type routeTable struct {
+	opts  RouteOptions
+	lru   *list.List // 队首为最近访问
+	index map[string]*list.Element
+
+	evicted    uint64
+	overflowed uint64
+}
+
+// newRouteTable 创建路由表
+func newRouteTable(opts RouteOptions) *routeTable {
+	if opts.MaxRoutes <= 0 {
+		opts.MaxRoutes = defaultMaxRoutes
+	}
+	if opts.IdleTTL <= 0 {
+		opts.IdleTTL = defaultRouteIdleTTL
+	}
+	return &routeTable{
+		opts:  opts,
+		lru:   list.New(),
+		index: make(map[string]*list.Element),
+	}
+}
+
+// normalize 应用改写规则和 ID 折叠，不访问路由表，可在锁外调用
+func (rt *routeTable) normalize(route string) string {
+	if route == "" || route == UnmatchedRoute || route == OverflowRoute {
+		return route
+	}
+	for _, rw := range rt.opts.Rewrites {
+		route = rw.Pattern.ReplaceAllString(route, rw.Replace)
+	}
+	if rt.opts.CollapseIDs {
+		route = collapseIDs(route)
+	}
+	return route
+}
+
+// admit 登记一次访问，返回实际使用的路由名和需要清理数据的被淘汰路由
+// 路由表已满时淘汰最久未访问且已空闲的路由；若最久未访问的路由仍活跃则归入 OverflowRoute
+func (rt *routeTable) admit(route string, now time.Time) (string, string) {
+	if route == UnmatchedRoute || route == OverflowRoute {
+		return route, ""
+	}
+	if el, ok := rt.index[route]; ok {
+		el.Value.(*routeEntry).lastSeen = now
+		rt.lru.MoveToFront(el)
+		return route, ""
+	}
+
+	var evicted string
+	if rt.lru.Len() >= rt.opts.MaxRoutes {
+		back := rt.lru.Back()
+		oldest := back.Value.(*routeEntry)
+		if now.Sub(oldest.lastSeen) < rt.opts.IdleTTL {
+			rt.overflowed++
+			return OverflowRoute, ""
+		}
+		rt.remove(back)
+		evicted = oldest.route
+	}
+	rt.index[route] = rt.lru.PushFront(&routeEntry{route: route, lastSeen: now})
+	return route, evicted
+}
+
+// expire 淘汰所有空闲超过 IdleTTL 的路由，返回被淘汰的路由名
+func (rt *routeTable) expire(now time.Time) []string {
+	var out []string
+	for el := rt.lru.Back(); el != nil; {
+		e := el.Value.(*routeEntry)
+		if now.Sub(e.lastSeen) < rt.opts.IdleTTL {
+			break
+		}
+		prev := el.Prev()
+		rt.remove(el)
+		out = append(out, e.route)
+		el = prev
+	}
+	return out
+}
+
+// remove 从路由表删除元素
+func (rt *routeTable) remove(el *list.Element) {
+	delete(rt.index, el.Value.(*routeEntry).route)
+	rt.lru.Remove(el)
+	rt.evicted++
+}
+
+// cardinality 返回基数统计
+func (rt *routeTable) cardinality() RouteCardinality {
+	return RouteCardinality{
+		Tracked:    rt.lru.Len(),
+		MaxRoutes:  rt.opts.MaxRoutes,
+		Evicted:    rt.evicted,
+		Overflowed: rt.overflowed,
+	}
+}
+
+// collapseIDs 把纯数字、UUID、长十六进制路径段替换为 :id
+func collapseIDs(route string) string {
+	segs := strings.Split(route, "/")
+	for i, seg := range segs {
+		if isIDSegment(seg) {
+			segs[i] = ":id"
+		}
+	}
+	return strings.Join(segs, "/")
+}
+
+// isIDSegment 判断路径段是否像 ID
+func isIDSegment(seg string) bool {
+	if seg == "" {
+		return false
+	}
+	digits := true
+	for _, r := range seg {
+		if r < '0' || r > '9' {
+			digits = false
+			break
+		}
+	}
+	return digits || uuidRe.MatchString(seg) || hexIDRe.MatchString(seg)
+}
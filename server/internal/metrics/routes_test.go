@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRouteNormalize(t *testing.T) {
+	rt := newRouteTable(RouteOptions{
+		CollapseIDs: true,
+		Rewrites: []RouteRewrite{
+			{Pattern: regexp.MustCompile(`^/v\d+/`), Replace: "/"},
+			{Pattern: regexp.MustCompile(`^/users/([^/]+)/avatar\.\w+$`), Replace: "/users/$1/avatar"},
+		},
+	})
+	tests := []struct{ in, want string }{
+		{"/api/posts/123", "/api/posts/:id"},
+		{"/api/posts/123/comments/9", "/api/posts/:id/comments/:id"},
+		{"/api/posts/550e8400-e29b-41d4-a716-446655440000", "/api/posts/:id"},
+		{"/api/blobs/0123456789abcdef0123", "/api/blobs/:id"},
+		{"/api/blobs/abc123", "/api/blobs/abc123"},         // 短十六进制不折叠
+		{"/api/posts/12a", "/api/posts/12a"},               // 非纯数字
+		{"/v2/api/posts/7", "/api/posts/:id"},              // 改写后再折叠
+		{"/users/alice/avatar.png", "/users/alice/avatar"}, // 分组引用
+		{"/users/42/avatar.jpg", "/users/:id/avatar"},
+		{"/api/posts/", "/api/posts/"},
+		{UnmatchedRoute, UnmatchedRoute},
+		{OverflowRoute, OverflowRoute},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := rt.normalize(tt.in); got != tt.want {
+			t.Errorf("normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	plain := newRouteTable(RouteOptions{})
+	if got := plain.normalize("/api/posts/123"); got != "/api/posts/123" {
+		t.Errorf("without CollapseIDs: %q", got)
+	}
+}
+
+func TestRouteAdmitCardinality(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	rt := newRouteTable(RouteOptions{MaxRoutes: 3, IdleTTL: time.Minute})
+
+	for i, r := range []string{"/a", "/b", "/c"} {
+		if got, evicted := rt.admit(r, now.Add(time.Duration(i)*time.Second)); got != r || evicted != "" {
+			t.Fatalf("admit(%s) = %q, %q", r, got, evicted)
+		}
+	}
+
+	// 已满且最久未访问的 /a 仍活跃：新路由归入 OverflowRoute，已有路由照常登记
+	if got, evicted := rt.admit("/d", now.Add(10*time.Second)); got != OverflowRoute || evicted != "" {
+		t.Errorf("admit(/d) while full = %q, %q; want overflow", got, evicted)
+	}
+	if got, _ := rt.admit("/a", now.Add(20*time.Second)); got != "/a" {
+		t.Errorf("admit(/a) = %q", got)
+	}
+
+	// 特殊路由不占用名额
+	for _, r := range []string{UnmatchedRoute, OverflowRoute} {
+		if got, evicted := rt.admit(r, now); got != r || evicted != "" {
+			t.Errorf("admit(%s) = %q, %q", r, got, evicted)
+		}
+	}
+
+	// /a 刚被访问，最久未访问的是 /b；空闲超过 IdleTTL 后被新路由挤出（LRU）
+	later := now.Add(time.Minute + 5*time.Second)
+	if got, evicted := rt.admit("/e", later); got != "/e" || evicted != "/b" {
+		t.Errorf("admit(/e) = %q, evicted %q; want /e evicting /b", got, evicted)
+	}
+	want := RouteCardinality{Tracked: 3, MaxRoutes: 3, Evicted: 1, Overflowed: 1}
+	if got := rt.cardinality(); got != want {
+		t.Errorf("cardinality = %+v, want %+v", got, want)
+	}
+}
+
+func TestRouteExpire(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	rt := newRouteTable(RouteOptions{IdleTTL: time.Minute})
+	rt.admit("/older", now.Add(-time.Second))
+	rt.admit("/old", now)
+	rt.admit("/fresh", now.Add(10*time.Second))
+	rt.admit("/old", now.Add(20*time.Second)) // 重新访问，移到队首
+
+	got := rt.expire(now.Add(time.Minute + 500*time.Millisecond))
+	if !slices.Equal(got, []string{"/older"}) {
+		t.Errorf("expire = %v, want [/older]", got)
+	}
+	got = rt.expire(now.Add(75 * time.Second))
+	if !slices.Equal(got, []string{"/fresh"}) {
+		t.Errorf("expire = %v, want [/fresh]", got)
+	}
+	if c := rt.cardinality(); c.Tracked != 1 || c.Evicted != 2 {
+		t.Errorf("cardinality = %+v", c)
+	}
+}
+
+func TestTrackerRouteEviction(t *testing.T) {
+	clock := newManualClock()
+	tracker := NewTracker(TrackerConfig{Clock: clock, Routes: RouteOptions{MaxRoutes: 2, IdleTTL: time.Minute}})
+
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	mux.HandleFunc("GET /a", ok)
+	mux.HandleFunc("GET /b", ok)
+	mux.HandleFunc("GET /c", ok)
+	h := Middleware(tracker, nil, ServeMuxRoute(mux))(mux)
+	get := func(path string) {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	get("/a")
+	get("/b")
+	for i := range 20 {
+		get("/probe/" + string(rune('a'+i)))
+	}
+	get("/c") // 已满且都活跃
+
+	routes := func() []string {
+		var out []string
+		for _, r := range tracker.RouteStats() {
+			out = append(out, r.Route)
+		}
+		slices.Sort(out)
+		return out
+	}
+	if got, want := routes(), []string{OverflowRoute, UnmatchedRoute, "GET /a", "GET /b"}; !slices.Equal(got, want) {
+		t.Errorf("routes = %v, want %v", got, want)
+	}
+
+	// /a 和 /b 空闲超过 IdleTTL 后，/c 淘汰 /a 而不是归入 OverflowRoute，/a 的统计一并清除；
+	// 特殊路由不会被淘汰，其计数仍在计数器跨度内
+	clock.Advance(2 * time.Minute)
+	get("/b")
+	get("/c")
+	if got, want := routes(), []string{OverflowRoute, UnmatchedRoute, "GET /b", "GET /c"}; !slices.Equal(got, want) {
+		t.Errorf("after eviction routes = %v, want %v", got, want)
+	}
+	if c := tracker.RouteCardinality(); c.Tracked != 2 || c.Evicted != 1 || c.Overflowed != 1 {
+		t.Errorf("cardinality = %+v", c)
+	}
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"sort"
+	"time"
+)
+
+// sizeBounds 大小直方图的桶上界（字节），最后还有一个 +Inf 桶
+var sizeBounds = []uint64{256, 1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 4 << 20}
+
+// SizeHistogram 请求体或响应体大小分布
+type SizeHistogram struct {
+	Bounds []uint64 `json:"bounds"` // 桶上界（字节，含），Counts 比 Bounds 多一个 +Inf 桶
+	Counts []uint64 `json:"counts"` // 各桶计数
+	Count  uint64   `json:"count"`  // 样本数
+	Sum    uint64   `json:"sum"`    // 总字节数
+	Max    uint64   `json:"max"`    // 最大值
+}
+
+// RouteSizes 某路由的请求体和响应体大小分布
+type RouteSizes struct {
+	Route    string        `json:"route"`
+	Request  SizeHistogram `json:"request"`
+	Response SizeHistogram `json:"response"`
+}
+
+// SizeStats 全局和按路由的大小分布
+type SizeStats struct {
+	Request  SizeHistogram `json:"request"`  // 全部请求的请求体
+	Response SizeHistogram `json:"response"` // 全部请求的响应体
+	Routes   []RouteSizes  `json:"routes"`   // 按路由名排序
+}
+
+// sizeHist 固定桶的大小直方图，由 Tracker.mu 保护
+type sizeHist struct {
+	counts [9]uint64 // len(sizeBounds)+1
+	count  uint64
+	sum    uint64
+	max    uint64
+}
+
+// add 记录一个大小
+func (h *sizeHist) add(n uint64) {
+	i := sort.Search(len(sizeBounds), func(i int) bool { return n <= sizeBounds[i] })
+	h.counts[i]++
+	h.count++
+	h.sum += n
+	if n > h.max {
+		h.max = n
+	}
+}
+
+// snapshot 导出直方图
+func (h *sizeHist) snapshot() SizeHistogram {
+	return SizeHistogram{
+		Bounds: sizeBounds,
+		Counts: append([]uint64(nil), h.counts[:]...),
+		Count:  h.count,
+		Sum:    h.sum,
+		Max:    h.max,
+	}
+}
+
+// routeTraffic 某路由（或全局）的流量统计：生命周期内的大小分布和秒级字节计数
+type routeTraffic struct {
+	reqSize  sizeHist
+	respSize sizeHist
+	bytesIn  *ringCounter
+	bytesOut *ringCounter
+}
+
+// newRouteTraffic 创建流量统计，计数器覆盖 ringSize 秒
+func newRouteTraffic(ringSize int) *routeTraffic {
+	return &routeTraffic{
+		bytesIn:  newRingCounter(ringSize),
+		bytesOut: newRingCounter(ringSize),
+	}
+}
+
+// add 记录一次请求的请求体和响应体大小
+func (rt *routeTraffic) add(sec int64, in, out uint64) {
+	rt.reqSize.add(in)
+	rt.respSize.add(out)
+	rt.bytesIn.add(sec, in)
+	rt.bytesOut.add(sec, out)
+}
+
+// bandwidth 返回窗口内的每秒字节数
+func (rt *routeTraffic) bandwidth(now int64, window time.Duration) (inRate, outRate float64) {
+	sec := windowSeconds(window)
+	return float64(rt.bytesIn.sum(now, sec)) / float64(sec), float64(rt.bytesOut.sum(now, sec)) / float64(sec)
+}
+
+// addTraffic 记录一次请求的流量，调用方须持有 t.mu
+func (t *Tracker) addTraffic(info RequestInfo) {
+	var in, out uint64
+	if info.BytesIn > 0 {
+		in = uint64(info.BytesIn)
+	}
+	if info.Bytes > 0 {
+		out = uint64(info.Bytes)
+	}
+	sec := info.Start.Unix()
+
+	t.traffic.add(sec, in, out)
+	rt, ok := t.trafficByRoute[info.Route]
+	if !ok {
+		rt = newRouteTraffic(t.ringSize)
+		t.trafficByRoute[info.Route] = rt
+	}
+	rt.add(sec, in, out)
+}
+
+// Sizes 返回全局和按路由的请求体、响应体大小分布
+func (t *Tracker) Sizes() SizeStats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	out := SizeStats{
+		Request:  t.traffic.reqSize.snapshot(),
+		Response: t.traffic.respSize.snapshot(),
+		Routes:   make([]RouteSizes, 0, len(t.trafficByRoute)),
+	}
+	for route, rt := range t.trafficByRoute {
+		out.Routes = append(out.Routes, RouteSizes{
+			Route:    route,
+			Request:  rt.reqSize.snapshot(),
+			Response: rt.respSize.snapshot(),
+		})
+	}
+	sort.Slice(out.Routes, func(i, j int) bool { return out.Routes[i].Route < out.Routes[j].Route })
+	return out
+}
@@ -0,0 +1,320 @@
+package metrics
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"runtime"
+	pprof "runtime/pprof"
+	"slices"
+	"time"
+)
+
+const (
+	// SnapshotFormat 快照包清单中的格式标识
+	SnapshotFormat = "analysego-snapshot"
+	// SnapshotVersion 快照包版本，结构不兼容时递增
+	SnapshotVersion = 1
+	// maxImportBytes 导入快照包的最大字节数
+	maxImportBytes = 256 << 20
+	// maxSnapshotFileBytes 快照包内单个文件解压后的最大字节数，防止压缩炸弹
+	maxSnapshotFileBytes = 512 << 20
+	// maxSnapshotSamples 快照包最多包含的历史样本数
+	maxSnapshotSamples = 200_000
+	// maxImportedTrackers 最多同时存在的导入追踪器数，超出时须先删除
+	maxImportedTrackers = 16
+)
+
+// 快照包中的文件
+const (
+	snapshotManifestFile   = "manifest.json"
+	snapshotHistoryFile    = "history.ndjson"
+	snapshotRoutesFile     = "routes.json"
+	snapshotGoroutinesFile = "goroutines.txt"
+	snapshotHeapFile       = "heap.pb.gz"
+)
+
+var (
+	// errTrackerExists 导入时追踪器名称已被使用
+	errTrackerExists = errors.New("Tracker already exists")
+	// errTooManyImports 导入追踪器已达上限
+	errTooManyImports = fmt.Errorf("Too many imported trackers (max %d), delete one first", maxImportedTrackers)
+	// errNotImported 只能删除导入的只读追踪器
+	errNotImported = errors.New("Only imported trackers can be deleted")
+	// errSnapshotTooLarge 快照包内文件解压后过大或样本过多
+	errSnapshotTooLarge = errors.New("snapshot bundle too large")
+	// reTrackerName 导入追踪器的合法名称
+	reTrackerName = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+)
+
+// SnapshotManifest 快照包清单
+type SnapshotManifest struct {
+	Format    string `json:"format"`
+	Version   int    `json:"version"`
+	CreatedAt int64  `json:"createdAt"` // 生成时间戳（毫秒）
+	Source    string `json:"source"`    // 数据源：追踪器名或 collector 实例
+	Host      string `json:"host"`
+	GoVersion string `json:"goVersion"`
+	WindowSec int    `json:"windowSec"` // 历史窗口（秒）
+	Samples   int    `json:"samples"`
+	Routes    int    `json:"routes"`
+}
+
+// Snapshot 快照包中的指标数据
+type Snapshot struct {
+	Manifest SnapshotManifest
+	History  []Sample
+	Routes   []RouteStat
+}
+
+// WriteSnapshot 把快照写为 zip 包，并附带当前进程的 goroutine 栈（文本）和堆 profile（pprof 格式）
+// 清单中的格式、版本、生成时间和计数由本函数填写
+func WriteSnapshot(w io.Writer, s Snapshot) error {
+	m := s.Manifest
+	m.Format = SnapshotFormat
+	m.Version = SnapshotVersion
+	m.CreatedAt = time.Now().UnixMilli()
+	m.GoVersion = runtime.Version()
+	m.Samples = len(s.History)
+	m.Routes = len(s.Routes)
+	if m.Host == "" {
+		m.Host, _ = os.Hostname()
+	}
+
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{snapshotManifestFile, func(w io.Writer) error { return writeIndented(w, m) }},
+		{snapshotHistoryFile, func(w io.Writer) error { return WriteNDJSON(w, s.History, "") }},
+		{snapshotRoutesFile, func(w io.Writer) error { return writeIndented(w, s.Routes) }},
+		{snapshotGoroutinesFile, func(w io.Writer) error { return pprof.Lookup("goroutine").WriteTo(w, 2) }},
+		{snapshotHeapFile, func(w io.Writer) error { return pprof.Lookup("heap").WriteTo(w, 0) }},
+	}
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if err := f.write(fw); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	return zw.Close()
+}
+
+// writeIndented 写出缩进的 JSON
+func writeIndented(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// ReadSnapshot 读取 zip 快照包中的清单、历史和路由统计，profile 文件供 go tool pprof 单独查看
+func ReadSnapshot(r io.ReaderAt, size int64) (Snapshot, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("invalid snapshot bundle: %w", err)
+	}
+
+	var s Snapshot
+	if err := readZipFile(zr, snapshotManifestFile, func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&s.Manifest)
+	}); err != nil {
+		return Snapshot{}, err
+	}
+	if s.Manifest.Format != SnapshotFormat {
+		return Snapshot{}, fmt.Errorf("not a snapshot bundle: format %q", s.Manifest.Format)
+	}
+	if s.Manifest.Version != SnapshotVersion {
+		return Snapshot{}, fmt.Errorf("unsupported snapshot version %d, want %d", s.Manifest.Version, SnapshotVersion)
+	}
+
+	if err := readZipFile(zr, snapshotHistoryFile, func(r io.Reader) error {
+		dec := json.NewDecoder(r)
+		for {
+			var smp Sample
+			if err := dec.Decode(&smp); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if len(s.History) == maxSnapshotSamples {
+				return fmt.Errorf("%w: more than %d samples", errSnapshotTooLarge, maxSnapshotSamples)
+			}
+			s.History = append(s.History, smp)
+		}
+	}); err != nil {
+		return Snapshot{}, err
+	}
+	slices.SortStableFunc(s.History, func(a, b Sample) int { return cmp.Compare(a.Time, b.Time) })
+
+	if err := readZipFile(zr, snapshotRoutesFile, func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&s.Routes)
+	}); err != nil {
+		return Snapshot{}, err
+	}
+	return s, nil
+}
+
+// readZipFile 打开包内文件并交给 read 解析，解压后超过 maxSnapshotFileBytes 时返回 errSnapshotTooLarge
+func readZipFile(zr *zip.Reader, name string, read func(io.Reader) error) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return fmt.Errorf("snapshot bundle: %w", err)
+	}
+	defer f.Close()
+	// 头部记录的大小可以伪造，读取时仍须限制
+	if info, err := f.Stat(); err == nil && info.Size() > maxSnapshotFileBytes {
+		return fmt.Errorf("snapshot bundle %s: %w", name, errSnapshotTooLarge)
+	}
+	if err := read(&limitedReader{r: f, n: maxSnapshotFileBytes}); err != nil {
+		return fmt.Errorf("snapshot bundle %s: %w", name, err)
+	}
+	return nil
+}
+
+// limitedReader 读取超过 n 字节时返回 errSnapshotTooLarge，而不是像 io.LimitReader 那样截断为 EOF
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read 实现 io.Reader
+func (l *limitedReader) Read(p []byte) (int, error) {
+	// 多读一个字节，以区分恰好 n 字节和超出 n 字节
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.n {
+		n, l.n = int(l.n), 0
+		return n, errSnapshotTooLarge
+	}
+	l.n -= int64(n)
+	return n, err
+}
+
+// NewReadOnlyTracker 用导入的快照创建只读追踪器
+// 只读追踪器不采样也不统计请求，当前样本为最后一个历史样本，历史窗口以最后一个样本为终点
+func NewReadOnlyTracker(name string, s Snapshot) *Tracker {
+	t := NewTracker(TrackerConfig{Name: name, MaxHistory: max(len(s.History), 1)})
+	t.readOnly = true
+	t.anomalies = nil
+	t.history = slices.Clone(s.History)
+	t.frozenRoutes = slices.Clone(s.Routes)
+	return t
+}
+
+// ReadOnly 是否为导入快照的只读追踪器
+func (t *Tracker) ReadOnly() bool {
+	return t.readOnly
+}
+
+// ServeSnapshot 下载快照包：历史（window/minutes/hours）、路由统计、goroutine 栈和堆 profile
+func (a *API) ServeSnapshot(w http.ResponseWriter, r *http.Request) {
+	src, err := a.source(r)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	}
+	q := r.URL.Query()
+	source := DefaultTrackerName
+	switch {
+	case q.Get("instance") != "":
+		source = "instance:" + q.Get("instance")
+	case q.Get("tracker") != "":
+		source = q.Get("tracker")
+	case a.opts.Collector != nil:
+		source = "instance:all"
+	}
+	windowSec := ParseWindowSeconds(q, a.opts.MaxWindowSec, a.opts.DefaultWindowSec)
+
+	// 先写入内存，失败时还能返回错误
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, Snapshot{
+		Manifest: SnapshotManifest{Source: source, WindowSec: windowSec},
+		History:  src.HistoryWindow(windowSec),
+		Routes:   src.RouteStats(),
+	}); err != nil {
+		writeError(w, r, http.StatusInternalServerError, err)
+		return
+	}
+	a.opts.Annotations.Record("Snapshot captured: "+source, TagProfile)
+	filename := fmt.Sprintf("snapshot-%s.zip", time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	w.Write(buf.Bytes())
+}
+
+// ServeImport 导入快照包（请求体为 zip），注册为名为 name 的只读追踪器
+// 之后可通过 ?tracker=name 在各指标接口和仪表盘中查看
+func (a *API) ServeImport(w http.ResponseWriter, r *http.Request) {
+	if a.opts.Registry == nil || a.opts.Collector != nil {
+		writeError(w, r, http.StatusNotImplemented, errLocalOnly)
+		return
+	}
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		name = "import-" + time.Now().Format("20060102-150405")
+	}
+	if !reTrackerName.MatchString(name) {
+		writeError(w, r, http.StatusBadRequest, fmt.Errorf("invalid tracker name %q: use 1-64 letters, digits, '.', '_' or '-'", name))
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeError(w, r, status, err)
+		return
+	}
+	s, err := ReadSnapshot(bytes.NewReader(body), int64(len(body)))
+	if errors.Is(err, errSnapshotTooLarge) {
+		writeError(w, r, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if err := a.opts.Registry.registerReadOnly(NewReadOnlyTracker(name, s), maxImportedTrackers); err != nil {
+		writeError(w, r, http.StatusConflict, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"tracker":  name,
+		"manifest": s.Manifest,
+		"samples":  len(s.History),
+		"routes":   len(s.Routes),
+	})
+}
+
+// ServeDeleteImport 删除导入的只读追踪器（?name=），成功返回 204
+func (a *API) ServeDeleteImport(w http.ResponseWriter, r *http.Request) {
+	if a.opts.Registry == nil || a.opts.Collector != nil {
+		writeError(w, r, http.StatusNotImplemented, errLocalOnly)
+		return
+	}
+	err := a.opts.Registry.removeReadOnly(r.URL.Query().Get("name"))
+	switch {
+	case errors.Is(err, errTrackerNotFound):
+		writeError(w, r, http.StatusNotFound, err)
+	case err != nil:
+		writeError(w, r, http.StatusBadRequest, err)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,359 @@
+package metrics
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testSnapshot 覆盖全部字段类型的快照，历史按时间倒序，读取时应重新排序
+func testSnapshot() Snapshot {
+	const start = 1_700_000_000_000
+	var s Snapshot
+	for i := 4; i >= 0; i-- {
+		s.History = append(s.History, Sample{
+			Time: start + int64(i)*1000, Goroutines: 10 + i, Requests: i * 3, QPS: 0.1 * float64(i),
+			BytesInRate: 1.5, BytesOutRate: 1e9 / 3, BytesInTotal: 1 << 40, BytesOutTotal: uint64(i),
+			InFlight: 1, PeakInFlight: 2, HeapAlloc: 123456789, HeapInuse: 2, HeapSys: 3, HeapObjects: 4,
+			NumGC: uint32(i), GCIncrement: 1, BlockLock: 1, BlockIO: 2, BlockPerm: 3,
+		})
+	}
+	s.History[1].Anomalies = []Anomaly{{Time: s.History[1].Time, Series: "qps", Value: 0.3, Mean: 0.1, StdDev: 0.05, Z: 4}}
+	s.Routes = []RouteStat{
+		{Route: "GET /a, \"quoted\"", Requests: 3, QPS: 0.3, BytesInTotal: 10, MemoryUsage: 0.25, CPUUsage: 1.75, BlockIO: 1},
+		{Route: "GET /b", Requests: 1, QPS: 1.0 / 3, BlocksUnavailable: true},
+	}
+	return s
+}
+
+// sortedHistory 按时间升序的历史
+func sortedHistory(s Snapshot) []Sample {
+	h := append([]Sample(nil), s.History...)
+	for i, j := 0, len(h)-1; i < j; i, j = i+1, j-1 {
+		h[i], h[j] = h[j], h[i]
+	}
+	return h
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	s := testSnapshot()
+	s.Manifest = SnapshotManifest{Source: "blog", WindowSec: 600}
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSnapshot(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := got.Manifest
+	if m.Format != SnapshotFormat || m.Version != SnapshotVersion || m.Source != "blog" || m.WindowSec != 600 || m.Samples != 5 || m.Routes != 2 || m.CreatedAt == 0 {
+		t.Errorf("manifest = %+v", m)
+	}
+	if !reflect.DeepEqual(got.History, sortedHistory(s)) {
+		t.Errorf("history = %+v\nwant %+v", got.History, sortedHistory(s))
+	}
+	if !reflect.DeepEqual(got.Routes, s.Routes) {
+		t.Errorf("routes = %+v\nwant %+v", got.Routes, s.Routes)
+	}
+}
+
+// decodeCSV 按表头把 CSV 解码回结构体：字符串原样，其余按 JSON 解析
+func decodeCSV[T any](t *testing.T, r io.Reader) []T {
+	t.Helper()
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := exportFields(reflect.TypeFor[T]())
+	if len(records) == 0 || len(records[0]) != len(fields) {
+		t.Fatalf("csv header = %v, want %d fields", records, len(fields))
+	}
+	var out []T
+	for _, rec := range records[1:] {
+		var row T
+		v := reflect.ValueOf(&row).Elem()
+		for i, f := range fields {
+			if records[0][i] != f.name {
+				t.Fatalf("csv column %d = %q, want %q", i, records[0][i], f.name)
+			}
+			fv := v.Field(f.index)
+			if fv.Kind() == reflect.String {
+				fv.SetString(rec[i])
+				continue
+			}
+			if err := json.Unmarshal([]byte(rec[i]), fv.Addr().Interface()); err != nil {
+				t.Fatalf("csv %s = %q: %v", f.name, rec[i], err)
+			}
+		}
+		out = append(out, row)
+	}
+	return out
+}
+
+// decodeNDJSON 逐行解码 NDJSON
+func decodeNDJSON[T any](t *testing.T, r io.Reader) []T {
+	t.Helper()
+	var out []T
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		var row T
+		dec := json.NewDecoder(strings.NewReader(sc.Text()))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&row); err != nil {
+			t.Fatalf("ndjson line %s: %v", sc.Text(), err)
+		}
+		out = append(out, row)
+	}
+	return out
+}
+
+// doRequest 发送请求并返回响应
+func doRequest(t *testing.T, method, url string, body []byte) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestSnapshotExportImportRoundTrip(t *testing.T) {
+	s := testSnapshot()
+	api := NewAPI(NewReadOnlyTracker(DefaultTrackerName, s), NewHub(), APIOptions{Registry: NewRegistry(TrackerConfig{})})
+	srv := httptest.NewServer(api.Handler())
+	defer srv.Close()
+
+	resp := doRequest(t, http.MethodGet, srv.URL+"/metrics/snapshot", nil)
+	bundle, err := io.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("snapshot: %s, %v", resp.Status, err)
+	}
+	if resp := doRequest(t, http.MethodPost, srv.URL+"/metrics/import?name=copy", bundle); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("import: %s", resp.Status)
+	}
+
+	want := sortedHistory(s)
+	routes := s.Routes // 导出按路由名排序，testSnapshot 已有序
+	t.Run("ndjson", func(t *testing.T) {
+		resp := doRequest(t, http.MethodGet, srv.URL+"/metrics/export/history?tracker=copy&format=ndjson", nil)
+		if got := decodeNDJSON[Sample](t, resp.Body); !reflect.DeepEqual(got, want) {
+			t.Errorf("history = %+v\nwant %+v", got, want)
+		}
+		resp = doRequest(t, http.MethodGet, srv.URL+"/metrics/export/routes?tracker=copy&format=ndjson", nil)
+		if got := decodeNDJSON[RouteStat](t, resp.Body); !reflect.DeepEqual(got, routes) {
+			t.Errorf("routes = %+v\nwant %+v", got, routes)
+		}
+	})
+	t.Run("csv", func(t *testing.T) {
+		resp := doRequest(t, http.MethodGet, srv.URL+"/metrics/export/history?tracker=copy&format=csv", nil)
+		if got := decodeCSV[Sample](t, resp.Body); !reflect.DeepEqual(got, want) {
+			t.Errorf("history = %+v\nwant %+v", got, want)
+		}
+		resp = doRequest(t, http.MethodGet, srv.URL+"/metrics/export/routes?tracker=copy&format=csv", nil)
+		if got := decodeCSV[RouteStat](t, resp.Body); !reflect.DeepEqual(got, routes) {
+			t.Errorf("routes = %+v\nwant %+v", got, routes)
+		}
+	})
+	t.Run("selected fields", func(t *testing.T) {
+		resp := doRequest(t, http.MethodGet, srv.URL+"/metrics/export/history?tracker=copy&format=csv&fields=qps,time", nil)
+		records, err := csv.NewReader(resp.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 6 || !reflect.DeepEqual(records[0], []string{"qps", "time"}) || records[1][1] != strconv.FormatInt(want[0].Time, 10) {
+			t.Errorf("records = %v", records)
+		}
+		if resp := doRequest(t, http.MethodGet, srv.URL+"/metrics/export/history?fields=nope", nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("unknown field: %s", resp.Status)
+		}
+		if resp := doRequest(t, http.MethodGet, srv.URL+"/metrics/export/history?format=xml", nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("unknown format: %s", resp.Status)
+		}
+	})
+}
+
+// zipEntry 测试用快照包中的一个文件，size 非零时伪造头部记录的解压后大小
+type zipEntry struct {
+	name string
+	data []byte
+	size uint64
+}
+
+// buildBundle 按 entries 生成 zip 包
+func buildBundle(t *testing.T, entries ...zipEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		var w io.Writer
+		var err error
+		if e.size == 0 {
+			w, err = zw.Create(e.name)
+		} else {
+			w, err = zw.CreateRaw(&zip.FileHeader{
+				Name:               e.name,
+				Method:             zip.Store,
+				CRC32:              crc32.ChecksumIEEE(e.data),
+				CompressedSize64:   uint64(len(e.data)),
+				UncompressedSize64: e.size,
+			})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(e.data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// manifestEntry 合法的清单文件
+func manifestEntry(t *testing.T) zipEntry {
+	data, err := json.Marshal(SnapshotManifest{Format: SnapshotFormat, Version: SnapshotVersion})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return zipEntry{name: snapshotManifestFile, data: data}
+}
+
+// historyEntry n 个样本的历史文件
+func historyEntry(n int) zipEntry {
+	var b []byte
+	for i := range n {
+		b = append(b, `{"time":`...)
+		b = strconv.AppendInt(b, int64(i), 10)
+		b = append(b, "}\n"...)
+	}
+	return zipEntry{name: snapshotHistoryFile, data: b}
+}
+
+func TestReadSnapshotLimits(t *testing.T) {
+	routes := zipEntry{name: snapshotRoutesFile, data: []byte("[]")}
+	tests := []struct {
+		name     string
+		entries  []zipEntry
+		tooLarge bool   // 应返回 errSnapshotTooLarge
+		wantErr  string // 其他错误的片段，为空且 tooLarge 为 false 时应成功
+	}{
+		{"sample limit", []zipEntry{manifestEntry(t), historyEntry(maxSnapshotSamples), routes}, false, ""},
+		{"too many samples", []zipEntry{manifestEntry(t), historyEntry(maxSnapshotSamples + 1), routes}, true, ""},
+		{"declared size too large", []zipEntry{manifestEntry(t), historyEntry(1), {name: snapshotRoutesFile, data: []byte("[]"), size: maxSnapshotFileBytes + 1}}, true, ""},
+		{"wrong format", []zipEntry{{name: snapshotManifestFile, data: []byte(`{"format":"other","version":1}`)}}, false, `format "other"`},
+		{"wrong version", []zipEntry{{name: snapshotManifestFile, data: []byte(`{"format":"analysego-snapshot","version":99}`)}}, false, "unsupported snapshot version 99"},
+		{"missing routes", []zipEntry{manifestEntry(t), historyEntry(1)}, false, "routes.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := buildBundle(t, tt.entries...)
+			_, err := ReadSnapshot(bytes.NewReader(b), int64(len(b)))
+			switch {
+			case tt.tooLarge:
+				if !errors.Is(err, errSnapshotTooLarge) {
+					t.Errorf("err = %v, want %v", err, errSnapshotTooLarge)
+				}
+			case tt.wantErr != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("err = %v, want %q", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Errorf("err = %v", err)
+			}
+		})
+	}
+
+	if _, err := ReadSnapshot(strings.NewReader("not a zip"), 9); err == nil || !strings.Contains(err.Error(), "invalid snapshot bundle") {
+		t.Errorf("not a zip: err = %v", err)
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	// 超出时返回错误而不是截断为 EOF
+	r := &limitedReader{r: strings.NewReader("0123456789"), n: 4}
+	got, err := io.ReadAll(r)
+	if string(got) != "0123" || !errors.Is(err, errSnapshotTooLarge) {
+		t.Errorf("ReadAll = %q, %v; want 0123, %v", got, err, errSnapshotTooLarge)
+	}
+	r = &limitedReader{r: strings.NewReader("0123"), n: 4}
+	if got, err := io.ReadAll(r); string(got) != "0123" || err != nil {
+		t.Errorf("ReadAll at limit = %q, %v; want 0123, nil", got, err)
+	}
+	r = &limitedReader{r: strings.NewReader("01234"), n: 4}
+	buf := make([]byte, 2)
+	var read []byte
+	for {
+		n, err := r.Read(buf)
+		read = append(read, buf[:n]...)
+		if err != nil {
+			if string(read) != "0123" || !errors.Is(err, errSnapshotTooLarge) {
+				t.Errorf("small reads = %q, %v", read, err)
+			}
+			break
+		}
+	}
+}
+
+func TestImportLimits(t *testing.T) {
+	api := NewAPI(NewTracker(TrackerConfig{}), NewHub(), APIOptions{Registry: NewRegistry(TrackerConfig{})})
+	srv := httptest.NewServer(api.Handler())
+	defer srv.Close()
+	bundle := buildBundle(t, manifestEntry(t), historyEntry(3), zipEntry{name: snapshotRoutesFile, data: []byte("[]")})
+	importAs := func(name string, body []byte) int {
+		return doRequest(t, http.MethodPost, srv.URL+"/metrics/import?name="+name, body).StatusCode
+	}
+
+	for i := range maxImportedTrackers {
+		if code := importAs(fmt.Sprintf("snap-%d", i), bundle); code != http.StatusCreated {
+			t.Fatalf("import %d: status = %d", i, code)
+		}
+	}
+	resp := doRequest(t, http.MethodPost, srv.URL+"/metrics/import?name=one-more", bundle)
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusConflict || !strings.Contains(string(body), "Too many imported trackers") {
+		t.Errorf("over limit: %s %s", resp.Status, body)
+	}
+	if code := doRequest(t, http.MethodDelete, srv.URL+"/metrics/import?name=snap-0", nil).StatusCode; code != http.StatusNoContent {
+		t.Errorf("delete: status = %d", code)
+	}
+	if code := importAs("one-more", bundle); code != http.StatusCreated {
+		t.Errorf("import after delete: status = %d", code)
+	}
+
+	tests := []struct {
+		name, tracker string
+		body          []byte
+		want          int
+	}{
+		{"duplicate name", "snap-1", bundle, http.StatusConflict},
+		{"invalid name", "a/b", bundle, http.StatusBadRequest},
+		{"not a bundle", "junk", []byte("junk"), http.StatusBadRequest},
+		{"decompressed size", "bomb", buildBundle(t, manifestEntry(t), zipEntry{name: snapshotHistoryFile, data: []byte("{}"), size: maxSnapshotFileBytes + 1}), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		if code := importAs(tt.tracker, tt.body); code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, code, tt.want)
+		}
+	}
+	if code := doRequest(t, http.MethodDelete, srv.URL+"/metrics/import?name=nope", nil).StatusCode; code != http.StatusNotFound {
+		t.Errorf("delete unknown: status = %d, want 404", code)
+	}
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"io"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// RouteTotals 某路由自上次重置以来的累计统计
+type RouteTotals struct {
+	Route        string  `json:"route"`        // 路由
+	Requests     uint64  `json:"requests"`     // 总请求数
+	BytesIn      uint64  `json:"bytesIn"`      // 请求体总字节数
+	BytesOut     uint64  `json:"bytesOut"`     // 响应体总字节数
+	LatencyMs    float64 `json:"latencyMs"`    // 总耗时（毫秒）
+	AvgLatencyMs float64 `json:"avgLatencyMs"` // 平均耗时（毫秒）
+	AllocBytes   uint64  `json:"allocBytes"`   // 处理期间的堆内存增量总和（字节）
+	FirstSeen    int64   `json:"firstSeen"`    // 首次请求时间戳（毫秒）
+	LastSeen     int64   `json:"lastSeen"`     // 最近请求时间戳（毫秒）
+}
+
+// TotalsSnapshot 某一时间段内全部路由的累计统计
+type TotalsSnapshot struct {
+	Since  int64         `json:"since"`  // 统计起点（毫秒），即创建或上次重置的时间
+	Until  int64         `json:"until"`  // 快照时间（毫秒）
+	Routes []RouteTotals `json:"routes"` // 按路由名排序
+}
+
+// routeTotals 累计值，由 Tracker.mu 保护
+type routeTotals struct {
+	requests   uint64
+	bytesIn    uint64
+	bytesOut   uint64
+	latency    time.Duration
+	allocBytes uint64
+	firstSeen  time.Time
+	lastSeen   time.Time
+}
+
+// addTotals 累加一次请求，调用方须持有 t.mu
+func (t *Tracker) addTotals(info RequestInfo) {
+	rt, ok := t.totals[info.Route]
+	if !ok {
+		rt = &routeTotals{firstSeen: info.Start}
+		t.totals[info.Route] = rt
+	}
+	rt.requests++
+	if info.BytesIn > 0 {
+		rt.bytesIn += uint64(info.BytesIn)
+	}
+	if info.Bytes > 0 {
+		rt.bytesOut += uint64(info.Bytes)
+	}
+	rt.latency += info.Duration
+	rt.allocBytes += info.AllocBytes
+	rt.lastSeen = info.Start.Add(info.Duration)
+}
+
+// RecordRequest 把一次完成的请求计入累计统计和流量统计，由 Track 调用
+func (t *Tracker) RecordRequest(info RequestInfo) {
+	t.mu.Lock()
+	t.addTotals(info)
+	t.addTraffic(info)
+	t.mu.Unlock()
+	if t.anomalies != nil {
+		t.anomalies.record(info)
+	}
+}
+
+// Totals 返回自上次重置以来的累计统计
+func (t *Tracker) Totals() TotalsSnapshot {
+	now := t.clock.Now()
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.totalsSnapshot(now)
+}
+
+// ResetTotals 返回当前累计统计并清零，用于测量两个时间点之间的实验数据
+func (t *Tracker) ResetTotals() TotalsSnapshot {
+	now := t.clock.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	snap := t.totalsSnapshot(now)
+	t.totals = make(map[string]*routeTotals)
+	t.totalsSince = now
+	return snap
+}
+
+// totalsSnapshot 生成快照，调用方须持有 t.mu
+func (t *Tracker) totalsSnapshot(now time.Time) TotalsSnapshot {
+	out := TotalsSnapshot{
+		Since:  t.totalsSince.UnixMilli(),
+		Until:  now.UnixMilli(),
+		Routes: make([]RouteTotals, 0, len(t.totals)),
+	}
+	for route, rt := range t.totals {
+		latencyMs := float64(rt.latency) / float64(time.Millisecond)
+		var avg float64
+		if rt.requests > 0 {
+			avg = latencyMs / float64(rt.requests)
+		}
+		out.Routes = append(out.Routes, RouteTotals{
+			Route:        route,
+			Requests:     rt.requests,
+			BytesIn:      rt.bytesIn,
+			BytesOut:     rt.bytesOut,
+			LatencyMs:    latencyMs,
+			AvgLatencyMs: avg,
+			AllocBytes:   rt.allocBytes,
+			FirstSeen:    rt.firstSeen.UnixMilli(),
+			LastSeen:     rt.lastSeen.UnixMilli(),
+		})
+	}
+	sort.Slice(out.Routes, func(i, j int) bool { return out.Routes[i].Route < out.Routes[j].Route })
+	return out
+}
+
+// countingBody 统计请求体实际读取的字节数，用于没有 Content-Length 的分块请求
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+// Read 读取并计数
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
@@ -1,89 +1,313 @@
 package metrics
 
 import (
-	"bytes"
-	"regexp"
+	"context"
+	"errors"
 	"runtime"
-	pprof "runtime/pprof"
-	"strconv"
-	"strings"
+	"slices"
 	"sync"
 	"time"
 )
 
 const (
-	// maxHistory 最多保留的历史样本数量（86400秒 = 24小时，每秒一个样本）
-	maxHistory = 86400
-	// requestWindowDuration 统计请求数的时间窗口
-	requestWindowDuration = 10 * time.Second
+	// defaultMaxHistory 默认最多保留的历史样本数量（86400秒 = 24小时，每秒一个样本）
+	defaultMaxHistory = 86400
+	// defaultRequestWindow 默认统计请求数的时间窗口
+	defaultRequestWindow = 10 * time.Second
+	// defaultSampleInterval 默认采样间隔
+	defaultSampleInterval = time.Second
+	// DefaultTrackerName 未指定名称时的追踪器名称
+	DefaultTrackerName = "default"
 )
 
+// defaultRateWindows 默认的请求速率统计窗口
+var defaultRateWindows = []time.Duration{10 * time.Second, time.Minute, 5 * time.Minute}
+
+// errTrackerStarted 重复调用 Start
+var errTrackerStarted = errors.New("tracker already started")
+
+// errTrackerReadOnly 只读追踪器不能启动采样
+var errTrackerReadOnly = errors.New("tracker is read-only")
+
+// TrackerConfig 追踪器配置，零值字段使用默认值
+type TrackerConfig struct {
+	Name          string        // 追踪器名称，默认 "default"
+	MaxHistory    int           // 最多保留的历史样本数量
+	RequestWindow time.Duration // 统计请求数的时间窗口，用于 Sample.Requests 和 RouteStat.Requests
+	// RateWindows Rates 输出的速率窗口，默认 10s、1m、5m；计数器按最大窗口分配固定数量的秒级桶
+	RateWindows []time.Duration
+	Clock       Clock // 时间来源，默认系统时间
+	Sampler     SamplerOptions
+	Routes      RouteOptions   // 路由名归一化与基数限制
+	Anomaly     AnomalyOptions // 样本和路由序列的异常检测
+}
+
+// SamplerOptions Start 启动的后台采样器配置
+type SamplerOptions struct {
+	Interval   time.Duration // 采样间隔，默认1秒
+	SkipBlocks bool          // 跳过 goroutine 阻塞分类（需要抓取 goroutine profile，开销较大）
+	// OnSample 每次采样并写入历史后调用，在采样协程中同步执行
+	OnSample func(Sample)
+}
+
 // Sample 指标样本数据结构
 type Sample struct {
-	Time        int64  `json:"time"`        // 时间戳（毫秒）
-	Goroutines  int    `json:"goroutines"`  // Goroutine 数量
-	Requests    int    `json:"requests"`    // 最近10秒的请求数
-	HeapAlloc   uint64 `json:"heapAlloc"`   // 堆内存已分配（字节）
-	HeapInuse   uint64 `json:"heapInuse"`   // 堆内存使用中（字节）
-	HeapSys     uint64 `json:"heapSys"`     // 堆内存系统占用（字节）
-	HeapObjects uint64 `json:"heapObjects"` // 堆对象数量
-	NumGC       uint32 `json:"numGC"`       // GC次数（累计）
-	GCIncrement uint32 `json:"gcIncrement"` // 本次采样期间的GC增量
-	BlockLock   int    `json:"blockLock"`   // 锁阻塞的 goroutine 数量
-	BlockIO     int    `json:"blockIO"`     // IO 阻塞的 goroutine 数量
-	BlockPerm   int    `json:"blockPerm"`   // 持续≥10秒的阻塞 goroutine 数量
-}
-
-// Tracker 负责指标采样和请求统计
+	Time          int64   `json:"time"`          // 时间戳（毫秒）
+	Goroutines    int     `json:"goroutines"`    // Goroutine 数量
+	Requests      int     `json:"requests"`      // 最近一个请求窗口（默认10秒）的请求数
+	QPS           float64 `json:"qps"`           // 最近一个请求窗口的平均每秒请求数
+	BytesInRate   float64 `json:"bytesInRate"`   // 最近一个请求窗口的请求体带宽（字节/秒）
+	BytesOutRate  float64 `json:"bytesOutRate"`  // 最近一个请求窗口的响应体带宽（字节/秒）
+	BytesInTotal  uint64  `json:"bytesInTotal"`  // 累计请求体字节数
+	BytesOutTotal uint64  `json:"bytesOutTotal"` // 累计响应体字节数
+	InFlight      int     `json:"inFlight"`      // 正在处理的请求数
+	PeakInFlight  int     `json:"peakInFlight"`  // 本采样周期内的最大并发请求数
+	HeapAlloc     uint64  `json:"heapAlloc"`     // 堆内存已分配（字节）
+	HeapInuse     uint64  `json:"heapInuse"`     // 堆内存使用中（字节）
+	HeapSys       uint64  `json:"heapSys"`       // 堆内存系统占用（字节）
+	HeapObjects   uint64  `json:"heapObjects"`   // 堆对象数量
+	NumGC         uint32  `json:"numGC"`         // GC次数（累计）
+	GCIncrement   uint32  `json:"gcIncrement"`   // 本次采样期间的GC增量
+	BlockLock     int     `json:"blockLock"`     // 锁阻塞的 goroutine 数量
+	BlockIO       int     `json:"blockIO"`       // IO 阻塞的 goroutine 数量
+	BlockPerm     int     `json:"blockPerm"`     // 持续≥10秒的阻塞 goroutine 数量
+	// Anomalies 写入历史时检测出的异常点，实时样本和无异常时为空
+	Anomalies []Anomaly `json:"anomalies,omitempty"`
+}
+
+// Tracker 负责指标采样和请求统计，可被多个协程并发使用
 type Tracker struct {
+	name          string
+	maxHistory    int
+	requestWindow time.Duration
+	rateWindows   []time.Duration
+	ringSize      int // 计数器桶数（秒），覆盖最大的统计窗口
+	clock         Clock
+	sampler       SamplerOptions
+	// readOnly 导入的快照：不采样，当前样本取历史最后一个，路由统计固定为 frozenRoutes
+	readOnly     bool
+	frozenRoutes []RouteStat
+	// anomalies 异常检测器，关闭时为 nil
+	anomalies *anomalyDetector
+
 	mu sync.RWMutex
-	// reqTimes 记录每次请求到达的时间戳（毫秒）
-	reqTimes []int64
-	// reqByRoute 按路由记录最近请求时间戳（毫秒）
-	reqByRoute map[string][]int64
-
-	histMu            sync.RWMutex
-	history           []Sample          // 历史样本数据
-	lastNumGC         uint32            // 上一次采样的GC次数（用于计算增量）
+	// reqs 全部请求的秒级计数
+	reqs *ringCounter
+	// reqByRoute 按路由的秒级计数，超过 ringSize 秒无请求的路由会被清理
+	reqByRoute map[string]*ringCounter
+	// routes 已跟踪的路由，超过上限或空闲时淘汰并清理下面的按路由数据
+	routes *routeTable
+	// traffic、trafficByRoute 全局和按路由的流量统计（大小分布、带宽）
+	traffic        *routeTraffic
+	trafficByRoute map[string]*routeTraffic
+	// inFlight、inFlightByRoute 全局和按路由的进行中请求数，峰值在每次 PushSample 后重置
+	inFlight        concurrency
+	inFlightByRoute map[string]*concurrency
+	// totals 自 totalsSince 以来的按路由累计统计，可通过 ResetTotals 清零
+	totals      map[string]*routeTotals
+	totalsSince time.Time
+
 	routeMemory       map[string]uint64 // 按路由记录的内存使用（字节）
 	routeRequestCount map[string]uint64 // 按路由记录的总请求数（用于计算平均内存）
 	routeCPUTime      map[string]int64  // 按路由记录的CPU时间（纳秒）
-	lastMemStats      runtime.MemStats  // 上一次的内存统计
+
+	histMu    sync.RWMutex
+	history   []Sample // 历史样本数据
+	lastNumGC uint32   // 上一次写入历史的样本的GC次数（用于计算增量），由 histMu 保护
+
+	// pushMu 串行化 PushSample，保证历史时间递增且 GC 增量不重复计算
+	pushMu sync.Mutex
+
+	runMu   sync.Mutex
+	cancel  context.CancelFunc // 非空表示采样器正在运行
+	stopped chan struct{}      // 采样协程退出后关闭
+
+	hookMu   sync.RWMutex
+	hooks    []RequestHook    // 请求生命周期钩子
+	flushers []HistoryFlusher // 关闭时持久化历史的钩子
 }
 
 // NewTracker 创建新的追踪器实例
-func NewTracker() *Tracker {
+func NewTracker(cfg TrackerConfig) *Tracker {
+	if cfg.MaxHistory <= 0 {
+		cfg.MaxHistory = defaultMaxHistory
+	}
+	if cfg.RequestWindow <= 0 {
+		cfg.RequestWindow = defaultRequestWindow
+	}
+	if cfg.Name == "" {
+		cfg.Name = DefaultTrackerName
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = SystemClock{}
+	}
+	if cfg.Sampler.Interval <= 0 {
+		cfg.Sampler.Interval = defaultSampleInterval
+	}
+	if len(cfg.RateWindows) == 0 {
+		cfg.RateWindows = defaultRateWindows
+	}
+	ringSize := windowSeconds(cfg.RequestWindow)
+	for _, w := range cfg.RateWindows {
+		ringSize = max(ringSize, windowSeconds(w))
+	}
+
 	var ms runtime.MemStats
 	runtime.ReadMemStats(&ms)
 	return &Tracker{
-		reqByRoute:        make(map[string][]int64),
+		name:              cfg.Name,
+		maxHistory:        cfg.MaxHistory,
+		requestWindow:     cfg.RequestWindow,
+		rateWindows:       append([]time.Duration(nil), cfg.RateWindows...),
+		ringSize:          ringSize,
+		clock:             cfg.Clock,
+		sampler:           cfg.Sampler,
+		reqs:              newRingCounter(ringSize),
+		reqByRoute:        make(map[string]*ringCounter),
+		routes:            newRouteTable(cfg.Routes),
+		traffic:           newRouteTraffic(ringSize),
+		trafficByRoute:    make(map[string]*routeTraffic),
+		inFlightByRoute:   make(map[string]*concurrency),
+		totals:            make(map[string]*routeTotals),
+		totalsSince:       cfg.Clock.Now(),
 		routeMemory:       make(map[string]uint64),
 		routeRequestCount: make(map[string]uint64),
 		routeCPUTime:      make(map[string]int64),
-		lastMemStats:      ms,
+		lastNumGC:         ms.NumGC,
+		anomalies:         newAnomalyDetector(cfg.Name, cfg.Anomaly),
+	}
+}
+
+// Name 返回追踪器名称
+func (t *Tracker) Name() string {
+	return t.name
+}
+
+// Now 返回追踪器时钟的当前时间
+func (t *Tracker) Now() time.Time {
+	return t.clock.Now()
+}
+
+// Start 启动后台采样器，直到 ctx 结束或调用 Stop
+// 已在运行时返回错误
+func (t *Tracker) Start(ctx context.Context) error {
+	if t.readOnly {
+		return errTrackerReadOnly
 	}
+	t.runMu.Lock()
+	defer t.runMu.Unlock()
+	if t.cancel != nil {
+		return errTrackerStarted
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	t.cancel = cancel
+	t.stopped = make(chan struct{})
+	go t.runSampler(ctx, t.stopped)
+	return nil
 }
 
-// AddRequest 记录一次请求到达
+// Stop 停止后台采样器并等待其退出，退出前会再采一个样本；未启动时直接返回
+// Stop 之后可以再次 Start
+func (t *Tracker) Stop() {
+	t.runMu.Lock()
+	cancel, stopped := t.cancel, t.stopped
+	t.cancel, t.stopped = nil, nil
+	t.runMu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-stopped
+}
+
+// runSampler 按间隔采样，ctx 结束时再采一个样本后退出
+func (t *Tracker) runSampler(ctx context.Context, stopped chan struct{}) {
+	defer close(stopped)
+
+	ticker := t.clock.NewTicker(t.sampler.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			t.PushSample()
+			return
+		case <-ticker.C():
+			t.PushSample()
+		}
+	}
+}
+
+// AddHook 注册请求生命周期钩子，所有向该 Tracker 上报的中间件都会调用
+func (t *Tracker) AddHook(h RequestHook) {
+	t.hookMu.Lock()
+	t.hooks = append(t.hooks, h)
+	t.hookMu.Unlock()
+}
+
+// requestHooks 返回当前已注册钩子的快照
+func (t *Tracker) requestHooks() []RequestHook {
+	t.hookMu.RLock()
+	defer t.hookMu.RUnlock()
+	return t.hooks
+}
+
+// Route 归一化路由名并登记到路由表，返回应使用的路由名
+// 路由数达到上限时可能淘汰空闲路由或返回 OverflowRoute；中间件在记录请求前调用
+func (t *Tracker) Route(route string) string {
+	route = t.routes.normalize(route)
+	now := t.clock.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	route, evicted := t.routes.admit(route, now)
+	if evicted != "" {
+		t.dropRoute(evicted)
+	}
+	return route
+}
+
+// RouteCardinality 返回路由基数统计
+func (t *Tracker) RouteCardinality() RouteCardinality {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.routes.cardinality()
+}
+
+// dropRoute 删除某路由的全部统计，调用方须持有 t.mu
+func (t *Tracker) dropRoute(route string) {
+	delete(t.reqByRoute, route)
+	delete(t.routeMemory, route)
+	delete(t.routeRequestCount, route)
+	delete(t.routeCPUTime, route)
+	delete(t.totals, route)
+	delete(t.trafficByRoute, route)
+}
+
+// AddRequest 记录一次请求到达，ts 为毫秒时间戳，0 表示当前时间
 func (t *Tracker) AddRequest(ts int64) {
 	if ts == 0 {
-		ts = time.Now().UnixMilli()
+		ts = t.clock.Now().UnixMilli()
 	}
 	t.mu.Lock()
-	t.reqTimes = append(t.reqTimes, ts)
+	t.reqs.add(ts/1000, 1)
 	t.mu.Unlock()
 }
 
-// AddRequestRoute 记录某路由一次请求到达
+// AddRequestRoute 记录某路由一次请求到达，ts 为毫秒时间戳，0 表示当前时间
 func (t *Tracker) AddRequestRoute(route string, ts int64) {
 	if ts == 0 {
-		ts = time.Now().UnixMilli()
+		ts = t.clock.Now().UnixMilli()
 	}
 	t.mu.Lock()
-	if t.reqByRoute == nil {
-		t.reqByRoute = make(map[string][]int64)
+	c, ok := t.reqByRoute[route]
+	if !ok {
+		c = newRingCounter(t.ringSize)
+		t.reqByRoute[route] = c
 	}
-	t.reqByRoute[route] = append(t.reqByRoute[route], ts)
+	c.add(ts/1000, 1)
 	t.mu.Unlock()
 }
 
@@ -111,246 +335,159 @@ func (t *Tracker) AddRouteCPUTime(route string, cpuTimeNs int64) {
 	t.mu.Unlock()
 }
 
-// requestsInWindow 统计最近 duration 内的请求数，并清理过期数据
+// requestsInWindow 统计最近 duration 内的请求数
 func (t *Tracker) requestsInWindow(duration time.Duration) int {
-	cutoff := time.Now().Add(-duration).UnixMilli()
+	now := t.clock.Now().Unix()
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return int(t.reqs.sum(now, windowSeconds(duration)))
+}
+
+// requestsInWindowByRoute 统计最近 duration 内每个路由的请求数，并清理已无有效计数的路由
+func (t *Tracker) requestsInWindowByRoute(duration time.Duration) map[string]int {
+	now := t.clock.Now().Unix()
+	sec := windowSeconds(duration)
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	// 找到第一个未过期的时间戳
-	i := 0
-	for ; i < len(t.reqTimes); i++ {
-		if t.reqTimes[i] >= cutoff {
-			break
+	res := make(map[string]int, len(t.reqByRoute))
+	for route, c := range t.reqByRoute {
+		if c.idle(now) {
+			delete(t.reqByRoute, route)
+			continue
 		}
+		res[route] = int(c.sum(now, sec))
 	}
+	return res
+}
 
-	// 清理过期数据
-	if i > 0 {
-		t.reqTimes = t.reqTimes[i:]
+// pruneRoutes 清理超过计数器跨度没有请求的路由计数，并淘汰空闲超过 IdleTTL 的路由
+func (t *Tracker) pruneRoutes() {
+	now := t.clock.Now()
+	t.mu.Lock()
+	for route, c := range t.reqByRoute {
+		if c.idle(now.Unix()) {
+			delete(t.reqByRoute, route)
+		}
 	}
-
-	return len(t.reqTimes)
+	for _, route := range t.routes.expire(now) {
+		t.dropRoute(route)
+	}
+	t.mu.Unlock()
 }
 
-// requestsInWindowByRoute 统计最近 duration 内每个路由的请求数，并清理过期数据
-func (t *Tracker) requestsInWindowByRoute(duration time.Duration) map[string]int {
-	cutoff := time.Now().Add(-duration).UnixMilli()
-	t.mu.Lock()
-	defer t.mu.Unlock()
+// Rates 返回全局和按路由的多窗口请求数与 QPS
+func (t *Tracker) Rates() RateStats {
+	now := t.clock.Now()
+	sec := now.Unix()
 
-	res := make(map[string]int, len(t.reqByRoute))
-	for route, times := range t.reqByRoute {
-		// 找到第一个未过期的时间戳
-		i := 0
-		for ; i < len(times); i++ {
-			if times[i] >= cutoff {
-				break
-			}
-		}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 
-		// 清理过期数据
-		if i > 0 {
-			times = times[i:]
-			t.reqByRoute[route] = times
+	out := RateStats{
+		Time:   now.UnixMilli(),
+		Total:  windowRates(t.reqs, sec, t.rateWindows),
+		Routes: make(map[string][]WindowRate, len(t.reqByRoute)),
+	}
+	for route, c := range t.reqByRoute {
+		if c.idle(sec) || route == "" {
+			continue
 		}
-
-		res[route] = len(times)
+		out.Routes[route] = windowRates(c, sec, t.rateWindows)
 	}
-
-	return res
+	return out
 }
 
 // CurrentSample 返回当前时刻的指标采样
+// GC 增量相对于最近一次写入历史的样本计算，不修改追踪器状态，可并发调用
 func (t *Tracker) CurrentSample() Sample {
+	if t.readOnly {
+		t.histMu.RLock()
+		defer t.histMu.RUnlock()
+		if n := len(t.history); n > 0 {
+			return t.history[n-1]
+		}
+		return Sample{}
+	}
+
 	var ms runtime.MemStats
 	runtime.ReadMemStats(&ms)
 
-	blockLock, blockIO, blockPerm := classifyBlocks()
+	var blockLock, blockIO, blockPerm int
+	if !t.sampler.SkipBlocks {
+		blockLock, blockIO, blockPerm = classifyBlocks()
+	}
 
 	// 计算GC增量
 	currentNumGC := ms.NumGC
+	t.histMu.RLock()
+	lastNumGC := t.lastNumGC
+	t.histMu.RUnlock()
 	var gcIncrement uint32
-	if currentNumGC >= t.lastNumGC {
-		gcIncrement = currentNumGC - t.lastNumGC
+	if currentNumGC >= lastNumGC {
+		gcIncrement = currentNumGC - lastNumGC
 	} else {
 		// GC次数重置（理论上不应该发生，但处理溢出情况）
 		gcIncrement = currentNumGC
 	}
-	t.lastNumGC = currentNumGC
 
-	return Sample{
-		Time:        time.Now().UnixMilli(),
-		Goroutines:  runtime.NumGoroutine(),
-		Requests:    t.requestsInWindow(requestWindowDuration),
-		HeapAlloc:   ms.HeapAlloc,
-		HeapInuse:   ms.HeapInuse,
-		HeapSys:     ms.HeapSys,
-		HeapObjects: ms.HeapObjects,
-		NumGC:       currentNumGC,
-		GCIncrement: gcIncrement,
-		BlockLock:   blockLock,
-		BlockIO:     blockIO,
-		BlockPerm:   blockPerm,
-	}
-}
-
-// classifyBlocks 分析并分类当前 goroutine 的阻塞状态
-// 返回: (锁阻塞数, IO阻塞数, 持续≥10秒阻塞数)
-func classifyBlocks() (lock int, io int, perm int) {
-	var buf bytes.Buffer
-	pprof.Lookup("goroutine").WriteTo(&buf, 1)
-	lines := strings.Split(buf.String(), "\n")
-
-	// 匹配 goroutine 状态行: "goroutine 123 [state]:"
-	re := regexp.MustCompile(`goroutine\s+\d+\s+\[(.*?)\]:`)
-	// 匹配持续时间: "123 minute(s)" 或 "123 second(s)"
-	durRe := regexp.MustCompile(`(\d+)\s*(minute|minutes|second|seconds)`)
-
-	for _, line := range lines {
-		m := re.FindStringSubmatch(line)
-		if len(m) < 2 {
-			continue
-		}
-
-		state := strings.ToLower(m[1])
-		var durSec int
-
-		// 解析持续时间
-		if dm := durRe.FindStringSubmatch(state); len(dm) >= 3 {
-			n := dm[1]
-			unit := dm[2]
-			if v, err := strconv.Atoi(n); err == nil {
-				if strings.HasPrefix(unit, "minute") {
-					durSec = v * 60
-				} else {
-					durSec = v
-				}
-			}
-		}
-
-		// 分类阻塞类型
-		if strings.Contains(state, "semacquire") {
-			lock++
-		}
-		if strings.Contains(state, "io wait") || strings.Contains(state, "syscall") {
-			io++
-		}
-		// 持续≥10秒的阻塞
-		if durSec >= 10 {
-			if strings.Contains(state, "semacquire") ||
-				strings.Contains(state, "chan receive") ||
-				strings.Contains(state, "chan send") ||
-				strings.Contains(state, "select") ||
-				strings.Contains(state, "io wait") ||
-				strings.Contains(state, "syscall") {
-				perm++
-			}
-		}
-	}
-
-	return
-}
-
-// classifyBlocksByRoute 按路由标签分类统计阻塞状态
-// 返回: map[route][锁阻塞数, IO阻塞数, 持续≥10秒阻塞数]
-func classifyBlocksByRoute() map[string][3]int {
-	var buf bytes.Buffer
-	pprof.Lookup("goroutine").WriteTo(&buf, 2) // 使用深度2以包含标签信息
-	lines := strings.Split(buf.String(), "\n")
-
-	reHeader := regexp.MustCompile(`goroutine\s+\d+\s+\[(.*?)\]:`)
-	reLabels := regexp.MustCompile(`labels:\s+(.*)`)
-	reRouteKV := regexp.MustCompile(`route=([^,\s]+)`)
-	reDur := regexp.MustCompile(`(\d+)\s*(minute|minutes|second|seconds)`)
-
-	stats := make(map[string][3]int)
-	i := 0
-
-	for i < len(lines) {
-		m := reHeader.FindStringSubmatch(lines[i])
-		if len(m) < 2 {
-			i++
-			continue
-		}
-
-		state := strings.ToLower(m[1])
-		var durSec int
-
-		// 解析持续时间
-		if dm := reDur.FindStringSubmatch(state); len(dm) >= 3 {
-			n := dm[1]
-			unit := dm[2]
-			if v, err := strconv.Atoi(n); err == nil {
-				if strings.HasPrefix(unit, "minute") {
-					durSec = v * 60
-				} else {
-					durSec = v
-				}
-			}
-		}
-
-		// 查找路由标签
-		route := ""
-		j := i + 1
-		for j < len(lines) && !reHeader.MatchString(lines[j]) {
-			if lm := reLabels.FindStringSubmatch(lines[j]); len(lm) >= 2 {
-				if rm := reRouteKV.FindStringSubmatch(lm[1]); len(rm) >= 2 {
-					route = rm[1]
-					break // 找到路由后立即退出
-				}
-			}
-			j++
-		}
-
-		// 如果没有找到路由标签，跳过这个goroutine（不统计到unknown）
-		if route == "" {
-			i = j
-			continue
-		}
-
-		// 统计阻塞类型
-		var a, b, c int
-		if strings.Contains(state, "semacquire") {
-			a = 1
-		}
-		if strings.Contains(state, "io wait") || strings.Contains(state, "syscall") {
-			b = 1
-		}
-		if durSec >= 10 {
-			if strings.Contains(state, "semacquire") ||
-				strings.Contains(state, "chan receive") ||
-				strings.Contains(state, "chan send") ||
-				strings.Contains(state, "select") ||
-				strings.Contains(state, "io wait") ||
-				strings.Contains(state, "syscall") {
-				c = 1
-			}
-		}
+	requests := t.requestsInWindow(t.requestWindow)
+	t.mu.RLock()
+	inRate, outRate := t.traffic.bandwidth(t.clock.Now().Unix(), t.requestWindow)
+	inTotal, outTotal := t.traffic.reqSize.sum, t.traffic.respSize.sum
+	inFlight := t.inFlight
+	t.mu.RUnlock()
 
-		cur := stats[route]
-		stats[route] = [3]int{cur[0] + a, cur[1] + b, cur[2] + c}
-		i = j
+	return Sample{
+		Time:          t.clock.Now().UnixMilli(),
+		Goroutines:    runtime.NumGoroutine(),
+		Requests:      requests,
+		QPS:           float64(requests) / float64(windowSeconds(t.requestWindow)),
+		BytesInRate:   inRate,
+		BytesOutRate:  outRate,
+		BytesInTotal:  inTotal,
+		BytesOutTotal: outTotal,
+		InFlight:      inFlight.current,
+		PeakInFlight:  inFlight.peak,
+		HeapAlloc:     ms.HeapAlloc,
+		HeapInuse:     ms.HeapInuse,
+		HeapSys:       ms.HeapSys,
+		HeapObjects:   ms.HeapObjects,
+		NumGC:         currentNumGC,
+		GCIncrement:   gcIncrement,
+		BlockLock:     blockLock,
+		BlockIO:       blockIO,
+		BlockPerm:     blockPerm,
 	}
-
-	return stats
 }
 
 // RouteStat 路由统计信息
 type RouteStat struct {
-	Route       string  `json:"route"`       // 路由路径
-	Requests    int     `json:"requests"`    // 最近10秒的请求数
-	MemoryUsage float64 `json:"memoryUsage"` // 内存消耗（MB），请求数 × 平均每个请求的内存
-	CPUUsage    float64 `json:"cpuUsage"`    // CPU消耗（ms），当前窗口内的CPU时间
-	BlockLock   int     `json:"blockLock"`   // 锁阻塞数
-	BlockIO     int     `json:"blockIO"`     // IO 阻塞数
-	BlockPerm   int     `json:"blockPerm"`   // 持续≥10秒阻塞数
+	Route         string  `json:"route"`         // 路由路径
+	Requests      int     `json:"requests"`      // 最近一个请求窗口的请求数
+	QPS           float64 `json:"qps"`           // 最近一个请求窗口的平均每秒请求数
+	BytesInRate   float64 `json:"bytesInRate"`   // 最近一个请求窗口的请求体带宽（字节/秒）
+	BytesOutRate  float64 `json:"bytesOutRate"`  // 最近一个请求窗口的响应体带宽（字节/秒）
+	BytesInTotal  uint64  `json:"bytesInTotal"`  // 累计请求体字节数
+	BytesOutTotal uint64  `json:"bytesOutTotal"` // 累计响应体字节数
+	InFlight      int     `json:"inFlight"`      // 正在处理的请求数
+	PeakInFlight  int     `json:"peakInFlight"`  // 本采样周期内的最大并发请求数
+	MemoryUsage   float64 `json:"memoryUsage"`   // 内存消耗（MB），请求数 × 平均每个请求的内存
+	CPUUsage      float64 `json:"cpuUsage"`      // CPU消耗（ms），当前窗口内的CPU时间
+	BlockLock     int     `json:"blockLock"`     // 锁阻塞数
+	BlockIO       int     `json:"blockIO"`       // IO 阻塞数
+	BlockPerm     int     `json:"blockPerm"`     // 持续≥10秒阻塞数
+	// BlocksUnavailable 栈转储不带 pprof 标签（见 ErrLabelsUnavailable），阻塞分类无法按路由统计
+	BlocksUnavailable bool `json:"blocksUnavailable,omitempty"`
 }
 
 // RouteStats 获取按路由统计的指标
 func (t *Tracker) RouteStats() []RouteStat {
-	reqs := t.requestsInWindowByRoute(requestWindowDuration)
-	blocks := classifyBlocksByRoute()
+	if t.readOnly {
+		return slices.Clone(t.frozenRoutes)
+	}
+	reqs := t.requestsInWindowByRoute(t.requestWindow)
+	blocks, blocksErr := BlocksByLabel("route")
 
 	t.mu.RLock()
 	routeMem := make(map[string]uint64)
@@ -365,17 +502,39 @@ func (t *Tracker) RouteStats() []RouteStat {
 	for r, cpu := range t.routeCPUTime {
 		routeCPU[r] = cpu
 	}
+	// 带宽和累计字节数
+	now := t.clock.Now().Unix()
+	traffic := make(map[string]RouteStat, len(t.trafficByRoute))
+	for r, rt := range t.trafficByRoute {
+		in, out := rt.bandwidth(now, t.requestWindow)
+		traffic[r] = RouteStat{
+			BytesInRate:   in,
+			BytesOutRate:  out,
+			BytesInTotal:  rt.reqSize.sum,
+			BytesOutTotal: rt.respSize.sum,
+		}
+	}
+	inFlight := make(map[string]concurrency, len(t.inFlightByRoute))
+	for r, c := range t.inFlightByRoute {
+		inFlight[r] = *c
+	}
 	t.mu.RUnlock()
 
-	// 收集所有路由（排除空路由和unknown）
+	// 收集所有路由（排除空路由）
 	routes := make(map[string]struct{})
 	for r := range reqs {
-		if r != "" && r != "(unknown)" {
+		if r != "" {
+			routes[r] = struct{}{}
+		}
+	}
+	// 请求到达早于统计窗口但仍在处理中的路由
+	for r, c := range inFlight {
+		if r != "" && c.current > 0 {
 			routes[r] = struct{}{}
 		}
 	}
 	for r := range blocks {
-		if r != "" && r != "(unknown)" {
+		if r != "" {
 			routes[r] = struct{}{}
 		}
 	}
@@ -411,14 +570,24 @@ func (t *Tracker) RouteStats() []RouteStat {
 			cpuUsage = avgCPUTimePerRequest * float64(requestCount)
 		}
 
+		tr := traffic[r]
 		out = append(out, RouteStat{
-			Route:       r,
-			Requests:    requestCount,
-			MemoryUsage: memoryUsage,
-			CPUUsage:    cpuUsage,
-			BlockLock:   b[0],
-			BlockIO:     b[1],
-			BlockPerm:   b[2],
+			Route:         r,
+			Requests:      requestCount,
+			QPS:           float64(requestCount) / float64(windowSeconds(t.requestWindow)),
+			BytesInRate:   tr.BytesInRate,
+			BytesOutRate:  tr.BytesOutRate,
+			BytesInTotal:  tr.BytesInTotal,
+			BytesOutTotal: tr.BytesOutTotal,
+			InFlight:      inFlight[r].current,
+			PeakInFlight:  inFlight[r].peak,
+			MemoryUsage:   memoryUsage,
+			CPUUsage:      cpuUsage,
+			BlockLock:     b.BlockLock,
+			BlockIO:       b.BlockIO,
+			BlockPerm:     b.BlockPerm,
+
+			BlocksUnavailable: blocksErr != nil,
 		})
 	}
 
@@ -427,13 +596,32 @@ func (t *Tracker) RouteStats() []RouteStat {
 
 // PushSample 将当前样本推入历史，最多保留 maxHistory 个点
 func (t *Tracker) PushSample() {
+	if t.readOnly {
+		return
+	}
+	t.pushMu.Lock()
+	defer t.pushMu.Unlock()
+
+	t.pruneRoutes()
 	s := t.CurrentSample()
+	if t.anomalies != nil {
+		s.Anomalies = t.anomalies.observe(s)
+	}
 	t.histMu.Lock()
 	t.history = append(t.history, s)
-	if len(t.history) > maxHistory {
-		t.history = t.history[len(t.history)-maxHistory:]
+	if len(t.history) > t.maxHistory {
+		t.history = t.history[len(t.history)-t.maxHistory:]
 	}
+	t.lastNumGC = s.NumGC
 	t.histMu.Unlock()
+
+	t.mu.Lock()
+	t.resetPeaks()
+	t.mu.Unlock()
+
+	if t.sampler.OnSample != nil {
+		t.sampler.OnSample(s)
+	}
 }
 
 // History 返回完整历史数据的拷贝
@@ -446,15 +634,44 @@ func (t *Tracker) History() []Sample {
 	return h
 }
 
+// SamplesSince 返回时间戳晚于 ts（毫秒）的历史样本
+func (t *Tracker) SamplesSince(ts int64) []Sample {
+	t.histMu.RLock()
+	defer t.histMu.RUnlock()
+
+	// 历史按时间递增，从后往前找到第一个不晚于 ts 的位置
+	idx := len(t.history)
+	for idx > 0 && t.history[idx-1].Time > ts {
+		idx--
+	}
+
+	out := make([]Sample, len(t.history)-idx)
+	copy(out, t.history[idx:])
+	return out
+}
+
+// WindowEnd 返回 HistoryWindow 窗口的终点（毫秒）：追踪器时钟的当前时间，只读追踪器为最后一个样本的时间
+func (t *Tracker) WindowEnd() int64 {
+	t.histMu.RLock()
+	defer t.histMu.RUnlock()
+	return t.windowEndLocked()
+}
+
+// windowEndLocked 同 WindowEnd，调用方须持有 histMu
+func (t *Tracker) windowEndLocked() int64 {
+	if t.readOnly && len(t.history) > 0 {
+		return t.history[len(t.history)-1].Time
+	}
+	return t.clock.Now().UnixMilli()
+}
+
 // HistoryWindow 返回指定时间窗口内的历史数据（秒）
-// 如果 seconds <= 0，默认返回最近10分钟的数据
+// 如果 seconds <= 0，默认返回最近10分钟的数据；只读追踪器的窗口以最后一个样本为终点
 func (t *Tracker) HistoryWindow(seconds int) []Sample {
 	if seconds <= 0 {
 		seconds = 600 // 默认10分钟
 	}
 
-	cutoff := time.Now().Add(-time.Duration(seconds) * time.Second).UnixMilli()
-
 	t.histMu.RLock()
 	defer t.histMu.RUnlock()
 
@@ -462,6 +679,7 @@ func (t *Tracker) HistoryWindow(seconds int) []Sample {
 	if len(h) == 0 {
 		return []Sample{}
 	}
+	cutoff := t.windowEndLocked() - int64(seconds)*1000
 
 	// 从后往前查找第一个未过期的时间戳
 	idx := 0
@@ -0,0 +1,285 @@
+package metrics
+
+import (
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const (
+	minWSIntervalMs = 200   // 推送间隔下限
+	maxWSIntervalMs = 60000 // 推送间隔上限
+)
+
+// wsCommand 客户端通过 WebSocket 发送的命令
+//
+//	{"action":"subscribe","topics":["sample","routes","anomalies"],"intervalMs":1000}
+//	{"action":"history","window":600,"annotations":true}
+//	{"action":"profile","profile":"heap","seconds":5}
+type wsCommand struct {
+	Action     string   `json:"action"`     // subscribe / history / profile
+	Topics     []string `json:"topics"`     // 订阅主题：sample、routes、anomalies、annotations
+	IntervalMs int      `json:"intervalMs"` // 定时推送间隔（毫秒）
+	Window     int      `json:"window"`     // 历史回填窗口（秒）
+	// Annotations 历史回填时一并返回窗口内的注释，data 为 {"samples", "annotations"}
+	Annotations bool   `json:"annotations"`
+	Profile     string `json:"profile"` // 性能数据类型：cpu/heap/goroutine/...
+	Seconds     int    `json:"seconds"` // CPU 采样时长（秒）
+}
+
+// wsMessage 服务端推送给客户端的消息
+type wsMessage struct {
+	Type  string      `json:"type"` // sample / routes / anomaly / annotation / history / profile / subscribed / shutdown / error
+	Data  interface{} `json:"data,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// wsProfile 性能数据抓取结果，内容为 base64 编码的 pprof 二进制
+type wsProfile struct {
+	Profile string `json:"profile"`
+	Size    int    `json:"size"`
+	Content string `json:"content"`
+}
+
+// wsSubscription 当前连接的订阅状态
+type wsSubscription struct {
+	Topics      []string `json:"topics"`
+	IntervalMs  int      `json:"intervalMs"`
+	sample      bool
+	routes      bool
+	anomalies   bool
+	annotations bool
+}
+
+// newWSSubscription 根据主题和间隔构造订阅状态，非法取值回落到默认值
+func newWSSubscription(topics []string, intervalMs int, defaultInterval time.Duration) wsSubscription {
+	if intervalMs <= 0 {
+		intervalMs = int(defaultInterval / time.Millisecond)
+	}
+	if intervalMs < minWSIntervalMs {
+		intervalMs = minWSIntervalMs
+	}
+	if intervalMs > maxWSIntervalMs {
+		intervalMs = maxWSIntervalMs
+	}
+
+	sub := wsSubscription{IntervalMs: intervalMs, Topics: []string{}}
+	for _, t := range topics {
+		switch t {
+		case "sample":
+			if !sub.sample {
+				sub.sample = true
+				sub.Topics = append(sub.Topics, t)
+			}
+		case "routes":
+			if !sub.routes {
+				sub.routes = true
+				sub.Topics = append(sub.Topics, t)
+			}
+		case "anomalies":
+			if !sub.anomalies {
+				sub.anomalies = true
+				sub.Topics = append(sub.Topics, t)
+			}
+		case "annotations":
+			if !sub.annotations {
+				sub.annotations = true
+				sub.Topics = append(sub.Topics, t)
+			}
+		}
+	}
+	return sub
+}
+
+// ServeWS WebSocket 双向推送实时指标
+// 与 SSE 共享 Hub 的广播通知，同时接受客户端命令
+func (a *API) ServeWS(w http.ResponseWriter, r *http.Request) {
+	src, err := a.source(r)
+	if err != nil {
+		writeError(w, r, http.StatusNotFound, err)
+		return
+	}
+
+	server := websocket.Server{
+		Handshake: a.checkWSOrigin,
+		Handler: func(ws *websocket.Conn) {
+			a.serveWS(ws, src)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// checkWSOrigin 拒绝跨站页面发起的握手，防止跨站 WebSocket 劫持
+// 不带 Origin 的非浏览器客户端和同源页面总是允许，跨站来源须在 APIOptions.WSOrigins 中显式列出
+func (a *API) checkWSOrigin(cfg *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(cfg, r)
+	if err != nil {
+		return err
+	}
+	if origin == nil {
+		return nil
+	}
+	cfg.Origin = origin
+	if origin.Host == r.Host || slices.Contains(a.opts.WSOrigins, origin.Scheme+"://"+origin.Host) {
+		return nil
+	}
+	slog.WarnContext(r.Context(), "WebSocket origin rejected", "origin", origin.String())
+	return errors.New("origin not allowed")
+}
+
+// serveWS 处理单个 WebSocket 连接
+func (a *API) serveWS(ws *websocket.Conn, src Source) {
+	defer ws.Close()
+
+	// 读协程：解析客户端命令
+	cmds := make(chan wsCommand)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			var cmd wsCommand
+			if err := websocket.JSON.Receive(ws, &cmd); err != nil {
+				return
+			}
+			select {
+			case cmds <- cmd:
+			case <-ws.Request().Context().Done():
+				return
+			}
+		}
+	}()
+
+	// 订阅通知通道
+	ch := a.hub.Subscribe()
+	defer a.hub.Unsubscribe(ch)
+
+	// 默认与 SSE 一致，只推送样本
+	sub := newWSSubscription([]string{"sample"}, 0, a.opts.Interval)
+	ticker := time.NewTicker(time.Duration(sub.IntervalMs) * time.Millisecond)
+	defer ticker.Stop()
+
+	// 订阅 anomalies、annotations 后推送连接建立之后产生的异常事件和注释
+	as, _ := src.(anomalySource)
+	var anomalySeq uint64
+	if as != nil {
+		anomalySeq = lastAnomalySeq(as)
+	}
+	annotationID := a.opts.Annotations.LastID()
+
+	// 异步命令（如 CPU 采样）的结果，避免阻塞推送
+	results := make(chan wsMessage)
+
+	send := func(msg wsMessage) bool {
+		if err := websocket.JSON.Send(ws, msg); err != nil {
+			slog.Debug("Failed to send websocket message", "error", err)
+			return false
+		}
+		return true
+	}
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ws.Request().Context().Done():
+			return
+		case <-a.hub.Done():
+			// 服务关闭：WebSocket 连接已被劫持，不受 http.Server.Shutdown 管理，需要主动断开
+			send(wsMessage{Type: "shutdown", Data: map[string]int{"retryMs": reconnectDelayMs}})
+			return
+		case <-ticker.C:
+			if sub.sample && !send(wsMessage{Type: "sample", Data: src.CurrentSample()}) {
+				return
+			}
+			if sub.routes && !send(wsMessage{Type: "routes", Data: src.RouteStats()}) {
+				return
+			}
+			if sub.anomalies && as != nil {
+				for _, an := range as.Anomalies(anomalySeq) {
+					if !send(wsMessage{Type: "anomaly", Data: an}) {
+						return
+					}
+					anomalySeq = an.Seq
+				}
+			}
+			if sub.annotations {
+				for _, ann := range a.opts.Annotations.Since(annotationID) {
+					if !send(wsMessage{Type: "annotation", Data: ann}) {
+						return
+					}
+					annotationID = max(annotationID, ann.ID)
+				}
+			}
+		case <-ch:
+			// 有新请求时立即推送
+			if sub.sample && !send(wsMessage{Type: "sample", Data: src.CurrentSample()}) {
+				return
+			}
+		case msg := <-results:
+			if !send(msg) {
+				return
+			}
+		case cmd := <-cmds:
+			var msg wsMessage
+			switch cmd.Action {
+			case "subscribe":
+				sub = newWSSubscription(cmd.Topics, cmd.IntervalMs, a.opts.Interval)
+				ticker.Reset(time.Duration(sub.IntervalMs) * time.Millisecond)
+				msg = wsMessage{Type: "subscribed", Data: sub}
+			case "history":
+				windowSec := cmd.Window
+				if windowSec <= 0 {
+					windowSec = a.opts.DefaultWindowSec
+				}
+				if windowSec > a.opts.MaxWindowSec {
+					windowSec = a.opts.MaxWindowSec
+				}
+				if cmd.Annotations && a.opts.Annotations != nil {
+					msg = wsMessage{Type: "history", Data: a.historyWithAnnotations(src, windowSec, nil)}
+				} else {
+					msg = wsMessage{Type: "history", Data: src.HistoryWindow(windowSec)}
+				}
+			case "profile":
+				if a.opts.CanProfile != nil && !a.opts.CanProfile(ws.Request()) {
+					msg = wsMessage{Type: "error", Error: errProfileForbidden.Error()}
+					break
+				}
+				go func(cmd wsCommand) {
+					select {
+					case results <- a.captureWSProfile(cmd):
+					case <-done:
+					}
+				}(cmd)
+				continue
+			default:
+				msg = wsMessage{Type: "error", Error: "unknown action: " + cmd.Action}
+			}
+			if !send(msg) {
+				return
+			}
+		}
+	}
+}
+
+// captureWSProfile 执行性能数据抓取命令，成功时记录注释
+func (a *API) captureWSProfile(cmd wsCommand) wsMessage {
+	kind := cmd.Profile
+	if kind == "" {
+		kind = "heap"
+	}
+	data, err := CaptureProfile(kind, cmd.Seconds)
+	if err != nil {
+		return wsMessage{Type: "error", Error: err.Error()}
+	}
+	a.opts.Annotations.Record("Profile captured: "+kind, TagProfile)
+	return wsMessage{Type: "profile", Data: wsProfile{
+		Profile: kind,
+		Size:    len(data),
+		Content: base64.StdEncoding.EncodeToString(data),
+	}}
+}
@@ -0,0 +1,227 @@
+package metrics
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// wsTestServer 启动挂载 ServeWS 的测试服务
+func wsTestServer(t *testing.T, opts APIOptions) (*API, *httptest.Server) {
+	t.Helper()
+	api := NewAPI(NewTracker(TrackerConfig{}), NewHub(), opts)
+	srv := httptest.NewServer(http.HandlerFunc(api.ServeWS))
+	t.Cleanup(srv.Close)
+	return api, srv
+}
+
+// dialWS 以 origin 发起握手
+func dialWS(t *testing.T, srv *httptest.Server, origin string) *websocket.Conn {
+	t.Helper()
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), "", origin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+// wsReply 解码后的服务端消息，data 保留原始 JSON
+type wsReply struct {
+	Type  string          `json:"type"`
+	Data  json.RawMessage `json:"data"`
+	Error string          `json:"error"`
+}
+
+// recvWS 读取下一条 typ 类型的消息，跳过其他类型（如定时推送的样本）
+func recvWS(t *testing.T, ws *websocket.Conn, typ string) wsReply {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var msg wsReply
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			t.Fatalf("waiting for %q: %v", typ, err)
+		}
+		if msg.Type == typ {
+			return msg
+		}
+	}
+}
+
+func sendWS(t *testing.T, ws *websocket.Conn, cmd wsCommand) {
+	t.Helper()
+	if err := websocket.JSON.Send(ws, cmd); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewWSSubscription(t *testing.T) {
+	tests := []struct {
+		topics     []string
+		intervalMs int
+		wantTopics []string
+		wantMs     int
+	}{
+		{nil, 0, []string{}, 1000},
+		{[]string{"sample", "sample", "bogus", "routes"}, 500, []string{"sample", "routes"}, 500},
+		{[]string{"annotations", "anomalies"}, 1, []string{"annotations", "anomalies"}, minWSIntervalMs},
+		{[]string{"routes"}, 10 * maxWSIntervalMs, []string{"routes"}, maxWSIntervalMs},
+	}
+	for _, tt := range tests {
+		sub := newWSSubscription(tt.topics, tt.intervalMs, time.Second)
+		if !slices.Equal(sub.Topics, tt.wantTopics) || sub.IntervalMs != tt.wantMs {
+			t.Errorf("newWSSubscription(%v, %d) = %v / %dms, want %v / %dms", tt.topics, tt.intervalMs, sub.Topics, sub.IntervalMs, tt.wantTopics, tt.wantMs)
+		}
+	}
+}
+
+func TestWSOrigin(t *testing.T) {
+	_, srv := wsTestServer(t, APIOptions{WSOrigins: []string{"http://dashboard.example"}})
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	for _, origin := range []string{srv.URL, "http://dashboard.example"} {
+		ws, err := websocket.Dial(url, "", origin)
+		if err != nil {
+			t.Errorf("origin %s rejected: %v", origin, err)
+			continue
+		}
+		ws.Close()
+	}
+	for _, origin := range []string{"http://evil.example", "https://dashboard.example"} {
+		if ws, err := websocket.Dial(url, "", origin); err == nil {
+			ws.Close()
+			t.Errorf("origin %s accepted", origin)
+		}
+	}
+
+	// 不带 Origin 的非浏览器客户端
+	api := NewAPI(NewTracker(TrackerConfig{}), NewHub(), APIOptions{})
+	req := httptest.NewRequest(http.MethodGet, "/metrics/ws", nil)
+	if err := api.checkWSOrigin(&websocket.Config{Version: websocket.ProtocolVersionHybi13}, req); err != nil {
+		t.Errorf("request without Origin rejected: %v", err)
+	}
+}
+
+func TestWSSubscribe(t *testing.T) {
+	_, srv := wsTestServer(t, APIOptions{Interval: time.Second})
+	ws := dialWS(t, srv, srv.URL)
+
+	sendWS(t, ws, wsCommand{Action: "subscribe", Topics: []string{"routes", "sample"}, IntervalMs: minWSIntervalMs})
+	var sub wsSubscription
+	if err := json.Unmarshal(recvWS(t, ws, "subscribed").Data, &sub); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(sub.Topics, []string{"routes", "sample"}) || sub.IntervalMs != minWSIntervalMs {
+		t.Fatalf("subscribed = %+v", sub)
+	}
+	recvWS(t, ws, "sample")
+	recvWS(t, ws, "routes")
+
+	// 空主题即取消订阅，此后不再有定时推送
+	sendWS(t, ws, wsCommand{Action: "subscribe", Topics: []string{}, IntervalMs: minWSIntervalMs})
+	recvWS(t, ws, "subscribed")
+	ws.SetReadDeadline(time.Now().Add(3 * minWSIntervalMs * time.Millisecond))
+	var msg wsReply
+	err := websocket.JSON.Receive(ws, &msg)
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		t.Errorf("after unsubscribe got %+v (err %v), want read timeout", msg, err)
+	}
+}
+
+func TestWSUnknownAction(t *testing.T) {
+	_, srv := wsTestServer(t, APIOptions{})
+	ws := dialWS(t, srv, srv.URL)
+	sendWS(t, ws, wsCommand{Action: "reboot"})
+	if got := recvWS(t, ws, "error").Error; got != "unknown action: reboot" {
+		t.Errorf("error = %q", got)
+	}
+}
+
+func TestWSProfilePermission(t *testing.T) {
+	for _, allowed := range []bool{false, true} {
+		annotations := NewAnnotationLog(0)
+		_, srv := wsTestServer(t, APIOptions{
+			Annotations: annotations,
+			CanProfile:  func(*http.Request) bool { return allowed },
+		})
+		ws := dialWS(t, srv, srv.URL)
+		sendWS(t, ws, wsCommand{Action: "profile", Profile: "heap"})
+
+		if !allowed {
+			if got := recvWS(t, ws, "error").Error; got != errProfileForbidden.Error() {
+				t.Errorf("forbidden: error = %q", got)
+			}
+			if n := annotations.LastID(); n != 0 {
+				t.Errorf("forbidden profile recorded %d annotations", n)
+			}
+			continue
+		}
+		var p wsProfile
+		if err := json.Unmarshal(recvWS(t, ws, "profile").Data, &p); err != nil {
+			t.Fatal(err)
+		}
+		data, err := base64.StdEncoding.DecodeString(p.Content)
+		if p.Profile != "heap" || err != nil || len(data) == 0 || len(data) != p.Size {
+			t.Errorf("profile = %s, %d bytes (size %d, err %v)", p.Profile, len(data), p.Size, err)
+		}
+		if got := annotations.Since(0); len(got) != 1 || !slices.Contains(got[0].Tags, TagProfile) {
+			t.Errorf("annotations = %+v, want one profile annotation", got)
+		}
+	}
+}
+
+func TestWSAnnotationCatchUp(t *testing.T) {
+	annotations := NewAnnotationLog(0)
+	annotations.Add(Annotation{Title: "before connect"})
+	_, srv := wsTestServer(t, APIOptions{Annotations: annotations})
+	ws := dialWS(t, srv, srv.URL)
+
+	sendWS(t, ws, wsCommand{Action: "subscribe", Topics: []string{"annotations"}, IntervalMs: minWSIntervalMs})
+	recvWS(t, ws, "subscribed")
+	annotations.Add(Annotation{Title: "first"})
+	annotations.Add(Annotation{Title: "second"})
+
+	// 只推送连接建立之后的注释，按 ID 顺序且各一次
+	for _, want := range []string{"first", "second"} {
+		var ann Annotation
+		if err := json.Unmarshal(recvWS(t, ws, "annotation").Data, &ann); err != nil {
+			t.Fatal(err)
+		}
+		if ann.Title != want {
+			t.Fatalf("annotation = %q, want %q", ann.Title, want)
+		}
+	}
+	annotations.Add(Annotation{Title: "third"})
+	var ann Annotation
+	if err := json.Unmarshal(recvWS(t, ws, "annotation").Data, &ann); err != nil {
+		t.Fatal(err)
+	}
+	if ann.Title != "third" {
+		t.Errorf("annotation after catch-up = %q, want third", ann.Title)
+	}
+}
+
+func TestWSShutdown(t *testing.T) {
+	api, srv := wsTestServer(t, APIOptions{})
+	ws := dialWS(t, srv, srv.URL)
+	sendWS(t, ws, wsCommand{Action: "subscribe", Topics: []string{}})
+	recvWS(t, ws, "subscribed")
+	api.hub.Close()
+	var data map[string]int
+	if err := json.Unmarshal(recvWS(t, ws, "shutdown").Data, &data); err != nil {
+		t.Fatal(err)
+	}
+	if data["retryMs"] != reconnectDelayMs {
+		t.Errorf("shutdown data = %v", data)
+	}
+}
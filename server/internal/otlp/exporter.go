@@ -0,0 +1,415 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"analyseGo/internal/metrics"
+	"analyseGo/internal/reqid"
+)
+
+const (
+	// scopeName 导出数据的 instrumentation scope 名称
+	scopeName = "analyseGo/internal/otlp"
+	// defaultExportInterval 默认导出间隔
+	defaultExportInterval = 5 * time.Second
+	// maxQueuedSpans 等待导出的 span 上限，超出后丢弃
+	maxQueuedSpans = 2048
+	// exportTimeout 单次导出的超时时间
+	exportTimeout = 10 * time.Second
+)
+
+// durationBounds 请求耗时直方图的桶边界（秒），与 HTTP 语义约定推荐值一致
+var durationBounds = []float64{0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10}
+
+// Config OTLP 导出配置
+type Config struct {
+	Endpoint    string            // OTLP/HTTP 基础地址，例如 http://localhost:4318
+	ServiceName string            // 资源属性 service.name
+	Interval    time.Duration     // 导出间隔，默认5秒
+	Headers     map[string]string // 额外请求头（如鉴权）
+}
+
+// routeAgg 单个路由的累计指标
+type routeAgg struct {
+	count      int64
+	errors     int64
+	allocBytes int64
+	durSum     float64 // 秒
+	buckets    []int64 // len(durationBounds)+1
+}
+
+// Exporter 周期性地以 OTLP/HTTP JSON 导出样本指标、路由指标和请求 span
+// 同时实现 metrics.RequestHook，注册到 Tracker 后为每个请求创建服务端 span
+type Exporter struct {
+	cfg     Config
+	tracker *metrics.Tracker
+	client  *http.Client
+	start   time.Time // 累计指标的起始时间
+
+	mu           sync.Mutex
+	routes       map[string]*routeAgg
+	spans        []span
+	droppedSpans int
+	lastSample   int64 // 最后一次导出的样本时间戳（毫秒），仅在 Run 协程内访问
+}
+
+// NewExporter 创建新的 OTLP 导出器
+func NewExporter(cfg Config, tracker *metrics.Tracker) *Exporter {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultExportInterval
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "analyseGo"
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	return &Exporter{
+		cfg:     cfg,
+		tracker: tracker,
+		client:  &http.Client{Timeout: exportTimeout},
+		start:   time.Now(),
+		routes:  make(map[string]*routeAgg),
+	}
+}
+
+// StartRequest 从 traceparent 头继承追踪上下文并创建服务端 span
+// 追踪 ID 同时通过 reqid 存入 ctx，请求日志和访问日志据此关联 span
+func (e *Exporter) StartRequest(ctx context.Context, r *http.Request, route string) context.Context {
+	as := &activeSpan{sc: SpanContext{SpanID: newSpanID(), Sampled: true}}
+	if parent, ok := ParseTraceparent(r.Header.Get(traceparentHeader)); ok {
+		as.sc.TraceID = parent.TraceID
+		as.sc.Sampled = parent.Sampled
+		as.parent = parent.SpanID
+		as.hasParent = true
+	} else {
+		as.sc.TraceID = newTraceID()
+	}
+	ctx = reqid.WithTraceID(ctx, as.sc.TraceIDHex())
+	return context.WithValue(ctx, spanContextKey{}, as)
+}
+
+// EndRequest 结束 span 并累计路由指标
+func (e *Exporter) EndRequest(ctx context.Context, info metrics.RequestInfo) {
+	sec := info.Duration.Seconds()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	agg, ok := e.routes[info.Route]
+	if !ok {
+		agg = &routeAgg{buckets: make([]int64, len(durationBounds)+1)}
+		e.routes[info.Route] = agg
+	}
+	agg.count++
+	if info.Status >= 500 {
+		agg.errors++
+	}
+	agg.allocBytes += int64(info.AllocBytes)
+	agg.durSum += sec
+	agg.buckets[sort.SearchFloat64s(durationBounds, sec)]++
+
+	as, ok := ctx.Value(spanContextKey{}).(*activeSpan)
+	if !ok || !as.sc.Sampled {
+		return
+	}
+	if len(e.spans) >= maxQueuedSpans {
+		e.droppedSpans++
+		return
+	}
+
+	s := span{
+		TraceID:           as.sc.TraceIDHex(),
+		SpanID:            as.sc.SpanIDHex(),
+		Name:              info.Method + " " + info.Route,
+		Kind:              spanKindServer,
+		StartTimeUnixNano: nanos(info.Start.UnixNano()),
+		EndTimeUnixNano:   nanos(info.Start.Add(info.Duration).UnixNano()),
+		Attributes: []keyValue{
+			strAttr("http.request.method", info.Method),
+			strAttr("http.route", info.Route),
+			strAttr("url.path", info.Path),
+			intAttr("http.response.status_code", int64(info.Status)),
+			intAttr("analysego.alloc_bytes", int64(info.AllocBytes)),
+		},
+		Status: spanStatus{Code: statusCodeUnset},
+	}
+	if as.hasParent {
+		s.ParentSpanID = hex.EncodeToString(as.parent[:])
+	}
+	if info.Status >= 500 {
+		s.Status.Code = statusCodeError
+	}
+	e.spans = append(e.spans, s)
+}
+
+// Run 按间隔导出，直到 ctx 结束；结束时做最后一次导出
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+			e.Export(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+			e.Export(ctx)
+		}
+	}
+}
+
+// Export 立即导出一次指标和 span
+func (e *Exporter) Export(ctx context.Context) {
+	if err := e.exportMetrics(ctx); err != nil {
+		slog.Warn("Failed to export OTLP metrics", "error", err)
+	}
+	if err := e.exportTraces(ctx); err != nil {
+		slog.Warn("Failed to export OTLP traces", "error", err)
+	}
+}
+
+// exportMetrics 导出新增样本（gauge）和路由累计指标（sum/histogram）
+func (e *Exporter) exportMetrics(ctx context.Context) error {
+	now := nanos(time.Now().UnixNano())
+	startNs := nanos(e.start.UnixNano())
+
+	var ms []metric
+	samples := e.tracker.SamplesSince(e.lastSample)
+	if len(samples) > 0 {
+		ms = append(ms, sampleMetrics(samples, startNs)...)
+	}
+
+	e.mu.Lock()
+	routes := make([]string, 0, len(e.routes))
+	for r := range e.routes {
+		routes = append(routes, r)
+	}
+	sort.Strings(routes)
+
+	count := &sum{AggregationTemporality: aggregationCumulative, IsMonotonic: true}
+	errs := &sum{AggregationTemporality: aggregationCumulative, IsMonotonic: true}
+	alloc := &sum{AggregationTemporality: aggregationCumulative, IsMonotonic: true}
+	dur := &histogram{AggregationTemporality: aggregationCumulative}
+	for _, r := range routes {
+		agg := e.routes[r]
+		attr := strAttr("http.route", r)
+		count.DataPoints = append(count.DataPoints, withStart(intPoint(now, agg.count, attr), startNs))
+		errs.DataPoints = append(errs.DataPoints, withStart(intPoint(now, agg.errors, attr), startNs))
+		alloc.DataPoints = append(alloc.DataPoints, withStart(intPoint(now, agg.allocBytes, attr), startNs))
+
+		buckets := make([]string, len(agg.buckets))
+		for i, b := range agg.buckets {
+			buckets[i] = strconv.FormatInt(b, 10)
+		}
+		dur.DataPoints = append(dur.DataPoints, histogramDataPoint{
+			Attributes:        []keyValue{attr},
+			StartTimeUnixNano: startNs,
+			TimeUnixNano:      now,
+			Count:             strconv.FormatInt(agg.count, 10),
+			Sum:               agg.durSum,
+			BucketCounts:      buckets,
+			ExplicitBounds:    durationBounds,
+		})
+	}
+	e.mu.Unlock()
+
+	if len(routes) > 0 {
+		ms = append(ms,
+			metric{Name: "http.server.request.count", Unit: "{request}", Sum: count},
+			metric{Name: "http.server.request.errors", Unit: "{request}", Sum: errs},
+			metric{Name: "http.server.request.alloc", Unit: "By", Sum: alloc},
+			metric{Name: "http.server.request.duration", Unit: "s", Histogram: dur},
+		)
+	}
+	if len(ms) == 0 {
+		return nil
+	}
+
+	req := metricsRequest{ResourceMetrics: []resourceMetrics{{
+		Resource:     e.resource(),
+		ScopeMetrics: []scopeMetrics{{Scope: scope{Name: scopeName}, Metrics: ms}},
+	}}}
+	if err := e.post(ctx, "/v1/metrics", req); err != nil {
+		return err
+	}
+	if len(samples) > 0 {
+		e.lastSample = samples[len(samples)-1].Time
+	}
+	return nil
+}
+
+// exportTraces 导出已结束的 span，失败时丢弃本批
+func (e *Exporter) exportTraces(ctx context.Context) error {
+	e.mu.Lock()
+	spans := e.spans
+	dropped := e.droppedSpans
+	e.spans = nil
+	e.droppedSpans = 0
+	e.mu.Unlock()
+
+	if dropped > 0 {
+		slog.Warn("Dropped OTLP spans: queue full", "dropped", dropped)
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	req := tracesRequest{ResourceSpans: []resourceSpans{{
+		Resource:   e.resource(),
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: scopeName}, Spans: spans}},
+	}}}
+	return e.post(ctx, "/v1/traces", req)
+}
+
+// resource 返回资源属性
+func (e *Exporter) resource() resource {
+	return resource{Attributes: []keyValue{
+		strAttr("service.name", e.cfg.ServiceName),
+		strAttr("telemetry.sdk.language", "go"),
+	}}
+}
+
+// post 以 JSON 编码发送 OTLP 请求
+func (e *Exporter) post(ctx context.Context, path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", path, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.cfg.Endpoint+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s responded %s", path, resp.Status)
+	}
+	return nil
+}
+
+// sampleMetricName 样本字段对应的 OTLP 指标名、单位和类型
+type sampleMetricName struct {
+	name string
+	unit string
+	sum  bool // 单调累计值使用 sum，其余使用 gauge
+}
+
+// sampleMetricNames 按 Sample 的 JSON 字段名给出 OTLP 指标名，
+// 未列出的数值字段以 analysego.sample.<字段名> 作为 gauge 导出，新增字段无需修改这里
+var sampleMetricNames = map[string]sampleMetricName{
+	"goroutines":    {"go.goroutines", "{goroutine}", false},
+	"requests":      {"http.server.requests.window", "{request}", false},
+	"qps":           {"http.server.requests.rate", "{request}/s", false},
+	"bytesInRate":   {"http.server.request.body.rate", "By/s", false},
+	"bytesOutRate":  {"http.server.response.body.rate", "By/s", false},
+	"bytesInTotal":  {"http.server.request.body.size", "By", true},
+	"bytesOutTotal": {"http.server.response.body.size", "By", true},
+	"inFlight":      {"http.server.active_requests", "{request}", false},
+	"peakInFlight":  {"http.server.active_requests.peak", "{request}", false},
+	"heapAlloc":     {"go.memory.heap.alloc", "By", false},
+	"heapInuse":     {"go.memory.heap.inuse", "By", false},
+	"heapSys":       {"go.memory.heap.sys", "By", false},
+	"heapObjects":   {"go.memory.heap.objects", "{object}", false},
+	"numGC":         {"go.gc.count", "{gc}", true},
+	"gcIncrement":   {"go.gc.increment", "{gc}", false},
+	"blockLock":     {"go.goroutines.blocked.lock", "{goroutine}", false},
+	"blockIO":       {"go.goroutines.blocked.io", "{goroutine}", false},
+	"blockPerm":     {"go.goroutines.blocked.long", "{goroutine}", false},
+}
+
+// sampleField 参与导出的 Sample 数值字段
+type sampleField struct {
+	sampleMetricName
+	index int
+	float bool // 浮点字段使用 asDouble
+}
+
+// sampleFields 与 anomalyFields 一样由 Sample 的数值字段反射得到，跳过时间戳
+var sampleFields = func() []sampleField {
+	t := reflect.TypeFor[metrics.Sample]()
+	var out []sampleField
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" || name == "time" {
+			continue
+		}
+		var float bool
+		switch f.Type.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		case reflect.Float32, reflect.Float64:
+			float = true
+		default:
+			continue
+		}
+		mn, ok := sampleMetricNames[name]
+		if !ok {
+			mn = sampleMetricName{name: "analysego.sample." + name}
+		}
+		out = append(out, sampleField{sampleMetricName: mn, index: i, float: float})
+	}
+	return out
+}()
+
+// sampleMetrics 把样本序列转换为 OTLP 指标，每个样本数值字段一个指标
+func sampleMetrics(samples []metrics.Sample, startNs string) []metric {
+	out := make([]metric, 0, len(sampleFields))
+	for _, f := range sampleFields {
+		points := make([]numberDataPoint, 0, len(samples))
+		for _, s := range samples {
+			ts := nanos(s.Time * int64(time.Millisecond))
+			v := reflect.ValueOf(s).Field(f.index)
+			var p numberDataPoint
+			switch {
+			case f.float:
+				p = doublePoint(ts, v.Float())
+			case v.CanInt():
+				p = intPoint(ts, v.Int())
+			default:
+				p = intPoint(ts, int64(v.Uint()))
+			}
+			if f.sum {
+				p = withStart(p, startNs)
+			}
+			points = append(points, p)
+		}
+		m := metric{Name: f.name, Unit: f.unit}
+		if f.sum {
+			m.Sum = &sum{DataPoints: points, AggregationTemporality: aggregationCumulative, IsMonotonic: true}
+		} else {
+			m.Gauge = &gauge{DataPoints: points}
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// withStart 设置累计指标的起始时间
+func withStart(p numberDataPoint, startNs string) numberDataPoint {
+	p.StartTimeUnixNano = startNs
+	return p
+}
@@ -0,0 +1,128 @@
+package otlp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"analyseGo/internal/metrics"
+)
+
+func TestExporterReceiverRoundTrip(t *testing.T) {
+	rv := NewReceiver(false)
+	var authorized atomic.Int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer secret" {
+			authorized.Add(1)
+		}
+		rv.Handler().ServeHTTP(w, r)
+	}))
+	defer collector.Close()
+
+	tracker := metrics.NewTracker(metrics.TrackerConfig{})
+	exporter := NewExporter(Config{
+		Endpoint: collector.URL + "/",
+		Headers:  map[string]string{"Authorization": "Bearer secret"},
+	}, tracker)
+	requestLog := metrics.NewRequestLog(16, 16, time.Minute)
+	tracker.AddHook(exporter)
+	tracker.AddHook(requestLog)
+
+	app := httptest.NewServer(metrics.Middleware(tracker, nil, nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})))
+	defer app.Close()
+
+	// 带上游 traceparent 的请求沿用其 trace，其余请求各自新建 trace
+	parent := NewSpanContext()
+	req, _ := http.NewRequest(http.MethodGet, app.URL+"/posts", nil)
+	Inject(ContextWithSpan(context.Background(), parent), req.Header)
+	for i := range 3 {
+		if i > 0 {
+			req, _ = http.NewRequest(http.MethodGet, app.URL+"/posts", nil)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	tracker.PushSample()
+
+	exporter.Export(context.Background())
+
+	stats := rv.Stats()
+	if stats.MetricRequests != 1 || stats.TraceRequests != 1 {
+		t.Fatalf("requests: metrics=%d traces=%d, want 1 each", stats.MetricRequests, stats.TraceRequests)
+	}
+	if n := authorized.Load(); n != 2 {
+		t.Errorf("requests with configured header = %d, want 2", n)
+	}
+	if stats.Spans != 3 || stats.SpanNames["GET /posts"] != 3 {
+		t.Errorf("spans = %d %v, want 3 named GET /posts", stats.Spans, stats.SpanNames)
+	}
+	for _, name := range []string{
+		"go.goroutines",
+		"http.server.requests.rate",
+		"http.server.active_requests",
+		"http.server.request.body.size",
+		"http.server.response.body.rate",
+		"http.server.request.count",
+		"http.server.request.duration",
+	} {
+		if stats.DataPoints[name] != 1 {
+			t.Errorf("data points for %s = %d, want 1", name, stats.DataPoints[name])
+		}
+	}
+
+	// 请求日志带出 span 的追踪 ID，上游传入的 trace 可以按 ID 查到
+	entries := requestLog.Query(metrics.RequestFilter{TraceID: parent.TraceIDHex()})
+	if len(entries) != 1 {
+		t.Fatalf("entries for upstream trace = %d, want 1", len(entries))
+	}
+	for _, e := range requestLog.Query(metrics.RequestFilter{}) {
+		if len(e.TraceID) != 32 {
+			t.Errorf("entry %+v has no trace ID", e)
+		}
+	}
+
+	// 已导出的样本和 span 不会重复导出
+	exporter.Export(context.Background())
+	if stats := rv.Stats(); stats.Spans != 3 || stats.DataPoints["go.goroutines"] != 1 {
+		t.Errorf("second export resent data: spans=%d goroutine points=%d", stats.Spans, stats.DataPoints["go.goroutines"])
+	}
+}
+
+func TestSampleFieldsCoverSample(t *testing.T) {
+	names := make(map[string]bool)
+	for _, f := range sampleFields {
+		if names[f.name] {
+			t.Errorf("duplicate metric name %s", f.name)
+		}
+		names[f.name] = true
+	}
+	for _, mn := range sampleMetricNames {
+		if !names[mn.name] {
+			t.Errorf("%s is not a numeric Sample field", mn.name)
+		}
+	}
+}
+
+func TestPropagate(t *testing.T) {
+	h := http.Header{}
+	Propagate(context.Background(), h)
+	root, ok := ParseTraceparent(h.Get(traceparentHeader))
+	if !ok || !root.Sampled {
+		t.Fatalf("new trace: traceparent %q", h.Get(traceparentHeader))
+	}
+
+	child := root.Child()
+	Propagate(ContextWithSpan(context.Background(), child), h)
+	got, ok := ParseTraceparent(h.Get(traceparentHeader))
+	if !ok || got.TraceID != root.TraceID || got.SpanID != child.SpanID || got.SpanID == root.SpanID {
+		t.Errorf("child span: got %+v, want trace %x span %x", got, root.TraceID, child.SpanID)
+	}
+}
@@ -0,0 +1,150 @@
+package otlp
+
+import "strconv"
+
+// 以下类型对应 OTLP/HTTP 的 JSON 编码（opentelemetry-proto 的 JSON 映射）
+// 64 位整数按规范编码为字符串，traceId/spanId 编码为十六进制字符串
+
+// aggregationCumulative AGGREGATION_TEMPORALITY_CUMULATIVE
+const aggregationCumulative = 2
+
+// span 类型与状态码
+const (
+	spanKindServer  = 2
+	statusCodeUnset = 0
+	statusCodeError = 2
+)
+
+type anyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// metricsRequest ExportMetricsServiceRequest
+type metricsRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type scopeMetrics struct {
+	Scope   scope    `json:"scope"`
+	Metrics []metric `json:"metrics"`
+}
+
+type metric struct {
+	Name      string     `json:"name"`
+	Unit      string     `json:"unit,omitempty"`
+	Gauge     *gauge     `json:"gauge,omitempty"`
+	Sum       *sum       `json:"sum,omitempty"`
+	Histogram *histogram `json:"histogram,omitempty"`
+}
+
+type gauge struct {
+	DataPoints []numberDataPoint `json:"dataPoints"`
+}
+
+type sum struct {
+	DataPoints             []numberDataPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type histogram struct {
+	DataPoints             []histogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type numberDataPoint struct {
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	AsInt             *string    `json:"asInt,omitempty"`
+	AsDouble          *float64   `json:"asDouble,omitempty"`
+}
+
+type histogramDataPoint struct {
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	Count             string     `json:"count"`
+	Sum               float64    `json:"sum"`
+	BucketCounts      []string   `json:"bucketCounts"`
+	ExplicitBounds    []float64  `json:"explicitBounds"`
+}
+
+// tracesRequest ExportTraceServiceRequest
+type tracesRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            spanStatus `json:"status"`
+}
+
+type spanStatus struct {
+	Code int `json:"code"`
+}
+
+// strAttr 构造字符串属性
+func strAttr(k, v string) keyValue {
+	return keyValue{Key: k, Value: anyValue{StringValue: &v}}
+}
+
+// intAttr 构造整数属性
+func intAttr(k string, v int64) keyValue {
+	s := strconv.FormatInt(v, 10)
+	return keyValue{Key: k, Value: anyValue{IntValue: &s}}
+}
+
+// intPoint 构造整数数据点
+func intPoint(ts string, v int64, attrs ...keyValue) numberDataPoint {
+	s := strconv.FormatInt(v, 10)
+	return numberDataPoint{Attributes: attrs, TimeUnixNano: ts, AsInt: &s}
+}
+
+// doublePoint 构造浮点数据点
+func doublePoint(ts string, v float64, attrs ...keyValue) numberDataPoint {
+	return numberDataPoint{Attributes: attrs, TimeUnixNano: ts, AsDouble: &v}
+}
+
+// nanos 把纳秒时间戳编码为字符串
+func nanos(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
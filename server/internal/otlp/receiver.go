@@ -0,0 +1,142 @@
+package otlp
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// ReceiverStats 接收端统计
+type ReceiverStats struct {
+	MetricRequests int            `json:"metricRequests"` // 收到的 /v1/metrics 请求数
+	TraceRequests  int            `json:"traceRequests"`  // 收到的 /v1/traces 请求数
+	DataPoints     map[string]int `json:"dataPoints"`     // 按指标名统计的数据点数
+	Spans          int            `json:"spans"`          // 收到的 span 总数
+	SpanNames      map[string]int `json:"spanNames"`      // 按 span 名称统计
+}
+
+// Receiver 最小化的 OTLP/HTTP JSON 接收端，用于本地联调导出器
+// 只做解码和计数，不做持久化
+type Receiver struct {
+	mu      sync.Mutex
+	stats   ReceiverStats
+	verbose bool
+}
+
+// NewReceiver 创建新的接收端，verbose 为 true 时打印每个 span
+func NewReceiver(verbose bool) *Receiver {
+	return &Receiver{
+		stats: ReceiverStats{
+			DataPoints: make(map[string]int),
+			SpanNames:  make(map[string]int),
+		},
+		verbose: verbose,
+	}
+}
+
+// Handler 返回接收端路由：POST /v1/metrics、POST /v1/traces、GET /stats
+func (rv *Receiver) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/metrics", rv.handleMetrics)
+	mux.HandleFunc("POST /v1/traces", rv.handleTraces)
+	mux.HandleFunc("GET /stats", rv.handleStats)
+	return mux
+}
+
+// Stats 返回统计快照
+func (rv *Receiver) Stats() ReceiverStats {
+	rv.mu.Lock()
+	defer rv.mu.Unlock()
+
+	out := rv.stats
+	out.DataPoints = make(map[string]int, len(rv.stats.DataPoints))
+	for k, v := range rv.stats.DataPoints {
+		out.DataPoints[k] = v
+	}
+	out.SpanNames = make(map[string]int, len(rv.stats.SpanNames))
+	for k, v := range rv.stats.SpanNames {
+		out.SpanNames[k] = v
+	}
+	return out
+}
+
+// handleMetrics 接收指标
+func (rv *Receiver) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var req metricsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rv.mu.Lock()
+	rv.stats.MetricRequests++
+	points := 0
+	for _, rm := range req.ResourceMetrics {
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				n := 0
+				switch {
+				case m.Gauge != nil:
+					n = len(m.Gauge.DataPoints)
+				case m.Sum != nil:
+					n = len(m.Sum.DataPoints)
+				case m.Histogram != nil:
+					n = len(m.Histogram.DataPoints)
+				}
+				rv.stats.DataPoints[m.Name] += n
+				points += n
+			}
+		}
+	}
+	rv.mu.Unlock()
+
+	slog.Info("Received metric data points", "points", points)
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// handleTraces 接收 span
+func (rv *Receiver) handleTraces(w http.ResponseWriter, r *http.Request) {
+	var req tracesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	rv.mu.Lock()
+	rv.stats.TraceRequests++
+	count := 0
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				rv.stats.Spans++
+				rv.stats.SpanNames[s.Name]++
+				count++
+				if rv.verbose {
+					slog.Info("span", "name", s.Name, "trace_id", s.TraceID, "span_id", s.SpanID, "parent_span_id", s.ParentSpanID)
+				}
+			}
+		}
+	}
+	rv.mu.Unlock()
+
+	slog.Info("Received spans", "spans", count)
+	writeJSON(w, http.StatusOK, struct{}{})
+}
+
+// handleStats 返回统计
+func (rv *Receiver) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, rv.Stats())
+}
+
+// writeJSON 以 JSON 格式写出响应
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError 以 {"error": "..."} 格式写出错误响应
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
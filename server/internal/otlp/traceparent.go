@@ -0,0 +1,134 @@
+package otlp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// traceparentHeader W3C Trace Context 请求头
+const traceparentHeader = "traceparent"
+
+// SpanContext 标识一个 span 的追踪上下文
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+	Sampled bool
+}
+
+// TraceIDHex 返回十六进制编码的 TraceID
+func (sc SpanContext) TraceIDHex() string {
+	return hex.EncodeToString(sc.TraceID[:])
+}
+
+// SpanIDHex 返回十六进制编码的 SpanID
+func (sc SpanContext) SpanIDHex() string {
+	return hex.EncodeToString(sc.SpanID[:])
+}
+
+// Traceparent 按 W3C 格式编码：00-{trace-id}-{span-id}-{flags}
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceIDHex() + "-" + sc.SpanIDHex() + "-" + flags
+}
+
+// ParseTraceparent 解析 W3C traceparent 头，格式非法或 ID 全零时返回 false
+func ParseTraceparent(v string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(v), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return sc, false
+	}
+	// 版本 00 必须恰好 4 段，更高版本允许追加字段
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, false
+	}
+	if sc.TraceID == [16]byte{} || sc.SpanID == [8]byte{} {
+		return sc, false
+	}
+	sc.Sampled = flags[0]&0x01 == 1
+	return sc, true
+}
+
+// NewSpanContext 生成新 trace 的根 span 上下文（已采样），供没有入站请求的出站调用使用
+func NewSpanContext() SpanContext {
+	return SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true}
+}
+
+// Child 返回同一 trace 下的新 span 上下文
+func (sc SpanContext) Child() SpanContext {
+	sc.SpanID = newSpanID()
+	return sc
+}
+
+// newSpanID 生成随机 SpanID
+func newSpanID() [8]byte {
+	var id [8]byte
+	rand.Read(id[:])
+	return id
+}
+
+// newTraceID 生成随机 TraceID
+func newTraceID() [16]byte {
+	var id [16]byte
+	rand.Read(id[:])
+	return id
+}
+
+type spanContextKey struct{}
+
+// activeSpan ctx 中保存的当前 span 及其父 span
+type activeSpan struct {
+	sc        SpanContext
+	parent    [8]byte
+	hasParent bool
+}
+
+// ContextWithSpan 把 span 上下文存入 ctx
+func ContextWithSpan(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, &activeSpan{sc: sc})
+}
+
+// SpanFromContext 从 ctx 取出当前 span 上下文
+func SpanFromContext(ctx context.Context) (SpanContext, bool) {
+	as, ok := ctx.Value(spanContextKey{}).(*activeSpan)
+	if !ok {
+		return SpanContext{}, false
+	}
+	return as.sc, true
+}
+
+// Inject 把 ctx 中的 span 上下文写入出站请求头，用于向下游传播
+func Inject(ctx context.Context, h http.Header) {
+	if sc, ok := SpanFromContext(ctx); ok {
+		h.Set(traceparentHeader, sc.Traceparent())
+	}
+}
+
+// Propagate 与 Inject 相同，但 ctx 中没有 span 时新建一条 trace，
+// 使后台任务（如 Agent 推送）发出的请求在下游也能按 trace 关联
+func Propagate(ctx context.Context, h http.Header) {
+	sc, ok := SpanFromContext(ctx)
+	if !ok {
+		sc = NewSpanContext()
+	}
+	h.Set(traceparentHeader, sc.Traceparent())
+}
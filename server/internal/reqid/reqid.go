@@ -0,0 +1,62 @@
+// Package reqid 提供请求 ID 的生成与上下文传递，以及追踪 ID 的上下文传递
+package reqid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+const (
+	// Header 请求 ID 请求头/响应头
+	Header = "X-Request-ID"
+	// maxLen 接受的外部请求 ID 最大长度
+	maxLen = 128
+)
+
+type ctxKey struct{}
+
+type traceIDKey struct{}
+
+// New 生成新的请求 ID（32 位十六进制）
+func New() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Valid 判断外部传入的请求 ID 是否可以直接沿用
+// 只接受可打印 ASCII 且不含空白，避免日志注入
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// WithContext 把请求 ID 存入 ctx
+func WithContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext 从 ctx 取出请求 ID，不存在时返回空字符串
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// WithTraceID 把追踪 ID（W3C trace-id 的十六进制形式）存入 ctx，供请求日志和访问日志关联
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceIDFromContext 从 ctx 取出追踪 ID，不存在时返回空字符串
+func TraceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
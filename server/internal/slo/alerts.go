@@ -0,0 +1,141 @@
+package slo
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"analyseGo/internal/metrics"
+)
+
+const (
+	// maxEvents 保留的最近告警事件数
+	maxEvents = 200
+	// minAlertRequests 长窗口请求数不足时不告警，避免个别失败请求触发
+	minAlertRequests = 10
+)
+
+// BurnRule 多窗口燃烧率告警规则：长窗口和短窗口的燃烧率都超过 Factor 时触发
+// 长窗口保证消耗足够显著，短窗口使问题恢复后告警能尽快解除
+type BurnRule struct {
+	Severity string        `json:"severity"` // page / ticket
+	Long     time.Duration `json:"-"`
+	Short    time.Duration `json:"-"`
+	LongWin  string        `json:"longWindow"`
+	ShortWin string        `json:"shortWindow"`
+	Factor   float64       `json:"factor"`
+}
+
+// burnRules 30 天周期的常用规则：1h 内耗尽 2%、6h 内耗尽 5%、1d 内耗尽 10% 的错误预算
+var burnRules = []BurnRule{
+	{Severity: "page", Long: time.Hour, Short: 5 * time.Minute, LongWin: "1h", ShortWin: "5m", Factor: 14.4},
+	{Severity: "page", Long: 6 * time.Hour, Short: 30 * time.Minute, LongWin: "6h", ShortWin: "30m", Factor: 6},
+	{Severity: "ticket", Long: 24 * time.Hour, Short: 2 * time.Hour, LongWin: "1d", ShortWin: "2h", Factor: 3},
+}
+
+// AlertState 一条规则的当前状态
+type AlertState struct {
+	BurnRule
+	LongBurn  float64 `json:"longBurn"`  // 最近一次评估的长窗口燃烧率
+	ShortBurn float64 `json:"shortBurn"` // 最近一次评估的短窗口燃烧率
+	Firing    bool    `json:"firing"`
+	Since     int64   `json:"since,omitempty"` // 开始告警的时间戳（毫秒）
+}
+
+// AlertEvent 告警触发或恢复事件
+type AlertEvent struct {
+	Time      int64   `json:"time"` // 时间戳（毫秒）
+	Objective string  `json:"objective"`
+	State     string  `json:"state"` // firing / resolved
+	Severity  string  `json:"severity"`
+	LongWin   string  `json:"longWindow"`
+	ShortWin  string  `json:"shortWindow"`
+	Factor    float64 `json:"factor"`
+	LongBurn  float64 `json:"longBurn"`
+	ShortBurn float64 `json:"shortBurn"`
+}
+
+// Run 每隔 interval 评估一次燃烧率告警，直到 ctx 取消
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	if len(m.objectives) == 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.evaluate()
+		}
+	}
+}
+
+// evaluate 评估所有规则，状态变化时记录事件、日志和注释
+func (m *Manager) evaluate() {
+	now := m.now()
+	var changed []AlertEvent
+
+	m.mu.Lock()
+	for _, o := range m.objectives {
+		for i := range o.alerts {
+			a := &o.alerts[i]
+			long := o.window(now, a.LongWin, a.Long)
+			short := o.window(now, a.ShortWin, a.Short)
+			a.LongBurn, a.ShortBurn = long.BurnRate, short.BurnRate
+
+			firing := long.Total >= minAlertRequests && a.LongBurn > a.Factor && a.ShortBurn > a.Factor
+			if firing == a.Firing {
+				continue
+			}
+			a.Firing = firing
+			a.Since = 0
+			state := "resolved"
+			if firing {
+				a.Since = now.UnixMilli()
+				state = "firing"
+			}
+			changed = append(changed, AlertEvent{
+				Time:      now.UnixMilli(),
+				Objective: o.Name,
+				State:     state,
+				Severity:  a.Severity,
+				LongWin:   a.LongWin,
+				ShortWin:  a.ShortWin,
+				Factor:    a.Factor,
+				LongBurn:  a.LongBurn,
+				ShortBurn: a.ShortBurn,
+			})
+		}
+	}
+	m.events = append(m.events, changed...)
+	if len(m.events) > maxEvents {
+		m.events = m.events[len(m.events)-maxEvents:]
+	}
+	m.mu.Unlock()
+
+	for _, e := range changed {
+		title := fmt.Sprintf("SLO %s %s: %s burn %.1f/%.1f over %s/%s (factor %g)",
+			e.Objective, e.State, e.Severity, e.LongBurn, e.ShortBurn, e.LongWin, e.ShortWin, e.Factor)
+		if e.State == "firing" {
+			slog.Warn("SLO alert firing", "objective", e.Objective, "severity", e.Severity,
+				"longWindow", e.LongWin, "longBurn", e.LongBurn, "shortWindow", e.ShortWin, "shortBurn", e.ShortBurn)
+			m.annotations.Record(title, metrics.TagAlert, "slo", e.Objective)
+			continue
+		}
+		slog.Info("SLO alert resolved", "objective", e.Objective, "severity", e.Severity, "longWindow", e.LongWin)
+		m.annotations.Record(title, "slo", e.Objective)
+	}
+}
+
+// Events 返回最近的告警事件，最新的在前
+func (m *Manager) Events() []AlertEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := slices.Clone(m.events)
+	slices.Reverse(out)
+	return out
+}
@@ -0,0 +1,29 @@
+package slo
+
+import (
+	"net/http"
+
+	"analyseGo/internal/ginutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HandleList 列出所有 SLO 的达标率、错误预算和告警状态
+func (m *Manager) HandleList(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"objectives": m.List()})
+}
+
+// HandleGet 返回单个 SLO 的状态
+func (m *Manager) HandleGet(c *gin.Context) {
+	s, err := m.Get(c.Param("name"))
+	if err != nil {
+		ginutil.Error(c, http.StatusNotFound, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, s)
+}
+
+// HandleAlerts 返回最近的告警触发和恢复事件
+func (m *Manager) HandleAlerts(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"events": m.Events()})
+}
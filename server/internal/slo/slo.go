@@ -0,0 +1,220 @@
+// Package slo 按路由跟踪延迟和可用性 SLO：滚动达标率、剩余错误预算和多窗口燃烧率告警
+//
+// 计数保存在内存中，进程重启即清零；只有通过 Save/Load 持久化（main 在设置 metrics.flushPath 时启用）
+// 才能跨重启累计 30 天窗口。Status.Since 给出计数实际覆盖的起始时间，早于它的请求不在任何窗口内。
+package slo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path"
+	"sync"
+	"time"
+
+	"analyseGo/internal/metrics"
+)
+
+// budgetWindow 错误预算的统计周期
+const budgetWindow = 30 * 24 * time.Hour
+
+// ErrNotFound SLO 不存在
+var ErrNotFound = errors.New("SLO not found")
+
+// Kind SLO 类型
+type Kind string
+
+const (
+	KindLatency      Kind = "latency"      // 耗时不超过阈值的请求达标
+	KindAvailability Kind = "availability" // 状态码小于 500 的请求达标
+)
+
+// Objective SLO 定义，由 config.Validate 校验
+type Objective struct {
+	Name      string
+	Route     string // 路由名，支持 path.Match 通配符
+	Method    string // 为空时匹配全部方法
+	Kind      Kind
+	Target    float64       // 达标请求比例，如 0.99
+	Threshold time.Duration // latency 类型的耗时阈值
+}
+
+// matches 请求是否计入该 SLO
+func (o Objective) matches(info metrics.RequestInfo) bool {
+	if o.Method != "" && o.Method != info.Method {
+		return false
+	}
+	ok, _ := path.Match(o.Route, info.Route)
+	return ok
+}
+
+// good 请求是否达标
+func (o Objective) good(info metrics.RequestInfo) bool {
+	if o.Kind == KindLatency {
+		return info.Duration <= o.Threshold
+	}
+	return info.Status < http.StatusInternalServerError
+}
+
+// Window 一个滚动窗口内的达标情况
+type Window struct {
+	Window     string  `json:"window"` // 1h / 1d / 30d
+	Total      uint64  `json:"total"`  // 请求数
+	Good       uint64  `json:"good"`   // 达标请求数
+	Compliance float64 `json:"compliance"`
+	// BudgetRemaining 按该窗口计算的剩余错误预算比例，1 表示未消耗，负数表示已超支
+	BudgetRemaining float64 `json:"budgetRemaining"`
+	// BurnRate 错误预算燃烧率：错误率 /（1 - target），1 表示恰好在周期末耗尽
+	BurnRate float64 `json:"burnRate"`
+}
+
+// Status SLO 的当前状态
+type Status struct {
+	Name        string   `json:"name"`
+	Route       string   `json:"route"`
+	Method      string   `json:"method,omitempty"`
+	Kind        Kind     `json:"kind"`
+	Target      float64  `json:"target"`
+	ThresholdMs float64  `json:"thresholdMs,omitempty"`
+	Windows     []Window `json:"windows"`
+	// ErrorBudget 30 天周期的错误预算
+	ErrorBudget Budget       `json:"errorBudget"`
+	Alerts      []AlertState `json:"alerts"` // 各燃烧率规则的当前状态
+	Firing      bool         `json:"firing"` // 是否有规则在告警
+	// Since 计数覆盖的起始时间（毫秒）：进程启动时间或恢复的持久化计数的起始时间，窗口早于它的部分没有数据
+	Since int64 `json:"since"`
+}
+
+// Budget 错误预算
+type Budget struct {
+	Allowed   float64 `json:"allowed"`   // 按周期内请求数允许的不达标请求数
+	Consumed  uint64  `json:"consumed"`  // 已有的不达标请求数
+	Remaining float64 `json:"remaining"` // 剩余比例，负数表示已超支
+}
+
+// statusWindows 状态中展示的滚动窗口
+var statusWindows = []struct {
+	name string
+	d    time.Duration
+}{
+	{"1h", time.Hour},
+	{"1d", 24 * time.Hour},
+	{"30d", budgetWindow},
+}
+
+// objective 运行中的 SLO：定义、计数和告警状态
+type objective struct {
+	Objective
+	counts *counter
+	alerts []AlertState // 与 burnRules 对应
+}
+
+// window 计算窗口内的达标率、剩余预算和燃烧率，调用方需持有 Manager.mu
+func (o *objective) window(now time.Time, name string, d time.Duration) Window {
+	total, good := o.counts.sum(now, d)
+	w := Window{Window: name, Total: total, Good: good, Compliance: 1, BudgetRemaining: 1}
+	if total > 0 {
+		errRate := float64(total-good) / float64(total)
+		w.Compliance = 1 - errRate
+		w.BurnRate = errRate / (1 - o.Target)
+		w.BudgetRemaining = 1 - w.BurnRate
+	}
+	return w
+}
+
+// Manager 维护所有 SLO 的计数和告警，作为请求钩子挂在 Tracker 上
+type Manager struct {
+	annotations *metrics.AnnotationLog
+	now         func() time.Time
+
+	mu         sync.Mutex
+	since      time.Time // 计数覆盖的起始时间
+	objectives []*objective
+	events     []AlertEvent // 最近的告警触发和恢复事件
+}
+
+// NewManager 创建 SLO 管理器并注册为 tracker 的请求钩子，annotations 用于记录告警，可为 nil
+func NewManager(tracker *metrics.Tracker, objectives []Objective, annotations *metrics.AnnotationLog) *Manager {
+	m := &Manager{annotations: annotations, now: time.Now}
+	m.since = m.now()
+	for _, o := range objectives {
+		obj := &objective{Objective: o, counts: newCounter(), alerts: make([]AlertState, len(burnRules))}
+		for i, r := range burnRules {
+			obj.alerts[i] = AlertState{BurnRule: r}
+		}
+		m.objectives = append(m.objectives, obj)
+	}
+	tracker.AddHook(m)
+	return m
+}
+
+// StartRequest 实现 metrics.RequestHook
+func (m *Manager) StartRequest(ctx context.Context, _ *http.Request, _ string) context.Context {
+	return ctx
+}
+
+// EndRequest 把请求计入匹配的 SLO
+func (m *Manager) EndRequest(_ context.Context, info metrics.RequestInfo) {
+	if len(m.objectives) == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range m.objectives {
+		if o.matches(info) {
+			o.counts.add(info.Start, o.good(info))
+		}
+	}
+}
+
+// status 生成 SLO 状态，调用方需持有 mu
+func (m *Manager) status(o *objective, now time.Time) Status {
+	s := Status{
+		Name:        o.Name,
+		Route:       o.Route,
+		Method:      o.Method,
+		Kind:        o.Kind,
+		Target:      o.Target,
+		ThresholdMs: float64(o.Threshold) / float64(time.Millisecond),
+		Alerts:      append([]AlertState(nil), o.alerts...),
+		Since:       m.since.UnixMilli(),
+	}
+	for _, sw := range statusWindows {
+		s.Windows = append(s.Windows, o.window(now, sw.name, sw.d))
+	}
+	total, good := o.counts.sum(now, budgetWindow)
+	s.ErrorBudget = Budget{
+		Allowed:   float64(total) * (1 - o.Target),
+		Consumed:  total - good,
+		Remaining: s.Windows[len(s.Windows)-1].BudgetRemaining,
+	}
+	for _, a := range o.alerts {
+		s.Firing = s.Firing || a.Firing
+	}
+	return s
+}
+
+// List 返回所有 SLO 的状态，顺序与定义一致
+func (m *Manager) List() []Status {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Status, 0, len(m.objectives))
+	for _, o := range m.objectives {
+		out = append(out, m.status(o, now))
+	}
+	return out
+}
+
+// Get 按名称返回 SLO 状态
+func (m *Manager) Get(name string) (Status, error) {
+	now := m.now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range m.objectives {
+		if o.Name == name {
+			return m.status(o, now), nil
+		}
+	}
+	return Status{}, ErrNotFound
+}
@@ -0,0 +1,152 @@
+package slo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// stateVersion 持久化状态的格式版本，不一致时忽略文件
+const stateVersion = 1
+
+// savedBucket 持久化的时间片
+type savedBucket struct {
+	Slot  int64  `json:"slot"`
+	Total uint64 `json:"total"`
+	Good  uint64 `json:"good"`
+}
+
+// savedObjective 单个 SLO 的持久化计数，定义变化后计数不再可比，按定义字段匹配
+type savedObjective struct {
+	Name        string        `json:"name"`
+	Route       string        `json:"route"`
+	Method      string        `json:"method,omitempty"`
+	Kind        Kind          `json:"kind"`
+	ThresholdMs float64       `json:"thresholdMs,omitempty"`
+	Minutes     []savedBucket `json:"minutes"`
+	Hours       []savedBucket `json:"hours"`
+}
+
+// savedState 持久化文件内容
+type savedState struct {
+	Version    int              `json:"version"`
+	Since      int64            `json:"since"` // 计数覆盖的起始时间（毫秒）
+	Objectives []savedObjective `json:"objectives"`
+}
+
+// saved 返回 ring 中的非空时间片
+func (r *ring) saved() []savedBucket {
+	var out []savedBucket
+	for _, b := range r.buckets {
+		if b.total > 0 {
+			out = append(out, savedBucket{Slot: b.slot, Total: b.total, Good: b.good})
+		}
+	}
+	return out
+}
+
+// restore 把持久化的时间片合并进 ring，同一位置保留较新的时间片
+func (r *ring) restore(saved []savedBucket) {
+	for _, s := range saved {
+		b := &r.buckets[s.Slot%int64(len(r.buckets))]
+		switch {
+		case b.slot < s.Slot:
+			*b = bucket{slot: s.Slot, total: s.Total, good: s.Good}
+		case b.slot == s.Slot:
+			b.total += s.Total
+			b.good += s.Good
+		}
+	}
+}
+
+// sameDefinition 持久化的计数是否属于同一定义的 SLO
+func (s savedObjective) sameDefinition(o Objective) bool {
+	return s.Name == o.Name && s.Route == o.Route && s.Method == o.Method && s.Kind == o.Kind &&
+		s.ThresholdMs == float64(o.Threshold)/float64(time.Millisecond)
+}
+
+// Save 把所有 SLO 的分钟和小时计数写入 path，先写临时文件再重命名
+func (m *Manager) Save(path string) error {
+	m.mu.Lock()
+	st := savedState{Version: stateVersion, Since: m.since.UnixMilli()}
+	for _, o := range m.objectives {
+		st.Objectives = append(st.Objectives, savedObjective{
+			Name:        o.Name,
+			Route:       o.Route,
+			Method:      o.Method,
+			Kind:        o.Kind,
+			ThresholdMs: float64(o.Threshold) / float64(time.Millisecond),
+			Minutes:     o.counts.minutes.saved(),
+			Hours:       o.counts.hours.saved(),
+		})
+	}
+	m.mu.Unlock()
+
+	data, err := json.Marshal(st)
+	if err != nil {
+		return fmt.Errorf("failed to marshal SLO state: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create SLO state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write SLO state file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write SLO state file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename SLO state file: %w", err)
+	}
+	return nil
+}
+
+// Load 读回 Save 写入的计数，文件不存在时不做任何事
+// 只恢复定义未变的 SLO；早于 30 天的时间片在统计时自然过期
+func (m *Manager) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read SLO state file: %w", err)
+	}
+	var st savedState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("failed to parse SLO state file: %w", err)
+	}
+	if st.Version != stateVersion {
+		return fmt.Errorf("unsupported SLO state version %d", st.Version)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	restored := false
+	for _, s := range st.Objectives {
+		for _, o := range m.objectives {
+			if o.Name != s.Name {
+				continue
+			}
+			if !s.sameDefinition(o.Objective) {
+				slog.Warn("Discarding saved SLO counts: definition changed", "objective", o.Name)
+				break
+			}
+			o.counts.minutes.restore(s.Minutes)
+			o.counts.hours.restore(s.Hours)
+			restored = true
+			break
+		}
+	}
+	if since := time.UnixMilli(st.Since); restored && since.Before(m.since) {
+		m.since = since
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package slo
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"analyseGo/internal/metrics"
+)
+
+// newTestManager 创建使用固定时钟的管理器
+func newTestManager(now time.Time, objectives ...Objective) *Manager {
+	m := NewManager(metrics.NewTracker(metrics.TrackerConfig{}), objectives, nil)
+	m.now = func() time.Time { return now }
+	m.since = now
+	return m
+}
+
+func TestSaveLoadRestoresCounts(t *testing.T) {
+	avail := Objective{Name: "avail", Route: "/api/*", Kind: KindAvailability, Target: 0.99}
+	latency := Objective{Name: "latency", Route: "/api/*", Kind: KindLatency, Target: 0.9, Threshold: 100 * time.Millisecond}
+	path := filepath.Join(t.TempDir(), "history.slo.json")
+
+	// 第一次运行：40 小时内的请求，超出分钟粒度的 1 天范围
+	start := base
+	before := newTestManager(start, avail, latency)
+	for i := range 40 {
+		status := http.StatusOK
+		if i%4 == 0 {
+			status = http.StatusInternalServerError
+		}
+		before.EndRequest(t.Context(), metrics.RequestInfo{
+			Method: "GET", Route: "/api/posts", Status: status,
+			Start: start.Add(time.Duration(i) * time.Hour), Duration: 50 * time.Millisecond,
+		})
+	}
+	if err := before.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重启后：延迟 SLO 的阈值改了，计数不再可比，应被丢弃
+	now := start.Add(40 * time.Hour)
+	changed := latency
+	changed.Threshold = 200 * time.Millisecond
+	after := newTestManager(now, avail, changed)
+	if err := after.Load(path); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := after.Get("avail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := got.ErrorBudget; b.Consumed != 10 || got.Windows[2].Total != 40 {
+		t.Errorf("30d after restore: total=%d consumed=%d, want 40 and 10", got.Windows[2].Total, b.Consumed)
+	}
+	if w := got.Windows[1]; w.Total != 23 { // 第 17..39 小时
+		t.Errorf("1d total after restore = %d, want 23", w.Total)
+	}
+	if got.Since != start.UnixMilli() {
+		t.Errorf("since = %d, want restored start %d", got.Since, start.UnixMilli())
+	}
+
+	lat, _ := after.Get("latency")
+	if lat.Windows[2].Total != 0 {
+		t.Errorf("changed objective restored %d requests, want 0", lat.Windows[2].Total)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	m := newTestManager(base, Objective{Name: "avail", Route: "*", Kind: KindAvailability, Target: 0.99})
+	if err := m.Load(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Fatalf("missing file: %v", err)
+	}
+	if s, _ := m.Get("avail"); s.Since != base.UnixMilli() {
+		t.Errorf("since = %d, want start time %d", s.Since, base.UnixMilli())
+	}
+}
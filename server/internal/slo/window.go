@@ -0,0 +1,78 @@
+package slo
+
+import "time"
+
+// bucket 一个时间片内的请求计数
+type bucket struct {
+	slot  int64 // 时间片序号（Unix 时间 / 宽度），用于识别过期的桶
+	total uint64
+	good  uint64
+}
+
+// ring 固定宽度时间片的环形计数器，覆盖 width × len(buckets) 的时长
+type ring struct {
+	width   time.Duration
+	buckets []bucket
+}
+
+// newRing 创建覆盖 span 时长、每片 width 的计数器
+func newRing(width, span time.Duration) *ring {
+	return &ring{width: width, buckets: make([]bucket, span/width)}
+}
+
+// add 把一次请求计入 t 所在的时间片，早于 ring 覆盖范围（位置已被更新的时间片占用）的请求丢弃
+func (r *ring) add(t time.Time, good bool) {
+	slot := t.UnixNano() / int64(r.width)
+	b := &r.buckets[slot%int64(len(r.buckets))]
+	if b.slot > slot {
+		return
+	}
+	if b.slot != slot {
+		*b = bucket{slot: slot}
+	}
+	b.total++
+	if good {
+		b.good++
+	}
+}
+
+// sum 返回截至 now 最近 window 时长（按时间片取整，含当前片）的请求数和达标数
+func (r *ring) sum(now time.Time, window time.Duration) (total, good uint64) {
+	cur := now.UnixNano() / int64(r.width)
+	n := min(int64(window/r.width), int64(len(r.buckets)))
+	for _, b := range r.buckets {
+		if b.slot > cur-n && b.slot <= cur {
+			total += b.total
+			good += b.good
+		}
+	}
+	return total, good
+}
+
+// counter 请求计数：分钟粒度覆盖 1 天，用于 1h/1d 窗口和燃烧率；小时粒度覆盖 30 天
+type counter struct {
+	minutes *ring
+	hours   *ring
+}
+
+// newCounter 创建计数器
+func newCounter() *counter {
+	return &counter{
+		minutes: newRing(time.Minute, 24*time.Hour),
+		hours:   newRing(time.Hour, budgetWindow),
+	}
+}
+
+// add 计入一次请求
+func (c *counter) add(t time.Time, good bool) {
+	c.minutes.add(t, good)
+	c.hours.add(t, good)
+}
+
+// sum 返回最近 window 的计数，超过 1 天的窗口使用小时粒度
+func (c *counter) sum(now time.Time, window time.Duration) (total, good uint64) {
+	if window <= 24*time.Hour {
+		return c.minutes.sum(now, window)
+	}
+	return c.hours.sum(now, window)
+}
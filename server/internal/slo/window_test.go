@@ -0,0 +1,84 @@
+package slo
+
+import (
+	"testing"
+	"time"
+)
+
+// base 测试用的整点时间
+var base = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestRingSumWindows(t *testing.T) {
+	r := newRing(time.Minute, 10*time.Minute)
+	for i := range 10 {
+		ts := base.Add(time.Duration(i) * time.Minute)
+		r.add(ts, true)
+		r.add(ts.Add(30*time.Second), i%2 == 0)
+	}
+	now := base.Add(9*time.Minute + 59*time.Second)
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		window    time.Duration
+		wantTotal uint64
+		wantGood  uint64
+	}{
+		{name: "current slice only", now: now, window: time.Minute, wantTotal: 2, wantGood: 1},
+		{name: "partial slices truncate", now: now, window: 90 * time.Second, wantTotal: 2, wantGood: 1},
+		{name: "whole ring", now: now, window: 10 * time.Minute, wantTotal: 20, wantGood: 15},
+		{name: "window capped at span", now: now, window: time.Hour, wantTotal: 20, wantGood: 15},
+		{name: "older slices expire", now: now.Add(5 * time.Minute), window: 10 * time.Minute, wantTotal: 10, wantGood: 7},
+		{name: "future slices excluded", now: base.Add(4 * time.Minute), window: 10 * time.Minute, wantTotal: 10, wantGood: 8},
+		{name: "all expired", now: now.Add(10 * time.Minute), window: 10 * time.Minute, wantTotal: 0, wantGood: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			total, good := r.sum(tt.now, tt.window)
+			if total != tt.wantTotal || good != tt.wantGood {
+				t.Errorf("sum = (%d, %d), want (%d, %d)", total, good, tt.wantTotal, tt.wantGood)
+			}
+		})
+	}
+}
+
+func TestRingReusesSlotAfterWrap(t *testing.T) {
+	r := newRing(time.Minute, 10*time.Minute)
+	r.add(base, false)
+	r.add(base.Add(10*time.Minute), true)
+
+	total, good := r.sum(base.Add(10*time.Minute), 10*time.Minute)
+	if total != 1 || good != 1 {
+		t.Errorf("after wrap sum = (%d, %d), want (1, 1)", total, good)
+	}
+}
+
+func TestRingDropsAddsOlderThanSpan(t *testing.T) {
+	r := newRing(time.Minute, 10*time.Minute)
+	r.add(base.Add(10*time.Minute), true)
+	// 与上面同一位置、早一整圈的请求不能清掉较新的时间片
+	r.add(base, false)
+
+	total, good := r.sum(base.Add(10*time.Minute), time.Minute)
+	if total != 1 || good != 1 {
+		t.Errorf("sum = (%d, %d), want (1, 1)", total, good)
+	}
+}
+
+func TestCounterUsesHoursBeyondOneDay(t *testing.T) {
+	c := newCounter()
+	c.add(base, true)
+	c.add(base.Add(25*time.Hour), false)
+	now := base.Add(25*time.Hour + time.Minute)
+
+	if total, _ := c.sum(now, 24*time.Hour); total != 1 {
+		t.Errorf("1d total = %d, want 1", total)
+	}
+	total, good := c.sum(now, budgetWindow)
+	if total != 2 || good != 1 {
+		t.Errorf("30d sum = (%d, %d), want (2, 1)", total, good)
+	}
+	if total, _ := c.sum(base.Add(budgetWindow+time.Hour), budgetWindow); total != 1 {
+		t.Errorf("30d total after first hour expired = %d, want 1", total)
+	}
+}
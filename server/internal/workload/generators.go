@@ -0,0 +1,312 @@
+package workload
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// allocTick 分配负载的节拍，每拍分配 rate*allocTick 字节，见 allocPacer
+const allocTick = 10 * time.Millisecond
+
+// generator 执行负载直到 ctx 结束，返回前须等待自己启动的 goroutine 全部退出
+type generator func(ctx context.Context, s Spec) error
+
+// generators 各类型负载的实现
+var generators = map[Kind]generator{
+	KindCPU:   runCPU,
+	KindAlloc: runAlloc,
+	KindLock:  runLock,
+	KindChan:  runChan,
+	KindIO:    runIO,
+	KindSleep: runSleep,
+}
+
+// cpuSink 防止自旋计算被编译器优化掉
+var cpuSink atomic.Uint64
+
+// runCPU 每个 worker 占满一个 CPU
+func runCPU(ctx context.Context, s Spec) error {
+	var wg sync.WaitGroup
+	for range s.Workers {
+		wg.Go(func() {
+			x := uint64(1)
+			for ctx.Err() == nil {
+				for range 100000 {
+					x = x*6364136223846793005 + 1442695040888963407
+				}
+			}
+			cpuSink.Add(x)
+		})
+	}
+	wg.Wait()
+	return nil
+}
+
+// runAlloc 按速率分配对象；RetainMB 内的对象被保留，Leak 时只增不换，否则按 FIFO 替换
+func runAlloc(ctx context.Context, s Spec) error {
+	var wg sync.WaitGroup
+	for i := range s.Workers {
+		wg.Go(func() {
+			pacer := newAllocPacer(s)
+			retain := retainObjects(s, i)
+			kept := make([][]byte, 0, min(retain, 1<<16))
+			next := 0
+			ticker := time.NewTicker(allocTick)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+				for range pacer.next() {
+					// 大小不是常量，总在堆上分配；不保留时随即成为垃圾
+					b := make([]byte, s.ObjectBytes)
+					b[0] = 1
+					switch {
+					case len(kept) < retain:
+						kept = append(kept, b)
+					case retain > 0 && !s.Leak:
+						kept[next] = b
+						next = (next + 1) % retain
+					}
+				}
+			}
+		})
+	}
+	wg.Wait()
+	return nil
+}
+
+// allocPacer 把一个 worker 的分配速率折算为每拍的对象数
+// 每拍不足一个对象的字节累计到之后的节拍，速率低或对象大时平均速率仍等于 RateKB
+type allocPacer struct {
+	perTick float64 // 每拍应分配的字节
+	object  float64 // 单个对象的字节
+	carry   float64 // 尚未分配的字节
+}
+
+// newAllocPacer 按 RateKB 在 Workers 间平分速率
+func newAllocPacer(s Spec) allocPacer {
+	return allocPacer{
+		perTick: float64(s.RateKB<<10) / float64(s.Workers) * allocTick.Seconds(),
+		object:  float64(s.ObjectBytes),
+	}
+}
+
+// next 返回本拍应分配的对象数
+func (p *allocPacer) next() int {
+	p.carry += p.perTick
+	n := math.Floor(p.carry / p.object)
+	p.carry -= n * p.object
+	return int(n)
+}
+
+// retainObjects 返回第 worker 个 worker 保留的对象数，各 worker 之和为 RetainMB 折算的对象数，RetainMB 为 0 时不保留
+func retainObjects(s Spec, worker int) int {
+	total := (s.RetainMB << 20) / s.ObjectBytes
+	n := total / s.Workers
+	if worker < total%s.Workers {
+		n++
+	}
+	return n
+}
+
+// runLock 所有 worker 竞争同一把互斥锁，持锁 HoldMs 后释放
+func runLock(ctx context.Context, s Spec) error {
+	var mu sync.Mutex
+	hold := time.Duration(s.HoldMs) * time.Millisecond
+
+	var wg sync.WaitGroup
+	for range s.Workers {
+		wg.Go(func() {
+			timer := time.NewTimer(hold)
+			defer timer.Stop()
+			for ctx.Err() == nil {
+				mu.Lock()
+				timer.Reset(hold)
+				select {
+				case <-ctx.Done():
+				case <-timer.C:
+				}
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	return nil
+}
+
+// runChan worker 阻塞在无人收发的通道上，取消时关闭通道或逐个接收以唤醒
+func runChan(ctx context.Context, s Spec) error {
+	ch := make(chan struct{})
+	var wg sync.WaitGroup
+	for range s.Workers {
+		if s.ChanMode == ChanModeSend {
+			wg.Go(func() { ch <- struct{}{} })
+		} else {
+			wg.Go(func() { <-ch })
+		}
+	}
+
+	<-ctx.Done()
+	if s.ChanMode == ChanModeSend {
+		for range s.Workers {
+			<-ch
+		}
+	} else {
+		close(ch)
+	}
+	wg.Wait()
+	return nil
+}
+
+// runSleep worker 空等到任务结束
+func runSleep(ctx context.Context, s Spec) error {
+	var wg sync.WaitGroup
+	for range s.Workers {
+		wg.Go(func() { <-ctx.Done() })
+	}
+	wg.Wait()
+	return nil
+}
+
+// runIO 按模式执行文件或回环 socket IO
+func runIO(ctx context.Context, s Spec) error {
+	if s.IOMode == IOModeNet {
+		return runNetIO(ctx, s)
+	}
+	return runFileIO(ctx, s)
+}
+
+// runFileIO 每个 worker 向自己的临时文件写入并 fsync，写满 FileMB 后从头覆写，退出时删除文件
+func runFileIO(ctx context.Context, s Spec) error {
+	files := make([]*os.File, 0, s.Workers)
+	defer func() {
+		for _, f := range files {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+	for range s.Workers {
+		f, err := os.CreateTemp("", "analysego-workload-*")
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+
+	chunk := make([]byte, s.ChunkKB<<10)
+	interval := time.Duration(s.IntervalMs) * time.Millisecond
+	fileSize := s.FileMB << 20
+	errs := make(chan error, s.Workers)
+	var wg sync.WaitGroup
+	for _, f := range files {
+		wg.Go(func() {
+			var size int
+			for {
+				if size+len(chunk) > fileSize {
+					if _, err := f.Seek(0, io.SeekStart); err != nil {
+						errs <- err
+						return
+					}
+					size = 0
+				}
+				n, err := f.Write(chunk)
+				if err == nil {
+					err = f.Sync()
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+				size += n
+				if !sleep(ctx, interval) {
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// runNetIO 建立 Workers 条回环 TCP 连接，服务端每隔 IntervalMs 写出一块，客户端阻塞读取
+func runNetIO(ctx context.Context, s Spec) error {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	// 取消时关闭监听和所有连接，唤醒阻塞在 Accept/Read/Write 上的 goroutine
+	stopLn := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stopLn()
+
+	chunk := make([]byte, s.ChunkKB<<10)
+	interval := time.Duration(s.IntervalMs) * time.Millisecond
+	wg.Go(func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			wg.Go(func() {
+				defer stop()
+				defer conn.Close()
+				for sleep(ctx, interval) {
+					if _, err := conn.Write(chunk); err != nil {
+						return
+					}
+				}
+			})
+		}
+	})
+
+	for range s.Workers {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			cancel()
+			wg.Wait()
+			return err
+		}
+		stop := context.AfterFunc(ctx, func() { conn.Close() })
+		wg.Go(func() {
+			defer stop()
+			defer conn.Close()
+			buf := make([]byte, len(chunk))
+			for {
+				if _, err := io.ReadFull(conn, buf); err != nil {
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	return nil
+}
+
+// sleep 等待 d 或 ctx 结束，ctx 结束时返回 false
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,70 @@
+package workload
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAllocPacer(t *testing.T) {
+	tests := []struct {
+		name  string
+		spec  Spec
+		ticks int
+	}{
+		{"many objects per tick", Spec{RateKB: 10240, ObjectBytes: 4096, Workers: 1}, 100},
+		{"split across workers", Spec{RateKB: 10240, ObjectBytes: 4096, Workers: 3}, 100},
+		{"one object every few ticks", Spec{RateKB: 1, ObjectBytes: 4096, Workers: 1}, 1000},
+		{"object larger than a tick", Spec{RateKB: 1024, ObjectBytes: 1 << 20, Workers: 4}, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newAllocPacer(tt.spec)
+			got := 0
+			for range tt.ticks {
+				got += p.next()
+			}
+			// 每个 worker 的速率 × 时长 / 对象大小，累计误差不超过一个对象
+			elapsed := time.Duration(tt.ticks) * allocTick
+			want := float64(tt.spec.RateKB<<10) / float64(tt.spec.Workers) * elapsed.Seconds() / float64(tt.spec.ObjectBytes)
+			if math.Abs(float64(got)-want) > 1 {
+				t.Errorf("allocated %d objects in %v, want %.2f", got, elapsed, want)
+			}
+		})
+	}
+}
+
+func TestRetainObjects(t *testing.T) {
+	tests := []struct {
+		spec Spec
+		want int // 各 worker 之和
+	}{
+		{Spec{RetainMB: 0, ObjectBytes: 4096, Workers: 4}, 0},
+		{Spec{RetainMB: 1, ObjectBytes: 4096, Workers: 1}, 256},
+		{Spec{RetainMB: 1, ObjectBytes: 4096, Workers: 1000}, 256},
+		{Spec{RetainMB: 10, ObjectBytes: 3000, Workers: 7}, (10 << 20) / 3000},
+	}
+	for _, tt := range tests {
+		sum, lo, hi := 0, math.MaxInt, 0
+		for i := range tt.spec.Workers {
+			n := retainObjects(tt.spec, i)
+			sum += n
+			lo, hi = min(lo, n), max(hi, n)
+		}
+		if sum != tt.want || hi-lo > 1 {
+			t.Errorf("retainObjects(%+v): sum %d (want %d), per worker %d..%d", tt.spec, sum, tt.want, lo, hi)
+		}
+	}
+}
+
+func TestRunAllocWithoutRetention(t *testing.T) {
+	for _, leak := range []bool{false, true} {
+		s := Spec{Kind: KindAlloc, Workers: 2, RateKB: 1024, ObjectBytes: 1024, Leak: leak}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*allocTick)
+		if err := runAlloc(ctx, s); err != nil {
+			t.Errorf("leak=%v: %v", leak, err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,72 @@
+package workload
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"analyseGo/internal/ginutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cancelWait 取消任务时等待其退出的最长时间
+const cancelWait = 5 * time.Second
+
+// HandleStart 返回启动指定类型负载任务的处理器，请求体为 Spec（可为空，使用默认参数）
+func (m *Manager) HandleStart(kind Kind) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var s Spec
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&s); err != nil {
+				ginutil.Error(c, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+		s.Kind = kind
+		job, err := m.Start(s)
+		if err != nil {
+			ginutil.Error(c, StartErrorStatus(err), err.Error())
+			return
+		}
+		c.JSON(http.StatusAccepted, job)
+	}
+}
+
+// StartErrorStatus 返回 Start 错误对应的状态码：预算不足为 429，其余为 400
+func StartErrorStatus(err error) int {
+	if errors.Is(err, ErrBudgetExceeded) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusBadRequest
+}
+
+// HandleList 列出所有任务（含压测）及 goroutine、内存和磁盘预算
+func (m *Manager) HandleList(c *gin.Context) {
+	jobs, budget := m.List()
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs, "budget": budget})
+}
+
+// HandleGet 获取任务状态
+func (m *Manager) HandleGet(c *gin.Context) {
+	job, ok := m.Get(c.Param("id"))
+	if !ok {
+		ginutil.Error(c, http.StatusNotFound, ErrNotFound.Error())
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// HandleCancel 取消任务，等待其 goroutine 退出后返回最终状态
+// 最多等待 cancelWait，超时返回 cancelling 状态，可稍后通过 HandleGet 查询
+func (m *Manager) HandleCancel(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), cancelWait)
+	defer cancel()
+	job, err := m.Cancel(ctx, c.Param("id"))
+	if err != nil {
+		ginutil.Error(c, http.StatusNotFound, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,406 @@
+package workload
+
+import (
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	pprof "runtime/pprof"
+	"slices"
+	"sync"
+	"time"
+
+	"analyseGo/internal/metrics"
+)
+
+const (
+	// defaultMaxGoroutines 默认的全局 goroutine 预算
+	defaultMaxGoroutines = 10000
+	// defaultMaxMemoryMB 默认的全局内存预算
+	defaultMaxMemoryMB = 2048
+	// defaultMaxDiskMB 默认的全局磁盘预算
+	defaultMaxDiskMB = 4096
+	// defaultMaxDuration 默认的单个任务最长持续时间
+	defaultMaxDuration = 10 * time.Minute
+	// defaultFinishedTTL 默认的已结束任务保留时间
+	defaultFinishedTTL = 10 * time.Minute
+	// maxFinished 保留的已结束任务数，超出时丢弃最早的
+	maxFinished = 50
+	// JobLabel 任务 goroutine 的 pprof 标签，值为任务 ID
+	JobLabel = "job"
+	// JobKindLabel 任务 goroutine 的 pprof 标签，值为负载类型
+	JobKindLabel = "job_kind"
+)
+
+var (
+	// ErrNotFound 任务不存在
+	ErrNotFound = errors.New("job not found")
+	// ErrBudgetExceeded 全局 goroutine、内存或磁盘预算不足
+	ErrBudgetExceeded = errors.New("workload budget exceeded")
+)
+
+// State 任务状态
+type State string
+
+const (
+	StateRunning    State = "running"    // 运行中
+	StateCancelling State = "cancelling" // 已通知取消，goroutine 尚未全部退出
+	StateCompleted  State = "completed"  // 按时结束
+	StateCancelled  State = "cancelled"  // 被取消
+	StateFailed     State = "failed"     // 出错退出
+)
+
+// Job 任务的状态快照
+type Job struct {
+	ID         string `json:"id"`
+	Name       string `json:"name,omitempty"` // 外部任务的名称，如压测 ID
+	Spec       Spec   `json:"spec"`
+	State      State  `json:"state"`
+	Error      string `json:"error,omitempty"`
+	Goroutines int    `json:"goroutines"` // 占用的 goroutine 预算
+	MemoryMB   int    `json:"memoryMB"`   // 占用的内存预算
+	DiskMB     int    `json:"diskMB"`     // 占用的磁盘预算
+	StartedAt  int64  `json:"startedAt"`  // 开始时间戳（毫秒）
+	ExpiresAt  int64  `json:"expiresAt"`  // 到期自动结束的时间戳（毫秒）
+	EndedAt    int64  `json:"endedAt"`    // 结束时间戳（毫秒），运行中为 0
+	// Runtime 按 job 标签统计的实际 goroutine 数和阻塞分类，仅运行中和取消中的任务带出
+	Runtime *metrics.LabelBlocks `json:"runtime,omitempty"`
+	// RuntimeError 无法按 job 标签统计时的原因，此时 Runtime 为空
+	RuntimeError string `json:"runtimeError,omitempty"`
+}
+
+// Budget 全局预算，运行中任务按 Spec 估算的用量占用
+type Budget struct {
+	Max         int `json:"max"`         // goroutine 上限
+	Reserved    int `json:"reserved"`    // 运行中任务占用的 goroutine 数
+	MaxMemoryMB int `json:"maxMemoryMB"` // 内存上限（MB）
+	MemoryMB    int `json:"memoryMB"`    // 运行中任务占用的内存（MB）
+	MaxDiskMB   int `json:"maxDiskMB"`   // 磁盘上限（MB）
+	DiskMB      int `json:"diskMB"`      // 运行中任务占用的磁盘（MB）
+}
+
+// fits 预算能否再容纳一个任务，不能时返回说明
+func (b Budget) fits(j Job) error {
+	check := func(resource string, need, used, max int) error {
+		if used+need <= max {
+			return nil
+		}
+		return fmt.Errorf("%w: %s needs %d, %d of %d available", ErrBudgetExceeded, resource, need, max-used, max)
+	}
+	return errors.Join(
+		check("goroutines", j.Goroutines, b.Reserved, b.Max),
+		check("memoryMB", j.MemoryMB, b.MemoryMB, b.MaxMemoryMB),
+		check("diskMB", j.DiskMB, b.DiskMB, b.MaxDiskMB),
+	)
+}
+
+// Options 任务管理器选项，零值字段使用默认值
+type Options struct {
+	MaxGoroutines int           // 所有运行中任务的 goroutine 总预算
+	MaxMemoryMB   int           // 所有运行中任务保留内存和缓冲的总预算（MB）
+	MaxDiskMB     int           // 所有运行中任务临时文件的总预算（MB）
+	MaxDuration   time.Duration // 单个任务最长持续时间
+	FinishedTTL   time.Duration // 已结束任务在列表中的保留时间
+	// Annotations 非空时记录任务开始和结束注释
+	Annotations *metrics.AnnotationLog
+}
+
+// job 一个运行中或已结束的任务
+type job struct {
+	run    func(ctx context.Context) error
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu    sync.Mutex
+	state Job
+}
+
+// snapshot 返回状态快照
+func (j *job) snapshot() Job {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.state
+}
+
+// Manager 管理模拟负载任务：分配 ID、限制全局 goroutine、内存和磁盘用量、取消和到期清理
+type Manager struct {
+	opts Options
+
+	mu     sync.Mutex
+	jobs   []*job // 按启动时间排序
+	budget Budget // 上限和运行中任务的占用
+}
+
+// NewManager 创建任务管理器
+func NewManager(opts Options) *Manager {
+	if opts.MaxGoroutines <= 0 {
+		opts.MaxGoroutines = defaultMaxGoroutines
+	}
+	if opts.MaxDuration <= 0 {
+		opts.MaxDuration = defaultMaxDuration
+	}
+	if opts.MaxMemoryMB <= 0 {
+		opts.MaxMemoryMB = defaultMaxMemoryMB
+	}
+	if opts.MaxDiskMB <= 0 {
+		opts.MaxDiskMB = defaultMaxDiskMB
+	}
+	if opts.FinishedTTL <= 0 {
+		opts.FinishedTTL = defaultFinishedTTL
+	}
+	return &Manager{
+		opts:   opts,
+		budget: Budget{Max: opts.MaxGoroutines, MaxMemoryMB: opts.MaxMemoryMB, MaxDiskMB: opts.MaxDiskMB},
+	}
+}
+
+// Start 校验参数、占用预算并在后台启动任务，到达持续时间后自动结束
+func (m *Manager) Start(s Spec) (Job, error) {
+	if err := s.normalize(m.opts.MaxDuration); err != nil {
+		return Job{}, err
+	}
+	gen := generators[s.Kind]
+	return m.start(Job{
+		Spec:       s,
+		Goroutines: s.goroutines(),
+		MemoryMB:   s.memoryMB(),
+		DiskMB:     s.diskMB(),
+	}, func(ctx context.Context) error { return gen(ctx, s) })
+}
+
+// StartFunc 以任务形式运行外部负载（如压测）：占用 goroutine 预算、带任务标签并出现在任务列表中
+// s.Workers 为占用的 goroutine 数，s.DurationSec 为到期取消的时限，由调用方校验；
+// fn 须在 ctx 结束后尽快返回
+func (m *Manager) StartFunc(name string, s Spec, fn func(ctx context.Context) error) (Job, error) {
+	if s.Workers <= 0 || s.DurationSec <= 0 {
+		return Job{}, errors.New("workers and durationSec must be positive")
+	}
+	return m.start(Job{Name: name, Spec: s, Goroutines: s.Workers}, fn)
+}
+
+// start 占用预算并在后台启动任务，j 只需填写名称、参数和预算占用
+func (m *Manager) start(j Job, fn func(ctx context.Context) error) (Job, error) {
+	m.mu.Lock()
+	if err := m.budget.fits(j); err != nil {
+		m.mu.Unlock()
+		return Job{}, err
+	}
+	m.budget.Reserved += j.Goroutines
+	m.budget.MemoryMB += j.MemoryMB
+	m.budget.DiskMB += j.DiskMB
+
+	now := time.Now()
+	d := j.Spec.duration()
+	ctx, cancel := context.WithDeadline(context.Background(), now.Add(d))
+	j.ID = newID()
+	j.State = StateRunning
+	j.StartedAt = now.UnixMilli()
+	j.ExpiresAt = now.Add(d).UnixMilli()
+	jb := &job{
+		run:    fn,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		state:  j,
+	}
+	m.jobs = append(m.jobs, jb)
+	m.prune(now)
+	m.mu.Unlock()
+
+	go m.run(ctx, jb)
+	return jb.snapshot(), nil
+}
+
+// run 在带任务标签的 pprof 上下文中执行负载，结束后归还预算
+// 标签基于空上下文，不继承发起请求的 route 标签，子 goroutine 会继承任务标签
+func (m *Manager) run(ctx context.Context, j *job) {
+	defer close(j.done)
+	defer j.cancel()
+
+	s := j.state.Spec
+	id := j.state.ID
+	// 外部任务（如压测）由调用方记录注释
+	annotations := m.opts.Annotations
+	if j.state.Name != "" {
+		annotations = nil
+	}
+	slog.Info("Job started", "id", id, "name", j.state.Name, "kind", s.Kind, "workers", s.Workers)
+	annotations.Record(fmt.Sprintf("Job %s started: %s", id, s.Kind), metrics.TagJob, string(s.Kind))
+
+	var err error
+	pprof.Do(ctx, pprof.Labels(JobLabel, id, JobKindLabel, string(s.Kind)), func(ctx context.Context) {
+		err = j.run(ctx)
+	})
+
+	m.mu.Lock()
+	m.budget.Reserved -= j.state.Goroutines
+	m.budget.MemoryMB -= j.state.MemoryMB
+	m.budget.DiskMB -= j.state.DiskMB
+	m.mu.Unlock()
+
+	j.mu.Lock()
+	j.state.EndedAt = time.Now().UnixMilli()
+	switch {
+	case err != nil:
+		j.state.State = StateFailed
+		j.state.Error = err.Error()
+	case j.state.State == StateRunning:
+		j.state.State = StateCompleted
+	case j.state.State == StateCancelling:
+		j.state.State = StateCancelled
+	}
+	state := j.state.State
+	j.mu.Unlock()
+	annotations.Record(fmt.Sprintf("Job %s %s: %s", id, state, s.Kind), metrics.TagJob, string(s.Kind))
+
+	if err != nil {
+		slog.Warn("Job failed", "id", id, "kind", s.Kind, "error", err)
+		return
+	}
+	slog.Info("Job finished", "id", id, "kind", s.Kind, "state", state)
+}
+
+// prune 丢弃超过保留时间或超出保留数量的已结束任务，调用方需持有 mu
+func (m *Manager) prune(now time.Time) {
+	cutoff := now.Add(-m.opts.FinishedTTL).UnixMilli()
+	m.jobs = slices.DeleteFunc(m.jobs, func(j *job) bool {
+		return !j.running() && j.snapshot().EndedAt < cutoff
+	})
+
+	finished := 0
+	for _, j := range m.jobs {
+		if !j.running() {
+			finished++
+		}
+	}
+	m.jobs = slices.DeleteFunc(m.jobs, func(j *job) bool {
+		if finished > maxFinished && !j.running() {
+			finished--
+			return true
+		}
+		return false
+	})
+}
+
+// get 按 ID 查找任务
+func (m *Manager) get(id string) (*job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.state.ID == id {
+			return j, true
+		}
+	}
+	return nil, false
+}
+
+// Get 按 ID 返回任务状态，运行中和取消中的任务带实际 goroutine 统计
+func (m *Manager) Get(id string) (Job, bool) {
+	j, ok := m.get(id)
+	if !ok {
+		return Job{}, false
+	}
+	job := j.snapshot()
+	if job.State == StateRunning || job.State == StateCancelling {
+		runtime, err := metrics.BlocksByLabel(JobLabel)
+		job.setRuntime(runtime, err)
+	}
+	return job, true
+}
+
+// Cancel 取消任务并等待其 goroutine 全部退出或 ctx 结束
+// ctx 先结束时（如 worker 卡在 IO 或锁上）返回的状态仍为 cancelling，任务退出后变为 cancelled
+func (m *Manager) Cancel(ctx context.Context, id string) (Job, error) {
+	j, ok := m.get(id)
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	j.stop()
+	select {
+	case <-j.done:
+	case <-ctx.Done():
+	}
+	return j.snapshot(), nil
+}
+
+// setRuntime 填入按 job 标签的统计，无法统计时记录原因
+func (j *Job) setRuntime(runtime map[string]metrics.LabelBlocks, err error) {
+	if err != nil {
+		j.RuntimeError = err.Error()
+		return
+	}
+	rt := runtime[j.ID]
+	j.Runtime = &rt
+}
+
+// List 返回所有任务的状态（最新的在前）和当前预算
+func (m *Manager) List() ([]Job, Budget) {
+	m.mu.Lock()
+	m.prune(time.Now())
+	jobs := slices.Clone(m.jobs)
+	budget := m.budget
+	m.mu.Unlock()
+
+	var (
+		runtime    map[string]metrics.LabelBlocks
+		runtimeErr error
+	)
+	out := make([]Job, 0, len(jobs))
+	for _, j := range slices.Backward(jobs) {
+		job := j.snapshot()
+		if job.State == StateRunning || job.State == StateCancelling {
+			if runtime == nil && runtimeErr == nil {
+				runtime, runtimeErr = metrics.BlocksByLabel(JobLabel)
+			}
+			job.setRuntime(runtime, runtimeErr)
+		}
+		out = append(out, job)
+	}
+	return out, budget
+}
+
+// StopAll 取消所有任务并等待结束或 ctx 到期
+func (m *Manager) StopAll(ctx context.Context) {
+	m.mu.Lock()
+	jobs := slices.Clone(m.jobs)
+	m.mu.Unlock()
+
+	for _, j := range jobs {
+		j.stop()
+	}
+	for _, j := range jobs {
+		select {
+		case <-j.done:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// stop 标记为取消中并通知任务退出
+func (j *job) stop() {
+	j.mu.Lock()
+	if j.state.State == StateRunning {
+		j.state.State = StateCancelling
+	}
+	j.mu.Unlock()
+	j.cancel()
+}
+
+// running 任务是否仍在执行
+func (j *job) running() bool {
+	select {
+	case <-j.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// newID 生成任务 ID
+func newID() string {
+	var b [6]byte
+	crand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
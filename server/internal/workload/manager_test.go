@@ -0,0 +1,156 @@
+package workload
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitCtx 返回阻塞到任务 ctx 结束的负载函数
+func waitCtx(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+// waitState 等待任务进入 want 状态
+func waitState(t *testing.T, m *Manager, id string, want State) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, ok := m.Get(id)
+		if !ok {
+			t.Fatalf("job %s not found", id)
+		}
+		if job.State == want {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s state = %s, want %s", id, job.State, want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestManagerBudgetAdmission(t *testing.T) {
+	m := NewManager(Options{MaxGoroutines: 100, MaxMemoryMB: 100, MaxDiskMB: 100})
+	defer m.StopAll(t.Context())
+
+	first, err := m.Start(Spec{Kind: KindSleep, Workers: 60, DurationSec: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		spec    Spec
+		wantErr string // 为空表示应被接受
+	}{
+		{"goroutines over budget", Spec{Kind: KindSleep, Workers: 41, DurationSec: 60}, "goroutines needs 41, 40 of 100 available"},
+		{"memory over budget", Spec{Kind: KindAlloc, RetainMB: 101, DurationSec: 60}, "memoryMB needs 101"},
+		{"disk over budget", Spec{Kind: KindIO, Workers: 7, FileMB: 16, DurationSec: 60}, "diskMB needs 112"},
+		{"exactly fits", Spec{Kind: KindSleep, Workers: 40, DurationSec: 60}, ""},
+		{"now full", Spec{Kind: KindSleep, Workers: 1, DurationSec: 60}, "goroutines needs 1, 0 of 100 available"},
+	}
+	for _, tt := range tests {
+		_, err := m.Start(tt.spec)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.wantErr != "" && (!errors.Is(err, ErrBudgetExceeded) || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: err = %v, want budget error mentioning %q", tt.name, err, tt.wantErr)
+		}
+	}
+	if _, b := m.List(); b.Reserved != 100 {
+		t.Errorf("reserved = %d, want 100", b.Reserved)
+	}
+
+	// 取消后预算归还，可以再次启动
+	if job, err := m.Cancel(t.Context(), first.ID); err != nil || job.State != StateCancelled {
+		t.Fatalf("Cancel = %+v, %v", job, err)
+	}
+	if _, b := m.List(); b.Reserved != 40 {
+		t.Errorf("reserved after cancel = %d, want 40", b.Reserved)
+	}
+	if _, err := m.Start(Spec{Kind: KindSleep, Workers: 60, DurationSec: 60}); err != nil {
+		t.Errorf("start after release: %v", err)
+	}
+}
+
+func TestManagerExpiry(t *testing.T) {
+	m := NewManager(Options{MaxGoroutines: 10})
+	job, err := m.StartFunc("short", Spec{Kind: KindLoadTest, Workers: 10, DurationSec: 0.05}, waitCtx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != StateRunning || job.ExpiresAt-job.StartedAt != 50 {
+		t.Errorf("started job = %+v", job)
+	}
+	done := waitState(t, m, job.ID, StateCompleted)
+	if done.EndedAt < done.ExpiresAt {
+		t.Errorf("ended at %d before expiry %d", done.EndedAt, done.ExpiresAt)
+	}
+	if _, b := m.List(); b.Reserved != 0 {
+		t.Errorf("reserved after expiry = %d, want 0", b.Reserved)
+	}
+}
+
+func TestManagerFailedJob(t *testing.T) {
+	m := NewManager(Options{})
+	job, err := m.StartFunc("broken", Spec{Workers: 1, DurationSec: 60}, func(context.Context) error {
+		return errors.New("boom")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := waitState(t, m, job.ID, StateFailed); got.Error != "boom" {
+		t.Errorf("error = %q, want boom", got.Error)
+	}
+}
+
+func TestManagerStartFuncRejects(t *testing.T) {
+	m := NewManager(Options{})
+	for _, s := range []Spec{{Workers: 0, DurationSec: 1}, {Workers: 1, DurationSec: 0}} {
+		if _, err := m.StartFunc("x", s, waitCtx); err == nil {
+			t.Errorf("StartFunc(%+v) accepted", s)
+		}
+	}
+}
+
+func TestManagerCancel(t *testing.T) {
+	m := NewManager(Options{})
+	if _, err := m.Cancel(t.Context(), "missing"); err != ErrNotFound {
+		t.Errorf("missing job: err = %v, want %v", err, ErrNotFound)
+	}
+
+	// worker 在 ctx 取消后仍卡住：Cancel 在等待超时后返回 cancelling，worker 退出后变为 cancelled
+	release := make(chan struct{})
+	job, err := m.StartFunc("stuck", Spec{Workers: 2, DurationSec: 60}, func(ctx context.Context) error {
+		<-ctx.Done()
+		<-release
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+	defer cancel()
+	got, err := m.Cancel(ctx, job.ID)
+	if err != nil || got.State != StateCancelling || got.EndedAt != 0 {
+		t.Fatalf("Cancel on stuck job = %+v, %v; want cancelling", got, err)
+	}
+	if _, b := m.List(); b.Reserved != 2 {
+		t.Errorf("reserved while cancelling = %d, want 2", b.Reserved)
+	}
+
+	close(release)
+	waitState(t, m, job.ID, StateCancelled)
+	if _, b := m.List(); b.Reserved != 0 {
+		t.Errorf("reserved after cancel = %d, want 0", b.Reserved)
+	}
+
+	// 已结束的任务再次取消不改变状态
+	if again, _ := m.Cancel(t.Context(), job.ID); again.State != StateCancelled {
+		t.Errorf("second cancel state = %s", again.State)
+	}
+}
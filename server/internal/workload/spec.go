@@ -0,0 +1,197 @@
+// Package workload 按需制造 CPU、内存分配、锁竞争、通道等待和 IO 压力，以任务形式统一管理，
+// 用于在 Goroutine、Memory 和 Blocks 视图中复现各类负载。压测也经 StartFunc 作为任务运行，共用预算和任务标签
+package workload
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Kind 负载类型
+type Kind string
+
+const (
+	KindCPU   Kind = "cpu"   // CPU 自旋
+	KindAlloc Kind = "alloc" // 按速率分配堆内存，可保留以模拟泄漏
+	KindLock  Kind = "lock"  // 多个 goroutine 竞争同一把互斥锁
+	KindChan  Kind = "chan"  // goroutine 阻塞在无人收发的通道上
+	KindIO    Kind = "io"    // 文件或回环 socket IO
+	KindSleep Kind = "sleep" // goroutine 空等到任务结束，对应 /api/busy
+
+	// KindLoadTest 压测，由 loadtest 包通过 StartFunc 启动，不能经 Start 创建
+	KindLoadTest Kind = "loadtest"
+)
+
+// IO 模式
+const (
+	IOModeFile = "file" // 写临时文件并 fsync
+	IOModeNet  = "net"  // 回环 TCP 连接，服务端定时写出，客户端阻塞读取
+)
+
+// 通道等待模式
+const (
+	ChanModeRecv = "recv" // 阻塞在接收
+	ChanModeSend = "send" // 阻塞在发送
+)
+
+const (
+	// maxWorkers 单个负载最多 worker 数，同时受全局 goroutine 预算限制
+	maxWorkers = 10000
+	// defaultDuration 未指定持续时间时的默认值
+	defaultDuration = 30 * time.Second
+	// maxRetainMB 分配负载最多保留的内存
+	maxRetainMB = 1024
+	// maxRateKB 分配负载最大速率（KB/秒）
+	maxRateKB = 1 << 20
+	// maxChunkKB IO 负载单次读写的最大块
+	maxChunkKB = 4096
+	// maxFileMB 文件 IO 负载单个临时文件的最大大小
+	maxFileMB = 64
+	// maxJobDiskMB 单个文件 IO 负载的 worker 数 × 文件大小上限
+	maxJobDiskMB = 2048
+)
+
+// Spec 负载参数，未用到的字段按类型忽略
+type Spec struct {
+	Kind        Kind    `json:"kind"`
+	Workers     int     `json:"workers"`     // goroutine 数，默认 1（lock 默认 8，chan 默认 100）
+	DurationSec float64 `json:"durationSec"` // 持续时间（秒），默认 30
+
+	// alloc
+	RateKB      int  `json:"rateKB,omitempty"`      // 每秒分配的 KB，默认 10240
+	ObjectBytes int  `json:"objectBytes,omitempty"` // 单个对象大小，默认 4096
+	RetainMB    int  `json:"retainMB,omitempty"`    // 保留的内存上限（MB），0 表示不保留（纯抖动）
+	Leak        bool `json:"leak,omitempty"`        // 保留全部分配直到 RetainMB 上限，否则按 FIFO 替换
+
+	// lock
+	HoldMs int `json:"holdMs,omitempty"` // 每次持锁时间（毫秒），默认 10
+
+	// chan
+	ChanMode string `json:"chanMode,omitempty"` // recv 或 send，默认 recv
+
+	// io
+	IOMode     string `json:"ioMode,omitempty"`     // file 或 net，默认 file
+	ChunkKB    int    `json:"chunkKB,omitempty"`    // 每次读写的 KB，默认 64
+	FileMB     int    `json:"fileMB,omitempty"`     // file 模式下每个临时文件的大小，写满后从头覆写，默认 16
+	IntervalMs int    `json:"intervalMs,omitempty"` // 两次读写的间隔（毫秒），默认 100
+}
+
+// duration 返回持续时间
+func (s Spec) duration() time.Duration {
+	return time.Duration(s.DurationSec * float64(time.Second))
+}
+
+// goroutines 返回负载运行时占用的 goroutine 数，用于预算
+// 不含负载主 goroutine；回环 IO 每个 worker 另有一个服务端 goroutine，外加一个 Accept goroutine
+func (s Spec) goroutines() int {
+	if s.Kind == KindIO && s.IOMode == IOModeNet {
+		return 2*s.Workers + 1
+	}
+	return s.Workers
+}
+
+// memoryMB 返回负载运行时占用的内存（MB，向上取整），用于预算
+// alloc 计保留的内存，不计随即被回收的抖动；IO 计读写缓冲
+func (s Spec) memoryMB() int {
+	switch {
+	case s.Kind == KindAlloc:
+		return s.RetainMB
+	case s.Kind == KindIO && s.IOMode == IOModeNet:
+		// 服务端共用一块，客户端每个 worker 一块
+		return ceilMB((s.Workers + 1) * s.ChunkKB)
+	case s.Kind == KindIO:
+		return ceilMB(s.ChunkKB)
+	}
+	return 0
+}
+
+// diskMB 返回负载占用的磁盘空间（MB），用于预算
+func (s Spec) diskMB() int {
+	if s.Kind == KindIO && s.IOMode == IOModeFile {
+		return s.Workers * s.FileMB
+	}
+	return 0
+}
+
+// ceilMB KB 转为 MB，向上取整
+func ceilMB(kb int) int {
+	return (kb + 1023) >> 10
+}
+
+// normalize 填充默认值并校验，返回所有错误
+func (s *Spec) normalize(maxDuration time.Duration) error {
+	var errs []error
+	if s.Workers == 0 {
+		switch s.Kind {
+		case KindLock:
+			s.Workers = 8
+		case KindChan:
+			s.Workers = 100
+		default:
+			s.Workers = 1
+		}
+	}
+	if s.Workers < 0 || s.Workers > maxWorkers {
+		errs = append(errs, fmt.Errorf("workers must be between 1 and %d", maxWorkers))
+	}
+	if s.DurationSec == 0 {
+		s.DurationSec = defaultDuration.Seconds()
+	}
+	if s.DurationSec < 0 || s.duration() > maxDuration {
+		errs = append(errs, fmt.Errorf("durationSec must be positive and at most %d", int(maxDuration.Seconds())))
+	}
+
+	switch s.Kind {
+	case KindCPU, KindSleep:
+	case KindAlloc:
+		s.RateKB = cmp.Or(s.RateKB, 10240)
+		s.ObjectBytes = cmp.Or(s.ObjectBytes, 4096)
+		if s.RateKB < 0 || s.RateKB > maxRateKB {
+			errs = append(errs, fmt.Errorf("rateKB must be between 1 and %d", maxRateKB))
+		}
+		if s.ObjectBytes < 0 || s.ObjectBytes > s.RateKB<<10 {
+			errs = append(errs, errors.New("objectBytes must be positive and at most rateKB*1024"))
+		}
+		if s.RetainMB < 0 || s.RetainMB > maxRetainMB {
+			errs = append(errs, fmt.Errorf("retainMB must be between 0 and %d", maxRetainMB))
+		}
+	case KindLock:
+		s.HoldMs = cmp.Or(s.HoldMs, 10)
+		if s.HoldMs < 0 || s.HoldMs > 10000 {
+			errs = append(errs, errors.New("holdMs must be between 1 and 10000"))
+		}
+	case KindChan:
+		s.ChanMode = cmp.Or(s.ChanMode, ChanModeRecv)
+		if s.ChanMode != ChanModeRecv && s.ChanMode != ChanModeSend {
+			errs = append(errs, fmt.Errorf("chanMode must be %q or %q", ChanModeRecv, ChanModeSend))
+		}
+	case KindIO:
+		s.IOMode = cmp.Or(s.IOMode, IOModeFile)
+		s.ChunkKB = cmp.Or(s.ChunkKB, 64)
+		s.IntervalMs = cmp.Or(s.IntervalMs, 100)
+		if s.IOMode != IOModeFile && s.IOMode != IOModeNet {
+			errs = append(errs, fmt.Errorf("ioMode must be %q or %q", IOModeFile, IOModeNet))
+		}
+		if s.ChunkKB < 0 || s.ChunkKB > maxChunkKB {
+			errs = append(errs, fmt.Errorf("chunkKB must be between 1 and %d", maxChunkKB))
+		}
+		if s.IntervalMs < 0 {
+			errs = append(errs, errors.New("intervalMs must be positive"))
+		}
+		if s.IOMode == IOModeFile {
+			s.FileMB = cmp.Or(s.FileMB, 16)
+			if s.FileMB < 0 || s.FileMB > maxFileMB {
+				errs = append(errs, fmt.Errorf("fileMB must be between 1 and %d", maxFileMB))
+			} else if s.Workers*s.FileMB > maxJobDiskMB {
+				errs = append(errs, fmt.Errorf("workers*fileMB must be at most %d", maxJobDiskMB))
+			} else if s.ChunkKB > s.FileMB<<10 {
+				errs = append(errs, errors.New("chunkKB must be at most fileMB*1024"))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown workload kind %q", s.Kind))
+	}
+	return errors.Join(errs...)
+}
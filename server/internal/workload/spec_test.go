@@ -0,0 +1,91 @@
+package workload
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpecNormalizeDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		in   Spec
+		want Spec
+	}{
+		{"cpu", Spec{Kind: KindCPU}, Spec{Kind: KindCPU, Workers: 1, DurationSec: 30}},
+		{"sleep keeps workers", Spec{Kind: KindSleep, Workers: 50, DurationSec: 2}, Spec{Kind: KindSleep, Workers: 50, DurationSec: 2}},
+		{"lock", Spec{Kind: KindLock}, Spec{Kind: KindLock, Workers: 8, DurationSec: 30, HoldMs: 10}},
+		{"chan", Spec{Kind: KindChan}, Spec{Kind: KindChan, Workers: 100, DurationSec: 30, ChanMode: ChanModeRecv}},
+		{"alloc", Spec{Kind: KindAlloc}, Spec{Kind: KindAlloc, Workers: 1, DurationSec: 30, RateKB: 10240, ObjectBytes: 4096}},
+		{"io file", Spec{Kind: KindIO}, Spec{Kind: KindIO, Workers: 1, DurationSec: 30, IOMode: IOModeFile, ChunkKB: 64, FileMB: 16, IntervalMs: 100}},
+		{"io net has no file", Spec{Kind: KindIO, IOMode: IOModeNet}, Spec{Kind: KindIO, Workers: 1, DurationSec: 30, IOMode: IOModeNet, ChunkKB: 64, IntervalMs: 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.in
+			if err := s.normalize(time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			if s != tt.want {
+				t.Errorf("normalize = %+v, want %+v", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpecNormalizeRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		spec Spec
+		want []string // 错误信息中应出现的片段
+	}{
+		{"unknown kind", Spec{Kind: "fork"}, []string{`unknown workload kind "fork"`}},
+		{"loadtest via Start", Spec{Kind: KindLoadTest}, []string{"unknown workload kind"}},
+		{"too many workers", Spec{Kind: KindSleep, Workers: 10_000_000}, []string{"workers must be between 1 and 10000"}},
+		{"negative workers", Spec{Kind: KindCPU, Workers: -1}, []string{"workers"}},
+		{"too long", Spec{Kind: KindCPU, DurationSec: 61}, []string{"durationSec must be positive and at most 60"}},
+		{"negative duration", Spec{Kind: KindCPU, DurationSec: -1}, []string{"durationSec"}},
+		{"alloc rate", Spec{Kind: KindAlloc, RateKB: maxRateKB + 1}, []string{"rateKB"}},
+		{"alloc object larger than rate", Spec{Kind: KindAlloc, RateKB: 1, ObjectBytes: 2048}, []string{"objectBytes"}},
+		{"alloc retain", Spec{Kind: KindAlloc, RetainMB: maxRetainMB + 1}, []string{"retainMB"}},
+		{"lock hold", Spec{Kind: KindLock, HoldMs: 10001}, []string{"holdMs"}},
+		{"chan mode", Spec{Kind: KindChan, ChanMode: "both"}, []string{"chanMode"}},
+		{"io mode", Spec{Kind: KindIO, IOMode: "pipe"}, []string{"ioMode"}},
+		{"io chunk", Spec{Kind: KindIO, ChunkKB: maxChunkKB + 1, FileMB: maxFileMB}, []string{"chunkKB must be between"}},
+		{"io file size", Spec{Kind: KindIO, FileMB: maxFileMB + 1}, []string{"fileMB"}},
+		{"io job disk", Spec{Kind: KindIO, Workers: 100, FileMB: 64}, []string{"workers*fileMB must be at most 2048"}},
+		{"io chunk larger than file", Spec{Kind: KindIO, ChunkKB: 2048, FileMB: 1}, []string{"chunkKB must be at most fileMB*1024"}},
+		{"all errors reported", Spec{Kind: KindLock, Workers: -1, DurationSec: -1, HoldMs: -1}, []string{"workers", "durationSec", "holdMs"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.spec
+			err := s.normalize(time.Minute)
+			if err == nil {
+				t.Fatalf("normalize(%+v) accepted", tt.spec)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(err.Error(), w) {
+					t.Errorf("error %q does not mention %q", err, w)
+				}
+			}
+		})
+	}
+}
+
+func TestSpecBudget(t *testing.T) {
+	tests := []struct {
+		spec                 Spec
+		goroutines, mem, dsk int
+	}{
+		{Spec{Kind: KindSleep, Workers: 50}, 50, 0, 0},
+		{Spec{Kind: KindAlloc, Workers: 4, RetainMB: 100}, 4, 100, 0},
+		{Spec{Kind: KindIO, IOMode: IOModeFile, Workers: 3, ChunkKB: 1500, FileMB: 16}, 3, 2, 48},
+		{Spec{Kind: KindIO, IOMode: IOModeNet, Workers: 3, ChunkKB: 512}, 7, 2, 0},
+	}
+	for _, tt := range tests {
+		if g, m, d := tt.spec.goroutines(), tt.spec.memoryMB(), tt.spec.diskMB(); g != tt.goroutines || m != tt.mem || d != tt.dsk {
+			t.Errorf("%+v: goroutines/memoryMB/diskMB = %d/%d/%d, want %d/%d/%d", tt.spec, g, m, d, tt.goroutines, tt.mem, tt.dsk)
+		}
+	}
+}
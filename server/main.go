@@ -1,36 +1,242 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"analyseGo/internal/auth"
+	"analyseGo/internal/bench"
 	"analyseGo/internal/blog"
+	"analyseGo/internal/config"
 	"analyseGo/internal/ginutil"
+	"analyseGo/internal/loadtest"
+	"analyseGo/internal/logging"
 	"analyseGo/internal/metrics"
+	"analyseGo/internal/otlp"
+	"analyseGo/internal/slo"
+	"analyseGo/internal/workload"
 
 	"github.com/gin-gonic/gin"
 )
 
-const (
-	defaultPort      = ":8099"
-	defaultWindowSec = 600   // 默认10分钟
-	maxWindowSec     = 86400 // 最大24小时
-	sampleInterval   = time.Second
-)
+// blogTrackerName 博客路由分组使用的追踪器名称
+const blogTrackerName = "blog"
 
-var (
-	tracker = metrics.NewTracker()
-	hub     = metrics.NewHub()
-)
+// server 进程内的服务状态，由 newServer 按配置创建
+type server struct {
+	cfg  *config.Config
+	auth *auth.Authenticator
+
+	registry *metrics.Registry
+	// tracker 默认追踪器，统计全部请求
+	tracker    *metrics.Tracker
+	hub        *metrics.Hub
+	requestLog *metrics.RequestLog
+	// annotations 图表事件注释，客户端提交和服务端自动记录
+	annotations *metrics.AnnotationLog
+	// collector 仅在 collector 模式下非空
+	collector  *metrics.Collector
+	metricsAPI *metrics.API
+	loadTest   *loadtest.Manager
+	jobs       *workload.Manager
+	runs       *bench.Manager
+	slo        *slo.Manager
+
+	// draining 收到退出信号后置为 true，/api/ready 返回 503
+	draining atomic.Bool
+}
+
+// newServer 按配置创建认证、追踪器、请求日志和指标接口
+func newServer(cfg *config.Config) (*server, error) {
+	authenticator, err := auth.New(authConfig(cfg.Auth))
+	if err != nil {
+		return nil, err
+	}
+	annotations := metrics.NewAnnotationLog(0)
+	registry := metrics.NewRegistry(metrics.TrackerConfig{
+		MaxHistory:    cfg.Metrics.MaxHistory,
+		RequestWindow: cfg.Metrics.RequestWindow.Std(),
+		RateWindows:   config.StdDurations(cfg.Metrics.RateWindows),
+		Sampler: metrics.SamplerOptions{
+			Interval: cfg.Metrics.SampleInterval.Std(),
+		},
+		Routes: routeOptions(cfg.Metrics.Routes),
+		Anomaly: metrics.AnomalyOptions{
+			Disabled:  !cfg.Metrics.Anomaly.Enabled,
+			Alpha:     cfg.Metrics.Anomaly.Alpha,
+			Threshold: cfg.Metrics.Anomaly.Threshold,
+			Warmup:    cfg.Metrics.Anomaly.Warmup,
+			OnEvent: func(tracker string, a metrics.Anomaly) {
+				series := a.Series
+				if a.Route != "" {
+					series = a.Route + " " + series
+				}
+				annotations.Record(fmt.Sprintf("Anomaly on %s: %s = %.4g (z = %.1f)", tracker, series, a.Value, a.Z),
+					metrics.TagAlert, "anomaly")
+			},
+		},
+	})
+	s := &server{
+		cfg:         cfg,
+		auth:        authenticator,
+		registry:    registry,
+		tracker:     registry.Tracker(metrics.DefaultTrackerName),
+		hub:         metrics.NewHub(),
+		requestLog:  metrics.NewRequestLog(cfg.Metrics.RequestLogSize, cfg.Metrics.SlowLogSize, cfg.Metrics.SlowThreshold.Std()),
+		annotations: annotations,
+	}
+	if cfg.Cluster.Mode == config.ModeCollector {
+		s.collector = metrics.NewCollector(cfg.Metrics.MaxHistory)
+	} else {
+		registry.Tracker(blogTrackerName)
+	}
+
+	s.metricsAPI = metrics.NewAPI(s.tracker, s.hub, metrics.APIOptions{
+		Collector:        s.collector,
+		RequestLog:       s.requestLog,
+		Annotations:      s.annotations,
+		Registry:         registry,
+		Interval:         cfg.Metrics.SampleInterval.Std(),
+		DefaultWindowSec: cfg.Server.DefaultWindowSec,
+		MaxWindowSec:     cfg.Server.MaxWindowSec,
+		WSOrigins:        cfg.Server.CORSOrigins,
+		CanProfile: func(r *http.Request) bool {
+			return auth.HasRole(r.Context(), auth.RoleAdmin)
+		},
+		Principal: func(r *http.Request) string {
+			p, _ := auth.FromContext(r.Context())
+			return p.Method + ":" + p.Subject
+		},
+	})
+	s.tracker.AddHook(s.requestLog)
+	s.runs = bench.NewManager(s.tracker, s.requestLog)
+	s.slo = slo.NewManager(s.tracker, sloObjectives(cfg.SLO.Objectives), s.annotations)
+	s.jobs = workload.NewManager(workload.Options{
+		MaxGoroutines: cfg.Jobs.MaxGoroutines,
+		MaxMemoryMB:   cfg.Jobs.MaxMemoryMB,
+		MaxDiskMB:     cfg.Jobs.MaxDiskMB,
+		MaxDuration:   cfg.Jobs.MaxDuration.Std(),
+		FinishedTTL:   cfg.Jobs.FinishedTTL.Std(),
+		Annotations:   s.annotations,
+	})
+	s.loadTest = loadtest.NewManager(selfURL(cfg.ListenAddr()), s.tracker, s.annotations, s.jobs)
+
+	// 关闭时持久化历史，每个追踪器一个文件；SLO 计数同样写在旁边，启动时读回
+	if cfg.Metrics.FlushPath != "" {
+		for _, name := range registry.Names() {
+			t, _ := registry.Get(name)
+			t.AddFlusher(metrics.FileFlusher{Path: flushPathFor(cfg.Metrics.FlushPath, name)})
+		}
+		if err := s.slo.Load(sloStatePath(cfg.Metrics.FlushPath)); err != nil {
+			slog.Warn("Failed to restore SLO counts, starting empty", "error", err)
+		}
+	}
+	return s, nil
+}
+
+// authConfig 把认证配置转为 auth.Config，角色已在 config.Validate 中校验
+func authConfig(ac config.AuthConfig) auth.Config {
+	out := auth.Config{
+		Enabled:    ac.Enabled,
+		Secret:     []byte(ac.Secret),
+		SessionTTL: ac.SessionTTL.Std(),
+	}
+	for _, u := range ac.Users {
+		out.Users = append(out.Users, auth.User{Username: u.Username, PasswordHash: u.PasswordHash, Role: auth.Role(u.Role)})
+	}
+	for _, t := range ac.Tokens {
+		out.Tokens = append(out.Tokens, auth.Token{Name: t.Name, Hash: t.Hash, Role: auth.Role(t.Role)})
+	}
+	return out
+}
+
+// routeOptions 把路由配置转为追踪器选项，正则已在 config.Validate 中校验
+func routeOptions(rc config.RoutesConfig) metrics.RouteOptions {
+	opts := metrics.RouteOptions{
+		MaxRoutes:   rc.MaxRoutes,
+		IdleTTL:     rc.IdleTTL.Std(),
+		CollapseIDs: rc.CollapseIDs,
+	}
+	for _, rw := range rc.Rewrites {
+		opts.Rewrites = append(opts.Rewrites, metrics.RouteRewrite{
+			Pattern: regexp.MustCompile(rw.Pattern),
+			Replace: rw.Replace,
+		})
+	}
+	return opts
+}
+
+// sloObjectives 把 SLO 配置转为定义，已在 config.Validate 中校验
+func sloObjectives(objs []config.SLOObjective) []slo.Objective {
+	out := make([]slo.Objective, len(objs))
+	for i, o := range objs {
+		out[i] = slo.Objective{
+			Name:      o.Name,
+			Route:     o.Route,
+			Method:    o.Method,
+			Kind:      slo.Kind(o.Type),
+			Target:    o.Target,
+			Threshold: o.Threshold.Std(),
+		}
+	}
+	return out
+}
+
+// selfURL 返回压测访问本服务使用的地址，监听所有地址时使用回环地址
+func selfURL(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "http://" + addr
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	return "http://" + net.JoinHostPort(host, port)
+}
+
+// flushPathFor 返回追踪器的历史文件路径，默认追踪器使用原路径，其余在扩展名前插入名称
+func flushPathFor(path, name string) string {
+	if name == metrics.DefaultTrackerName {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + name + ext
+}
+
+// sloStatePath 返回 SLO 计数的持久化文件路径，与历史文件放在一起
+func sloStatePath(flushPath string) string {
+	ext := filepath.Ext(flushPath)
+	return strings.TrimSuffix(flushPath, ext) + ".slo" + ext
+}
 
 // handlePing 健康检查
 func handlePing(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "pong"})
 }
 
+// handleReady 就绪检查，关闭前的排空阶段返回 503，便于负载均衡摘除实例
+func (s *server) handleReady(c *gin.Context) {
+	if s.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
 // handlePingSlow 模拟慢请求
 func handlePingSlow(c *gin.Context) {
 	ms := ginutil.ParseIntQuery(c, "ms", 2000)
@@ -41,131 +247,222 @@ func handlePingSlow(c *gin.Context) {
 	})
 }
 
-// handleBusy 生成大量 goroutine 模拟负载
-func handleBusy(c *gin.Context) {
-	n := ginutil.ParseIntQuery(c, "n", 50)
-	ms := ginutil.ParseIntQuery(c, "ms", 2000)
+// handleBusy 启动一个空等任务生成大量 goroutine 模拟负载，受全局 goroutine 预算限制，可通过 /api/jobs 取消
+func (s *server) handleBusy(c *gin.Context) {
+	// 不能交给 Spec 的默认值处理：n=0 会变成 1 个 worker，ms=0 会变成 30 秒
+	n, nOK := ginutil.ParsePositiveIntQuery(c, "n", 50)
+	ms, msOK := ginutil.ParsePositiveIntQuery(c, "ms", 2000)
+	if !nOK || !msOK {
+		ginutil.Error(c, http.StatusBadRequest, "n and ms must be positive")
+		return
+	}
 
-	for i := 0; i < n; i++ {
-		go func() {
-			time.Sleep(time.Duration(ms) * time.Millisecond)
-		}()
+	job, err := s.jobs.Start(workload.Spec{
+		Kind:        workload.KindSleep,
+		Workers:     n,
+		DurationSec: float64(ms) / 1000,
+	})
+	if err != nil {
+		ginutil.Error(c, workload.StartErrorStatus(err), err.Error())
+		return
 	}
 
+	// 回显任务实际采用的参数
 	c.JSON(http.StatusOK, gin.H{
-		"spawned":  n,
-		"sleep_ms": ms,
+		"spawned":  job.Spec.Workers,
+		"sleep_ms": int(job.Spec.DurationSec * 1000),
+		"job":      job.ID,
 	})
 }
 
-// handleMetrics 获取当前指标快照
-func handleMetrics(c *gin.Context) {
-	sample := tracker.CurrentSample()
-	c.JSON(http.StatusOK, sample)
+// handleGetLogLevels 获取各日志组件的级别
+func handleGetLogLevels(c *gin.Context) {
+	c.JSON(http.StatusOK, logging.Levels())
 }
 
-// handleMetricsHistory 获取历史指标数据
-func handleMetricsHistory(c *gin.Context) {
-	windowSec := ginutil.ParseWindowSeconds(c, maxWindowSec, defaultWindowSec)
-	history := tracker.HistoryWindow(windowSec)
-	c.JSON(http.StatusOK, history)
+// logLevelRequest 调整日志级别的请求体
+type logLevelRequest struct {
+	Component string `json:"component"` // 为空时调整所有组件
+	Level     string `json:"level" binding:"required"`
 }
 
-// handleMetricsRoutes 获取按路由统计的指标
-func handleMetricsRoutes(c *gin.Context) {
-	stats := tracker.RouteStats()
-	c.JSON(http.StatusOK, stats)
+// handleSetLogLevel 运行时调整日志级别
+func handleSetLogLevel(c *gin.Context) {
+	var req logLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ginutil.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := logging.SetLevel(req.Component, req.Level); err != nil {
+		ginutil.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	slog.InfoContext(c.Request.Context(), "Log level changed", "target", req.Component, "level", req.Level)
+	c.JSON(http.StatusOK, logging.Levels())
 }
 
-// handleMetricsStream SSE 流式推送实时指标
-func handleMetricsStream(c *gin.Context) {
-	c.Writer.Header().Set("Content-Type", "text/event-stream")
-	c.Writer.Header().Set("Cache-Control", "no-cache")
-	c.Writer.Header().Set("Connection", "keep-alive")
-	c.Writer.Flush()
+// setupRoutes 设置路由
+// 按角色分组：公开接口无需认证；reader 查看指标和已发布文章；editor 另可写文章和注释；
+// admin 另可使用压测、合成负载、性能剖析、导入和管理接口
+func (s *server) setupRoutes(r *gin.Engine) {
+	api := r.Group("/api")
+	// EventSource 和 WebSocket 无法设置请求头，只有这两个接口接受 ?access_token=
+	api.Use(s.auth.Middleware("/api/metrics/stream", "/api/metrics/ws"))
+	{
+		// 公开接口：存活、就绪检查和登录
+		api.GET("/ping", handlePing)
+		api.GET("/ready", s.handleReady)
+		api.POST("/auth/login", s.auth.HandleLogin)
+	}
+
+	reader := api.Group("", s.auth.Require(auth.RoleReader))
+	{
+		reader.GET("/auth/me", s.auth.HandleMe)
+		reader.GET("/ping/slow", handlePingSlow)
+		reader.GET("/jobs", s.jobs.HandleList)
+		reader.GET("/jobs/:id", s.jobs.HandleGet)
+
+		// 指标接口
+		reader.GET("/metrics", gin.WrapF(s.metricsAPI.ServeSample))
+		reader.GET("/metrics/history", gin.WrapF(s.metricsAPI.ServeHistory))
+		reader.GET("/metrics/routes", gin.WrapF(s.metricsAPI.ServeRoutes))
+		reader.GET("/metrics/rates", gin.WrapF(s.metricsAPI.ServeRates))
+		reader.GET("/metrics/routes/cardinality", gin.WrapF(s.metricsAPI.ServeRouteCardinality))
+		reader.GET("/metrics/routes/totals", gin.WrapF(s.metricsAPI.ServeRouteTotals))
+		reader.GET("/metrics/routes/sizes", gin.WrapF(s.metricsAPI.ServeRouteSizes))
+		reader.GET("/metrics/export/history", gin.WrapF(s.metricsAPI.ServeExportHistory))
+		reader.GET("/metrics/export/routes", gin.WrapF(s.metricsAPI.ServeExportRoutes))
+		reader.GET("/metrics/stream", gin.WrapF(s.metricsAPI.ServeStream))
+		reader.POST("/metrics/stream/replay", gin.WrapF(s.metricsAPI.ServeReplayControl))
+		// WebSocket 的 profile 命令另需 admin 角色，见 APIOptions.CanProfile
+		reader.GET("/metrics/ws", gin.WrapF(s.metricsAPI.ServeWS))
+		reader.GET("/metrics/requests", gin.WrapF(s.metricsAPI.ServeRequests))
+		reader.GET("/metrics/requests/thresholds", gin.WrapF(s.metricsAPI.ServeSlowThresholds))
+		reader.GET("/metrics/anomalies", gin.WrapF(s.metricsAPI.ServeAnomalies))
+		reader.GET("/metrics/annotations", gin.WrapF(s.metricsAPI.ServeAnnotations))
+		reader.GET("/metrics/trackers", gin.WrapF(s.metricsAPI.ServeTrackers))
+
+		// 基准 run 与对比报告
+		reader.GET("/runs", s.runs.HandleList)
+		reader.GET("/runs/compare", s.runs.HandleCompare)
+		reader.GET("/runs/:name", s.runs.HandleGet)
+
+		// SLO 达标率、错误预算和燃烧率告警
+		reader.GET("/slo", s.slo.HandleList)
+		reader.GET("/slo/alerts", s.slo.HandleAlerts)
+		reader.GET("/slo/:name", s.slo.HandleGet)
 
-	// 订阅通知通道
-	ch := hub.Subscribe()
-	defer hub.Unsubscribe(ch)
+		// 压测报告
+		reader.GET("/loadtest/runs", s.loadTest.HandleList)
+		reader.GET("/loadtest/runs/:id", s.loadTest.HandleGet)
+	}
 
-	// 定时推送
-	ticker := time.NewTicker(sampleInterval)
-	defer ticker.Stop()
+	editor := api.Group("", s.auth.Require(auth.RoleEditor))
+	{
+		editor.POST("/metrics/annotations", gin.WrapF(s.metricsAPI.ServeAddAnnotation))
+		editor.DELETE("/metrics/annotations", gin.WrapF(s.metricsAPI.ServeDeleteAnnotation))
+	}
 
-	for {
-		select {
-		case <-c.Request.Context().Done():
-			return
-		case <-ticker.C:
-			sendSample(c, tracker.CurrentSample())
-		case <-ch:
-			// 有新请求时立即推送
-			sendSample(c, tracker.CurrentSample())
+	admin := api.Group("", s.auth.Require(auth.RoleAdmin))
+	{
+		admin.GET("/busy", s.handleBusy)
+
+		// 合成负载接口，制造 CPU、分配、锁、通道和 IO 压力
+		for _, kind := range []workload.Kind{workload.KindCPU, workload.KindAlloc, workload.KindLock, workload.KindChan, workload.KindIO} {
+			admin.POST("/workloads/"+string(kind), s.jobs.HandleStart(kind))
 		}
+		admin.POST("/jobs/:id/cancel", s.jobs.HandleCancel)
+
+		// 修改统计、快照（含堆 profile 和 goroutine 栈）和导入
+		admin.POST("/metrics/routes/totals/reset", gin.WrapF(s.metricsAPI.ServeResetRouteTotals))
+		admin.GET("/metrics/snapshot", gin.WrapF(s.metricsAPI.ServeSnapshot))
+		admin.POST("/metrics/import", gin.WrapF(s.metricsAPI.ServeImport))
+		admin.DELETE("/metrics/import", gin.WrapF(s.metricsAPI.ServeDeleteImport))
+		admin.PUT("/metrics/requests/thresholds", gin.WrapF(s.metricsAPI.ServeSetSlowThreshold))
+
+		// 管理接口
+		admin.GET("/admin/log-levels", handleGetLogLevels)
+		admin.PUT("/admin/log-levels", handleSetLogLevel)
+
+		// 基准 run 与压测
+		admin.POST("/runs", s.runs.HandleStart)
+		admin.POST("/runs/:name/stop", s.runs.HandleStop)
+		admin.DELETE("/runs/:name", s.runs.HandleDelete)
+		admin.POST("/loadtest/runs", s.loadTest.HandleStart)
+		admin.POST("/loadtest/runs/:id/stop", s.loadTest.HandleStop)
 	}
-}
 
-// sendSample 发送单个样本数据
-func sendSample(c *gin.Context, sample metrics.Sample) {
-	data, err := json.Marshal(sample)
-	if err != nil {
-		log.Printf("Failed to marshal sample: %v", err)
+	// collector 模式只提供指标和汇聚接口
+	if s.collector != nil {
+		admin.POST("/collector/push", gin.WrapF(s.metricsAPI.ServeCollectorPush))
+		reader.GET("/collector/instances", gin.WrapF(s.metricsAPI.ServeCollectorInstances))
 		return
 	}
-	fmt.Fprintf(c.Writer, "data: %s\n\n", string(data))
-	c.Writer.Flush()
-}
 
-// startSampling 启动定时采样
-func startSampling() {
-	ticker := time.NewTicker(sampleInterval)
-	go func() {
-		for range ticker.C {
-			tracker.PushSample()
-		}
-	}()
+	// 博客接口，另用独立追踪器统计，可通过 ?tracker=blog 查询
+	// 与全局追踪器的中间件嵌套，两层共用一次请求前后的堆内存读数（见 metrics.Track）
+	// reader 只能看到已发布的文章，editor 可查看草稿并写入
+	blogAPI := api.Group("/blog")
+	blogAPI.Use(ginutil.TrackingMiddleware(s.registry.Tracker(blogTrackerName), nil))
+	blogReader := blogAPI.Group("", s.auth.Require(auth.RoleReader))
+	{
+		blogReader.GET("/posts", blog.GetPosts)
+		blogReader.GET("/posts/:id", blog.GetPost)
+		blogReader.GET("/categories", blog.GetCategories)
+		blogReader.GET("/tags", blog.GetTags)
+	}
+	blogEditor := blogAPI.Group("", s.auth.Require(auth.RoleEditor))
+	{
+		blogEditor.POST("/posts", blog.CreatePost)
+		blogEditor.PUT("/posts/:id", blog.UpdatePost)
+		blogEditor.DELETE("/posts/:id", blog.DeletePost)
+		blogEditor.POST("/categories", blog.CreateCategory)
+		blogEditor.POST("/tags", blog.CreateTag)
+	}
 }
 
-// setupRoutes 设置路由
-func setupRoutes(r *gin.Engine) {
-	api := r.Group("/api")
-	{
-		// 测试接口
-		api.GET("/ping", handlePing)
-		api.GET("/ping/slow", handlePingSlow)
-		api.GET("/busy", handleBusy)
+func main() {
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to load config", "error", err)
+		os.Exit(2)
+	}
 
-		// 指标接口
-		api.GET("/metrics", handleMetrics)
-		api.GET("/metrics/history", handleMetricsHistory)
-		api.GET("/metrics/routes", handleMetricsRoutes)
-		api.GET("/metrics/stream", handleMetricsStream)
-
-		// 博客接口
-		blogAPI := api.Group("/blog")
-		{
-			// 文章
-			blogAPI.GET("/posts", blog.GetPosts)
-			blogAPI.GET("/posts/:id", blog.GetPost)
-			blogAPI.POST("/posts", blog.CreatePost)
-			blogAPI.PUT("/posts/:id", blog.UpdatePost)
-			blogAPI.DELETE("/posts/:id", blog.DeletePost)
-
-			// 分类
-			blogAPI.GET("/categories", blog.GetCategories)
-			blogAPI.POST("/categories", blog.CreateCategory)
-
-			// 标签
-			blogAPI.GET("/tags", blog.GetTags)
-			blogAPI.POST("/tags", blog.CreateTag)
+	if err := logging.Setup(os.Stdout, cfg.Log.Format, cfg.Log.Level); err != nil {
+		slog.Error("Failed to set up logging", "error", err)
+		os.Exit(1)
+	}
+
+	if cfg.Cluster.Mode != config.ModeCollector {
+		// 初始化数据库
+		if err := blog.InitDB(blog.Config{
+			DSN:                cfg.Database.DSN,
+			SlowQueryThreshold: cfg.Database.SlowQueryThreshold.Std(),
+			MaxOpenConns:       cfg.Database.MaxOpenConns,
+			MaxIdleConns:       cfg.Database.MaxIdleConns,
+		}); err != nil {
+			slog.Error("Failed to initialize database", "error", err)
+			os.Exit(1)
 		}
 	}
-}
 
-func main() {
-	// 初始化数据库
-	if err := blog.InitDB(); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	s, err := newServer(cfg)
+	if err != nil {
+		slog.Error("Failed to set up auth", "error", err)
+		os.Exit(1)
+	}
+	switch {
+	case cfg.Auth.Enabled:
+	case cfg.Auth.Insecure:
+		slog.Warn("Auth is disabled and auth.insecure is set, all endpoints are open to anyone who can connect", "addr", cfg.ListenAddr())
+	default:
+		slog.Warn("Auth is disabled, listening on loopback only; enable auth or set auth.insecure to accept remote connections", "addr", cfg.ListenAddr())
+	}
+	if !metrics.LabelsAvailable() {
+		slog.Warn("Goroutine labels unavailable, per-route and per-job block counts are disabled", "error", metrics.ErrLabelsUnavailable)
 	}
 
 	// 设置 Gin 为发布模式
@@ -173,19 +470,124 @@ func main() {
 
 	// 创建路由
 	r := gin.New()
+	// 默认信任所有代理，任何客户端都能通过 X-Forwarded-For 伪造 IP；只信任配置的代理
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		slog.Error("Invalid trusted proxies", "error", err)
+		os.Exit(1)
+	}
 	r.Use(gin.Recovery())
-	r.Use(ginutil.CORSMiddleware())
-	r.Use(ginutil.TrackingMiddleware(tracker, hub))
+	r.Use(ginutil.CORSMiddleware(cfg.Server.CORSOrigins))
+	r.Use(ginutil.RequestIDMiddleware())
+	r.Use(ginutil.AccessLogMiddleware(logging.Logger(logging.ComponentAccess)))
+	r.Use(ginutil.TrackingMiddleware(s.tracker, s.hub))
 
 	// 设置路由
-	setupRoutes(r)
+	s.setupRoutes(r)
 
-	// 启动定时采样
-	startSampling()
+	// 后台任务（推送、导出）共用一个 ctx，在 HTTP 服务关闭后统一停止
+	bgCtx, stopBackground := context.WithCancel(context.Background())
+	var bg sync.WaitGroup
+
+	// 启动所有追踪器的定时采样
+	s.registry.StartAll(context.Background())
+
+	// runtime.GC、debug.FreeOSMemory 等强制 GC 记为注释
+	bg.Go(func() { s.annotations.WatchForcedGC(bgCtx, cfg.Metrics.SampleInterval.Std()) })
+
+	// 定期评估 SLO 燃烧率告警
+	bg.Go(func() { s.slo.Run(bgCtx, cfg.SLO.EvalInterval.Std()) })
+
+	// agent 模式下定期推送到 Collector
+	if cfg.Cluster.Mode == config.ModeAgent {
+		name := cfg.Cluster.Instance
+		if name == "" {
+			host, _ := os.Hostname()
+			name = host + cfg.Server.Addr
+		}
+		agent := metrics.NewAgent(s.tracker, name, cfg.Cluster.CollectorURL, cfg.Cluster.Token, cfg.Cluster.PushInterval.Std())
+		agent.SetPropagator(otlp.Propagate)
+		bg.Go(func() { agent.Run(bgCtx) })
+		slog.Info("Agent pushing to collector", "instance", name, "url", cfg.Cluster.CollectorURL)
+	}
+
+	// 导出 OTLP 指标和 span
+	if cfg.OTLP.Endpoint != "" {
+		exporter := otlp.NewExporter(otlp.Config{
+			Endpoint:    cfg.OTLP.Endpoint,
+			ServiceName: cfg.OTLP.ServiceName,
+			Interval:    cfg.OTLP.Interval.Std(),
+			Headers:     cfg.OTLP.Headers,
+		}, s.tracker)
+		s.tracker.AddHook(exporter)
+		bg.Go(func() { exporter.Run(bgCtx) })
+		slog.Info("Exporting OTLP", "endpoint", cfg.OTLP.Endpoint)
+	}
+
+	srv := &http.Server{
+		Addr:    cfg.ListenAddr(),
+		Handler: r,
+	}
 
 	// 启动服务器
-	log.Printf("Server starting on port %s", defaultPort)
-	if err := r.Run(defaultPort); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("Server starting", "addr", cfg.ListenAddr(), "mode", cfg.Cluster.Mode)
+		s.annotations.Record("Server started ("+cfg.Cluster.Mode+")", metrics.TagStartup)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	sigCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	select {
+	case err := <-serveErr:
+		slog.Error("Failed to start server", "error", err)
+		os.Exit(1)
+	case <-sigCtx.Done():
+	}
+	// 再次收到信号时直接退出
+	stopSignals()
+
+	s.shutdown(srv, stopBackground, &bg)
+}
+
+// shutdown 优雅关闭：排空、停止压测和合成负载、断开长连接、等待进行中请求、停止后台任务和采样、持久化历史、关闭数据库
+func (s *server) shutdown(srv *http.Server, stopBackground context.CancelFunc, bg *sync.WaitGroup) {
+	cfg := s.cfg
+	slog.Info("Shutting down", "drainDelay", cfg.Server.DrainDelay.Std(), "timeout", cfg.Server.ShutdownTimeout.Std())
+
+	// 排空：就绪检查失败但继续处理请求，等待负载均衡摘除
+	s.draining.Store(true)
+	time.Sleep(cfg.Server.DrainDelay.Std())
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Std())
+	defer cancel()
+
+	// 压测请求打到本服务，须在关闭监听前停止；合成负载一并停止
+	s.loadTest.StopAll(ctx)
+	s.jobs.StopAll(ctx)
+
+	// SSE 和 WebSocket 不会自行结束，先通知它们重连
+	s.hub.Close()
+	if err := srv.Shutdown(ctx); err != nil {
+		slog.Warn("Server shutdown incomplete", "error", err)
+	}
+
+	// 先停采样器（会补采最后一个样本），再停推送和导出，使其带上最后的样本
+	s.registry.StopAll()
+	stopBackground()
+	bg.Wait()
+
+	if err := s.registry.Flush(ctx); err != nil {
+		slog.Error("Failed to flush history", "error", err)
+	}
+	if cfg.Metrics.FlushPath != "" {
+		if err := s.slo.Save(sloStatePath(cfg.Metrics.FlushPath)); err != nil {
+			slog.Error("Failed to save SLO counts", "error", err)
+		}
+	}
+	if err := blog.CloseDB(); err != nil {
+		slog.Error("Failed to close database", "error", err)
 	}
+	slog.Info("Server stopped")
 }